@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Admin serves diagnostics API on a separate listener, so it's never exposed together with
+// public routes. Every request must provide the configured bearer token
+type Admin struct {
+	Hostname string
+	Port     uint16
+	token    string
+	rest     *REST
+	service  *Service
+	router   *chi.Mux
+	server   *http.Server
+}
+
+func (a *Admin) Init(config *AdminConfig, rest *REST, service *Service) error {
+	log.Traceln("Admin::Init")
+	if config == nil {
+		return fmt.Errorf("no configuration")
+	}
+	if config.Token == "" {
+		return fmt.Errorf("admin token is required")
+	}
+	if rest == nil || service == nil {
+		return fmt.Errorf("admin requires REST and service")
+	}
+	a.Hostname = config.Hostname
+	a.Port = config.Port
+	a.token = config.Token
+	a.rest = rest
+	a.service = service
+
+	a.router = chi.NewMux()
+	a.router.Use(a.AuthMiddleware)
+	a.router.Get("/routes", a.HandleRoutes)
+	a.router.Get("/services", a.HandleServices)
+	a.router.Get("/websockets", a.HandleWebSockets)
+	a.router.Get("/kafka", a.HandleKafka)
+	a.router.Get("/cache", a.HandleCache)
+	a.router.Get("/load", a.HandleLoad)
+	a.router.Get("/tokens", a.HandleTokens)
+	a.router.Post("/tokens/revoke", a.HandleRevokeTokens)
+	a.router.Get("/maintenance", a.HandleMaintenance)
+	a.router.Post("/maintenance", a.HandleEnableMaintenance)
+	a.router.Delete("/maintenance", a.HandleDisableMaintenance)
+	a.server = &http.Server{Addr: fmt.Sprintf("%s:%d", a.Hostname, a.Port), Handler: a.router}
+	return nil
+}
+
+func (a *Admin) Start() error {
+	log.Traceln("Admin::Start")
+	log.Infof("Admin API will start on %s:%d", a.Hostname, a.Port)
+	if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops the admin API, waiting for in-flight requests until the context is done
+func (a *Admin) Shutdown(ctx context.Context) error {
+	log.Traceln("Admin::Shutdown")
+	if a.server == nil {
+		return nil
+	}
+	return a.server.Shutdown(ctx)
+}
+
+func (a *Admin) AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		token, found := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			log.Warnf("Unauthorized admin request %s %s from %s", req.Method, req.URL.Path, req.RemoteAddr)
+			writeErrorResponse(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (a *Admin) HandleRoutes(w http.ResponseWriter, req *http.Request) {
+	log.Traceln("Admin::HandleRoutes")
+	writeAdminResponse(w, "routes", a.rest.RouteStatus())
+}
+
+func (a *Admin) HandleServices(w http.ResponseWriter, req *http.Request) {
+	log.Traceln("Admin::HandleServices")
+	writeAdminResponse(w, "services", a.service.Status())
+}
+
+func (a *Admin) HandleWebSockets(w http.ResponseWriter, req *http.Request) {
+	log.Traceln("Admin::HandleWebSockets")
+	pending, authenticated := a.rest.WebSocketStatus()
+	writeAdminResponse(w, "websockets", map[string]interface{}{
+		"pending":       pending,
+		"authenticated": authenticated,
+	})
+}
+
+func (a *Admin) HandleKafka(w http.ResponseWriter, req *http.Request) {
+	log.Traceln("Admin::HandleKafka")
+	writeAdminResponse(w, "kafka", a.rest.kafka.Status())
+}
+
+func (a *Admin) HandleCache(w http.ResponseWriter, req *http.Request) {
+	log.Traceln("Admin::HandleCache")
+	writeAdminResponse(w, "cache", a.rest.cache.Status())
+}
+
+func (a *Admin) HandleLoad(w http.ResponseWriter, req *http.Request) {
+	log.Traceln("Admin::HandleLoad")
+	writeAdminResponse(w, "load", a.rest.shedder.Status())
+}
+
+func (a *Admin) HandleTokens(w http.ResponseWriter, req *http.Request) {
+	log.Traceln("Admin::HandleTokens")
+	writeAdminResponse(w, "tokens", a.rest.tokens.Status())
+}
+
+// TokenRevocation lists users and tokens whose cached validation results are removed
+type TokenRevocation struct {
+	UserIds []int32  `json:"user_ids"`
+	Tokens  []string `json:"tokens"`
+}
+
+// HandleRevokeTokens removes cached validation results of users and tokens given in the body
+func (a *Admin) HandleRevokeTokens(w http.ResponseWriter, req *http.Request) {
+	log.Traceln("Admin::HandleRevokeTokens")
+	var revocation TokenRevocation
+	if err := json.NewDecoder(req.Body).Decode(&revocation); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("invalid revocation: %s", err.Error()))
+		return
+	}
+	if len(revocation.UserIds) == 0 && len(revocation.Tokens) == 0 {
+		writeErrorResponse(w, http.StatusBadRequest, "user_ids or tokens are required")
+		return
+	}
+	removed := a.rest.tokens.RevokeUsers(revocation.UserIds...) + a.rest.tokens.RevokeTokens(revocation.Tokens...)
+	log.Infof("Admin revoked tokens of %d users and %d tokens, %d cached results removed", len(revocation.UserIds), len(revocation.Tokens), removed)
+	writeAdminResponse(w, "removed", removed)
+}
+
+func (a *Admin) HandleMaintenance(w http.ResponseWriter, req *http.Request) {
+	log.Traceln("Admin::HandleMaintenance")
+	writeAdminResponse(w, "maintenance", a.rest.maintenance.Windows())
+}
+
+// HandleEnableMaintenance puts the service or the route described by MaintenanceWindow in the body under maintenance
+func (a *Admin) HandleEnableMaintenance(w http.ResponseWriter, req *http.Request) {
+	log.Traceln("Admin::HandleEnableMaintenance")
+	var window MaintenanceWindow
+	if err := json.NewDecoder(req.Body).Decode(&window); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("invalid maintenance window: %s", err.Error()))
+		return
+	}
+	enabled, err := a.rest.maintenance.Enable(window)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeAdminResponse(w, "maintenance", enabled)
+}
+
+// HandleDisableMaintenance ends maintenance of the service or the route given with query parameters
+func (a *Admin) HandleDisableMaintenance(w http.ResponseWriter, req *http.Request) {
+	log.Traceln("Admin::HandleDisableMaintenance")
+	query := req.URL.Query()
+	if !a.rest.maintenance.Disable(query.Get("service"), query.Get("method"), query.Get("pattern")) {
+		writeErrorResponse(w, http.StatusNotFound, "not under maintenance")
+		return
+	}
+	writeAdminResponse(w, "maintenance", a.rest.maintenance.Windows())
+}
+
+func writeAdminResponse(w http.ResponseWriter, key string, value interface{}) {
+	data := make(map[string]interface{})
+	data["code"] = 0
+	data["date"] = time.Now().String()
+	data[key] = value
+
+	response, err := json.Marshal(data)
+	if err != nil {
+		log.Errorf("Failed to marshal admin response: %s", err.Error())
+		writeErrorResponse(w, http.StatusInternalServerError, "failed to marshal response")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(response)
+}
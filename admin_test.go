@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/savageking-io/ogbrest/kafka"
+	"github.com/savageking-io/ogbrest/proto"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestAdmin(t *testing.T) *Admin {
+	maintenance, err := NewMaintenance(MaintenanceConfig{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &REST{routes: NewRouteTable(), kafka: new(kafka.Publisher), maintenance: maintenance}
+	_ = r.routes.ReplaceServiceRoutes(GatewayRouteOwner, []*RouteEntry{{Method: "GET", Pattern: "/status", Auth: AuthPolicyNone, Handler: routeHandler(200)}})
+	_ = r.routes.ReplaceServiceRoutes("user", []*RouteEntry{
+		{Method: "GET", Pattern: "/user/{id}", Endpoint: &proto.RestEndpoint{Method: "GET", Path: "/{id}"}, Handler: routeHandler(200)},
+		{Method: "POST", Pattern: "/user/login", Endpoint: &proto.RestEndpoint{Method: "POST", Path: "/login", SkipAuthMiddleware: true}, Handler: routeHandler(200)},
+	})
+	pool, err := NewServicePool(&ServiceConfig{Label: "user", Hostname: "localhost", Port: 12121}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Service{restClients: map[string]*ServicePool{"user": pool}}
+
+	a := &Admin{}
+	if err := a.Init(&AdminConfig{Port: 1, Token: "secret"}, r, s); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestAdmin_Init(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *AdminConfig
+		wantErr bool
+	}{
+		{"No config", nil, true},
+		{"No token", &AdminConfig{Port: 1}, true},
+		{"Valid", &AdminConfig{Port: 1, Token: "secret"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Admin{}
+			if err := a.Init(tt.config, &REST{}, &Service{}); (err != nil) != tt.wantErr {
+				t.Errorf("Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAdmin_AuthMiddleware(t *testing.T) {
+	a := newTestAdmin(t)
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"No header", "", http.StatusUnauthorized},
+		{"Wrong scheme", "secret", http.StatusUnauthorized},
+		{"Wrong token", "Bearer wrong", http.StatusUnauthorized},
+		{"Valid token", "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/routes", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			a.router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("GET /routes got = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdmin_HandleRoutes(t *testing.T) {
+	a := newTestAdmin(t)
+	req := httptest.NewRequest("GET", "/routes", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+
+	var response struct {
+		Routes []RouteStatus `json:"routes"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := []RouteStatus{
+		{Method: "GET", Pattern: "/status", Owner: GatewayRouteOwner, Auth: "none"},
+		{Method: "POST", Pattern: "/user/login", Owner: "user", Auth: "none"},
+		{Method: "GET", Pattern: "/user/{id}", Owner: "user", Auth: "jwt"},
+	}
+	if !reflect.DeepEqual(response.Routes, want) {
+		t.Errorf("routes got = %+v, want %+v", response.Routes, want)
+	}
+}
+
+func TestAdmin_HandleServices(t *testing.T) {
+	a := newTestAdmin(t)
+	req := httptest.NewRequest("GET", "/services", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+
+	var response struct {
+		Services []ServiceStatus `json:"services"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(response.Services) != 1 || len(response.Services[0].Instances) != 1 {
+		t.Fatalf("services got = %+v, want 1 service with 1 instance", response.Services)
+	}
+	if got := response.Services[0].Instances[0].State; got != ClientStateDisconnected {
+		t.Errorf("state got = %s, want %s", got, ClientStateDisconnected)
+	}
+}
+
+func TestAdmin_HandleMaintenance(t *testing.T) {
+	a := newTestAdmin(t)
+	steps := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{"Invalid body", "POST", "/maintenance", "{", http.StatusBadRequest},
+		{"No service or route", "POST", "/maintenance", `{"message": "migration"}`, http.StatusBadRequest},
+		{"Invalid IP", "POST", "/maintenance", `{"service": "user", "allow_ips": ["qa"]}`, http.StatusBadRequest},
+		{"Enable", "POST", "/maintenance", `{"service": "user", "retry_after": "10m", "allow_users": [7]}`, http.StatusOK},
+		{"Disable", "DELETE", "/maintenance?service=user", "", http.StatusOK},
+		{"Disable again", "DELETE", "/maintenance?service=user", "", http.StatusNotFound},
+	}
+	for _, step := range steps {
+		req := httptest.NewRequest(step.method, step.target, strings.NewReader(step.body))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		a.router.ServeHTTP(w, req)
+		if w.Code != step.want {
+			t.Errorf("%s: got = %d, want %d: %s", step.name, w.Code, step.want, w.Body.String())
+		}
+		if step.name == "Enable" {
+			windows := a.rest.maintenance.Windows()
+			if len(windows) != 1 || windows[0].RetryAfter != "10m0s" || windows[0].Message != DefaultMaintenanceMessage {
+				t.Errorf("windows got = %+v", windows)
+			}
+		}
+	}
+}
+
+func TestAdmin_HandleRevokeTokens(t *testing.T) {
+	a := newTestAdmin(t)
+	validator := &countingValidator{users: map[string]int32{"first": 1, "second": 2}}
+	a.rest.tokens = NewTokenCache(TokenCacheConfig{Enabled: true}, validator)
+	for _, token := range []string{"first", "second"} {
+		_, _, _ = a.rest.tokens.ValidateToken(context.Background(), token)
+	}
+	steps := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"Invalid body", "{", http.StatusBadRequest},
+		{"Nothing to revoke", "{}", http.StatusBadRequest},
+		{"Revoke", `{"user_ids": [1], "tokens": ["second"]}`, http.StatusOK},
+	}
+	for _, step := range steps {
+		req := httptest.NewRequest("POST", "/tokens/revoke", strings.NewReader(step.body))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		a.router.ServeHTTP(w, req)
+		if w.Code != step.want {
+			t.Errorf("%s: got = %d, want %d: %s", step.name, w.Code, step.want, w.Body.String())
+		}
+	}
+	if entries := a.rest.tokens.Status().Entries; entries != 0 {
+		t.Errorf("entries after revocation got = %d, want 0", entries)
+	}
+}
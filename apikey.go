@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// DefaultAPIKeyHeader carries API keys when configuration doesn't name another header
+const DefaultAPIKeyHeader = "X-Api-Key"
+
+// ErrInvalidAPIKey is returned for unknown and expired keys
+var ErrInvalidAPIKey = errors.New("invalid or expired API key")
+
+// APIKey authenticates servers, bots and tools that can't obtain user tokens
+type APIKey struct {
+	Name      string
+	Owner     string
+	UserId    int32
+	Roles     []string
+	Scopes    []string
+	ExpiresAt time.Time  // Zero when key never expires
+	rateLimit *RateLimit // Replaces gateway rate limit for requests with the key. nil when not set
+}
+
+// APIKeys holds keys of the configuration by their hashes, so keys themselves are never kept by the gateway
+type APIKeys struct {
+	header      string
+	rolesClaim  string
+	scopesClaim string
+	byHash      map[string]*APIKey
+}
+
+// NewAPIKeys returns nil when no keys are configured
+func NewAPIKeys(config AuthConfig) (*APIKeys, error) {
+	if len(config.APIKeys.Keys) == 0 {
+		return nil, nil
+	}
+	authorizer := NewAuthorizer(config)
+	k := &APIKeys{
+		header:      config.APIKeys.Header,
+		rolesClaim:  authorizer.rolesClaim,
+		scopesClaim: authorizer.scopesClaim,
+		byHash:      make(map[string]*APIKey),
+	}
+	if k.header == "" {
+		k.header = DefaultAPIKeyHeader
+	}
+	names := make(map[string]bool)
+	for _, key := range config.APIKeys.Keys {
+		if key.Name == "" {
+			return nil, fmt.Errorf("API key name is required")
+		}
+		if names[key.Name] {
+			return nil, fmt.Errorf("API key %s is defined twice", key.Name)
+		}
+		names[key.Name] = true
+		hash := strings.ToLower(key.Hash)
+		if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("hash of API key %s must be hex encoded SHA-256", key.Name)
+		}
+		if _, ok := k.byHash[hash]; ok {
+			return nil, fmt.Errorf("API key %s has the same hash as another key", key.Name)
+		}
+		limit, err := newRateLimit(key.RateLimit.Requests, key.RateLimit.Period, key.RateLimit.Burst, key.RateLimit.Key, RateLimitKeyUser)
+		if err != nil {
+			return nil, fmt.Errorf("API key %s: %w", key.Name, err)
+		}
+		if !key.ExpiresAt.IsZero() && time.Now().After(key.ExpiresAt) {
+			log.Warnf("API key %s of %s expired at %s", key.Name, key.Owner, key.ExpiresAt.String())
+		}
+		k.byHash[hash] = &APIKey{
+			Name:      key.Name,
+			Owner:     key.Owner,
+			UserId:    key.UserId,
+			Roles:     key.Roles,
+			Scopes:    key.Scopes,
+			ExpiresAt: key.ExpiresAt,
+			rateLimit: limit,
+		}
+	}
+	log.Infof("Loaded %d API keys, accepted in %s header", len(k.byHash), k.header)
+	return k, nil
+}
+
+// Header returns name of the header with API key, or empty string when API keys are disabled
+func (k *APIKeys) Header() string {
+	if k == nil {
+		return ""
+	}
+	return k.header
+}
+
+// Authenticate returns identity of the key. Roles and scopes of the key become claims, so endpoints
+// authorize keys the same way as tokens
+func (k *APIKeys) Authenticate(value string) (*Identity, error) {
+	if k == nil {
+		return nil, ErrInvalidAPIKey
+	}
+	key, ok := k.byHash[HashAPIKey(value)]
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+	if !key.ExpiresAt.IsZero() && time.Now().After(key.ExpiresAt) {
+		log.Warnf("Rejecting expired API key %s of %s", key.Name, key.Owner)
+		return nil, ErrInvalidAPIKey
+	}
+	claims := map[string][]string{"owner": {key.Owner}}
+	if len(key.Roles) > 0 {
+		claims[k.rolesClaim] = key.Roles
+	}
+	if len(key.Scopes) > 0 {
+		claims[k.scopesClaim] = key.Scopes
+	}
+	return &Identity{
+		UserId:     key.UserId,
+		Claims:     claims,
+		AuthMethod: AuthMethodAPIKey,
+		APIKey:     key.Name,
+		apiKey:     key,
+	}, nil
+}
+
+// HashAPIKey returns the hash API keys are stored by in configuration
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
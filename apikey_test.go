@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/savageking-io/ogbrest/kafka"
+	"github.com/savageking-io/ogbrest/proto"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewAPIKeys(t *testing.T) {
+	hash := HashAPIKey("server-key")
+	tests := []struct {
+		name    string
+		keys    []APIKeyConfig
+		wantNil bool
+		wantErr bool
+	}{
+		{"No keys", nil, true, false},
+		{"Valid", []APIKeyConfig{{Name: "matchmaking", Hash: hash}}, false, false},
+		{"Upper case hash", []APIKeyConfig{{Name: "matchmaking", Hash: strings.ToUpper(HashAPIKey("other"))}}, false, false},
+		{"No name", []APIKeyConfig{{Hash: hash}}, false, true},
+		{"Duplicate name", []APIKeyConfig{{Name: "bot", Hash: hash}, {Name: "bot", Hash: HashAPIKey("other")}}, false, true},
+		{"Duplicate hash", []APIKeyConfig{{Name: "bot", Hash: hash}, {Name: "tool", Hash: hash}}, false, true},
+		{"Plain key instead of hash", []APIKeyConfig{{Name: "bot", Hash: "server-key"}}, false, true},
+		{"Invalid rate limit key", []APIKeyConfig{{Name: "bot", Hash: hash, RateLimit: APIKeyRateLimitConfig{Requests: 1, Key: "session"}}}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := NewAPIKeys(AuthConfig{APIKeys: APIKeysConfig{Keys: tt.keys}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewAPIKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (keys == nil) != tt.wantNil {
+				t.Errorf("NewAPIKeys() got = %v, wantNil %v", keys, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestAPIKeys_Authenticate(t *testing.T) {
+	keys, err := NewAPIKeys(AuthConfig{ScopesClaim: "permissions", APIKeys: APIKeysConfig{Keys: []APIKeyConfig{
+		{Name: "matchmaking", Hash: HashAPIKey("server-key"), Owner: "game servers", Roles: []string{"server"}, Scopes: []string{"match:write"}},
+		{Name: "old-bot", Hash: HashAPIKey("bot-key"), Owner: "community", ExpiresAt: time.Now().Add(-time.Hour)},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity, err := keys.Authenticate("server-key")
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if identity.AuthMethod != AuthMethodAPIKey || identity.APIKey != "matchmaking" || identity.Claims["owner"][0] != "game servers" {
+		t.Errorf("Authenticate() got = %+v", identity)
+	}
+	if identity.Claims["roles"][0] != "server" || identity.Claims["permissions"][0] != "match:write" {
+		t.Errorf("Authenticate() claims got = %v", identity.Claims)
+	}
+	for _, key := range []string{"bot-key", "unknown", ""} {
+		if _, err := keys.Authenticate(key); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("Authenticate(%s) error = %v, want %v", key, err, ErrInvalidAPIKey)
+		}
+	}
+}
+
+func TestREST_JWTMiddleware_APIKey(t *testing.T) {
+	keys, err := NewAPIKeys(AuthConfig{APIKeys: APIKeysConfig{Header: "X-Server-Key", Keys: []APIKeyConfig{
+		{Name: "matchmaking", Hash: HashAPIKey("server-key"), Roles: []string{"server"}, RateLimit: APIKeyRateLimitConfig{Requests: 2, Period: time.Minute}},
+		{Name: "tool", Hash: HashAPIKey("tool-key")},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter, err := NewRateLimiter(RateLimitConfig{Requests: 1, Period: time.Minute}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &REST{apiKeys: keys, limiter: limiter, kafka: new(kafka.Publisher)}
+	r.routes = NewRouteTable(r.JWTMiddleware(), r.RateLimitMiddleware())
+	var identity *Identity
+	_ = r.routes.ReplaceServiceRoutes("match", []*RouteEntry{
+		{Method: "POST", Pattern: "/match/result", Endpoint: &proto.RestEndpoint{Roles: []string{"server"}}, Handler: func(w http.ResponseWriter, req *http.Request) {
+			identity = identityFromContext(req.Context())
+		}},
+	})
+	steps := []struct {
+		name string
+		key  string
+		want int
+	}{
+		{"Unknown key", "wrong", http.StatusUnauthorized},
+		{"Key without role", "tool-key", http.StatusForbidden},
+		{"Server", "server-key", http.StatusOK},
+		{"Key limit replaces gateway limit", "server-key", http.StatusOK},
+		{"Key limit exceeded", "server-key", http.StatusTooManyRequests},
+	}
+	for _, step := range steps {
+		req := httptest.NewRequest("POST", "/match/result", nil)
+		req.Header.Set("X-Server-Key", step.key)
+		w := httptest.NewRecorder()
+		r.routes.ServeHTTP(w, req)
+		if w.Code != step.want {
+			t.Errorf("%s: got = %d, want %d", step.name, w.Code, step.want)
+		}
+	}
+	if identity == nil || identity.toProto().AuthMethod != AuthMethodAPIKey || identity.toProto().ApiKey != "matchmaking" {
+		t.Errorf("identity got = %+v", identity)
+	}
+}
+
+func TestREST_httpRequestHeadToProto_APIKey(t *testing.T) {
+	keys, err := NewAPIKeys(AuthConfig{APIKeys: APIKeysConfig{Header: "x-server-key", Keys: []APIKeyConfig{
+		{Name: "matchmaking", Hash: HashAPIKey("server-key")},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyIdentity, err := keys.Authenticate("server-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		identity *Identity
+		want     string // Forwarded headers
+	}{
+		{"API key", keyIdentity, "[Accept]"},
+		{"JWT", &Identity{UserId: 1, AuthMethod: AuthMethodJWT}, "[Accept Authorization]"},
+		{"Anonymous", nil, "[Accept Authorization]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/match/result", nil)
+			req.Header.Set("X-Server-Key", "server-key")
+			req.Header.Set("Authorization", "Bearer token")
+			req.Header.Set("Accept", "application/json")
+			if tt.identity != nil {
+				req = req.WithContext(withIdentity(req.Context(), tt.identity))
+			}
+			request := (&REST{apiKeys: keys}).httpRequestHeadToProto(req)
+			var forwarded []string
+			for _, header := range request.Headers {
+				forwarded = append(forwarded, header.Key)
+			}
+			sort.Strings(forwarded)
+			if fmt.Sprint(forwarded) != tt.want {
+				t.Errorf("httpRequestHeadToProto() headers got = %v, want %s", forwarded, tt.want)
+			}
+		})
+	}
+}
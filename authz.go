@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultRolesClaim  = "roles"
+	DefaultScopesClaim = "scope"
+)
+
+// Authorizer checks roles and scopes required by endpoints against claims of the token
+type Authorizer struct {
+	rolesClaim  string
+	scopesClaim string
+}
+
+func NewAuthorizer(config AuthConfig) *Authorizer {
+	a := &Authorizer{rolesClaim: config.RolesClaim, scopesClaim: config.ScopesClaim}
+	if a.rolesClaim == "" {
+		a.rolesClaim = DefaultRolesClaim
+	}
+	if a.scopesClaim == "" {
+		a.scopesClaim = DefaultScopesClaim
+	}
+	return a
+}
+
+// requiresAuthorization returns true when the endpoint of the route limits who may call it
+func requiresAuthorization(route *RouteEntry) bool {
+	return route != nil && route.Endpoint != nil && (len(route.Endpoint.Roles) > 0 || len(route.Endpoint.Scopes) > 0)
+}
+
+// Authorize returns true when the identity has at least one of the roles and all the scopes of the route
+func (a *Authorizer) Authorize(identity *Identity, route *RouteEntry) bool {
+	if !requiresAuthorization(route) {
+		return true
+	}
+	if a == nil {
+		return NewAuthorizer(AuthConfig{}).Authorize(identity, route)
+	}
+	if identity == nil {
+		return false
+	}
+	if roles := route.Endpoint.Roles; len(roles) > 0 {
+		granted := identity.claimValues(a.rolesClaim)
+		if !slices.ContainsFunc(roles, func(role string) bool { return slices.Contains(granted, role) }) {
+			return false
+		}
+	}
+	granted := identity.claimValues(a.scopesClaim)
+	for _, scope := range route.Endpoint.Scopes {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// claimValues returns values of the claim. Space-delimited values are split, as scope claim is a single string
+func (i *Identity) claimValues(claim string) []string {
+	var result []string
+	for _, value := range i.Claims[claim] {
+		result = append(result, strings.Fields(value)...)
+	}
+	return result
+}
+
+// writeForbidden answers 403 with roles and scopes the route requires
+func writeForbidden(w http.ResponseWriter, req *http.Request, route *RouteEntry) {
+	log.Debugf("Forbidden %s %s: roles %v, scopes %v are required", req.Method, req.URL.Path, route.Endpoint.Roles, route.Endpoint.Scopes)
+	body := map[string]interface{}{
+		"code":  http.StatusForbidden,
+		"error": "insufficient permissions",
+		"date":  time.Now().String(),
+	}
+	if len(route.Endpoint.Roles) > 0 {
+		body["required_roles"] = route.Endpoint.Roles
+	}
+	if len(route.Endpoint.Scopes) > 0 {
+		body["required_scopes"] = route.Endpoint.Scopes
+	}
+	response, _ := json.Marshal(body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	_, _ = w.Write(response)
+}
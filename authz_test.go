@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/savageking-io/ogbrest/proto"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthorizer_Authorize(t *testing.T) {
+	route := func(roles []string, scopes []string) *RouteEntry {
+		return &RouteEntry{Method: "GET", Pattern: "/admin", Endpoint: &proto.RestEndpoint{Roles: roles, Scopes: scopes}}
+	}
+	player := &Identity{UserId: 1, Claims: map[string][]string{"roles": {"player"}, "scope": {"profile:read chat"}}}
+	moderator := &Identity{UserId: 2, Claims: map[string][]string{"roles": {"player", "moderator"}, "permissions": {"ban"}}}
+	tests := []struct {
+		name       string
+		authorizer *Authorizer
+		identity   *Identity
+		route      *RouteEntry
+		want       bool
+	}{
+		{"No requirements", NewAuthorizer(AuthConfig{}), player, route(nil, nil), true},
+		{"Unknown route", NewAuthorizer(AuthConfig{}), nil, nil, true},
+		{"Anonymous", NewAuthorizer(AuthConfig{}), nil, route([]string{"admin"}, nil), false},
+		{"Missing role", NewAuthorizer(AuthConfig{}), player, route([]string{"admin", "moderator"}, nil), false},
+		{"One of roles", NewAuthorizer(AuthConfig{}), moderator, route([]string{"admin", "moderator"}, nil), true},
+		{"All scopes", NewAuthorizer(AuthConfig{}), player, route(nil, []string{"chat", "profile:read"}), true},
+		{"Missing scope", NewAuthorizer(AuthConfig{}), player, route(nil, []string{"chat", "profile:write"}), false},
+		{"Role and missing scope", NewAuthorizer(AuthConfig{}), player, route([]string{"player"}, []string{"profile:write"}), false},
+		{"Custom claim", NewAuthorizer(AuthConfig{ScopesClaim: "permissions"}), moderator, route(nil, []string{"ban"}), true},
+		{"Nil authorizer uses default claims", nil, moderator, route([]string{"moderator"}, nil), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.authorizer.Authorize(tt.identity, tt.route); got != tt.want {
+				t.Errorf("Authorize() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestREST_JWTMiddleware_Authorization(t *testing.T) {
+	remote := &fakeTokenValidator{valid: true, userId: 3}
+	r := &REST{remote: remote, authorizer: NewAuthorizer(AuthConfig{})}
+	r.routes = NewRouteTable(r.JWTMiddleware())
+	_ = r.routes.ReplaceServiceRoutes("user", []*RouteEntry{
+		{Method: "POST", Pattern: "/user/ban", Endpoint: &proto.RestEndpoint{Roles: []string{"admin", "moderator"}}, Handler: routeHandler(http.StatusOK)},
+		{Method: "GET", Pattern: "/user/public", Endpoint: &proto.RestEndpoint{SkipAuthMiddleware: true, Scopes: []string{"profile:read"}}, Handler: routeHandler(http.StatusOK)},
+	})
+	token := func(roles ...string) string {
+		return signToken(t, jwt.SigningMethodHS256, []byte("secret"), "", jwt.MapClaims{"sub": 3, "roles": roles, "scope": "profile:read"})
+	}
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"Player", "POST", "/user/ban", token("player"), http.StatusForbidden},
+		{"Moderator", "POST", "/user/ban", token("player", "moderator"), http.StatusOK},
+		{"Not a JWT", "POST", "/user/ban", "opaque", http.StatusForbidden},
+		{"Route with scopes requires token", "GET", "/user/public", "", http.StatusUnauthorized},
+		{"Scope", "GET", "/user/public", token(), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			r.routes.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("%s %s got = %d, want %d", tt.method, tt.path, w.Code, tt.want)
+			}
+			if w.Code == http.StatusForbidden {
+				var body struct {
+					Code          int      `json:"code"`
+					RequiredRoles []string `json:"required_roles"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Code != http.StatusForbidden || len(body.RequiredRoles) != 2 {
+					t.Errorf("403 body got = %s", w.Body.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+)
+
+// DefaultMaxBodyBytes is used when configuration doesn't limit request bodies
+const DefaultMaxBodyBytes = 10 << 20
+
+// BodyLimit returns request body limit of the route or 0 when body is not limited. Endpoints override
+// the gateway limit, while stream endpoints are only limited by their own limit
+func (r *REST) BodyLimit(route *RouteEntry) int64 {
+	limit := r.maxBodyBytes
+	if route != nil && route.Endpoint != nil {
+		if route.Endpoint.MaxBodyBytes != 0 {
+			limit = route.Endpoint.MaxBodyBytes
+		} else if route.Endpoint.Stream {
+			limit = 0
+		}
+	}
+	if limit < 0 {
+		return 0
+	}
+	return limit
+}
+
+// BodyLimitMiddleware rejects requests with bodies over the limit of the route with 413. Runs before
+// authentication, so bodies are limited before anything reads them
+func (r *REST) BodyLimitMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			limit := r.BodyLimit(routeFromContext(req.Context()))
+			if limit == 0 {
+				next.ServeHTTP(w, req)
+				return
+			}
+			if req.ContentLength > limit {
+				writeBodyTooLarge(w, req, limit)
+				return
+			}
+			// Content-Length may be missing or wrong, so body is also cut when it's read
+			req.Body = http.MaxBytesReader(w, req.Body, limit)
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// isBodyTooLarge returns true when reading the body failed because of the limit
+func isBodyTooLarge(err error) (int64, bool) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return maxBytesErr.Limit, true
+	}
+	return 0, false
+}
+
+func writeBodyTooLarge(w http.ResponseWriter, req *http.Request, limit int64) {
+	log.Warnf("Request body of %s %s from %s is over %d bytes", req.Method, req.URL.Path, req.RemoteAddr, limit)
+	writeErrorResponse(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body is over %d bytes", limit))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/savageking-io/ogbrest/kafka"
+	"github.com/savageking-io/ogbrest/proto"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestREST_BodyLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint *proto.RestEndpoint
+		body     int
+		chunked  bool // Content-Length is not known in advance
+		wantCode int
+	}{
+		{"Under gateway limit", &proto.RestEndpoint{}, 16, false, http.StatusOK},
+		{"Over gateway limit", &proto.RestEndpoint{}, 17, false, http.StatusRequestEntityTooLarge},
+		{"Over gateway limit without Content-Length", &proto.RestEndpoint{}, 17, true, http.StatusRequestEntityTooLarge},
+		{"Endpoint raises limit", &proto.RestEndpoint{MaxBodyBytes: 32}, 32, false, http.StatusOK},
+		{"Endpoint lowers limit", &proto.RestEndpoint{MaxBodyBytes: 4}, 5, true, http.StatusRequestEntityTooLarge},
+		{"Endpoint is unlimited", &proto.RestEndpoint{MaxBodyBytes: -1}, 1024, false, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &REST{maxBodyBytes: 16, kafka: new(kafka.Publisher)}
+			r.routes = NewRouteTable(r.BodyLimitMiddleware())
+			tt.endpoint.Method = "POST"
+			tt.endpoint.Path = "/upload"
+			backend := &testBackend{response: &proto.RestApiResponse{HttpCode: 200}}
+			if err := r.UpdateServiceRoutes("/test", []*proto.RestEndpoint{tt.endpoint}, backend); err != nil {
+				t.Fatal(err)
+			}
+
+			var body io.Reader = strings.NewReader(strings.Repeat("a", tt.body))
+			if tt.chunked {
+				body = io.MultiReader(body)
+			}
+			req := httptest.NewRequest("POST", "/test/upload", body)
+			req.Header.Set("Content-Type", "application/octet-stream")
+			w := httptest.NewRecorder()
+			r.routes.ServeHTTP(w, req)
+			if w.Code != tt.wantCode {
+				t.Errorf("got = %d, want %d", w.Code, tt.wantCode)
+			}
+			if tt.wantCode == http.StatusRequestEntityTooLarge {
+				var response map[string]interface{}
+				if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response["code"] != float64(http.StatusRequestEntityTooLarge) {
+					t.Errorf("body got = %s", w.Body.String())
+				}
+				if backend.calls != 0 {
+					t.Errorf("request reached the service")
+				}
+			}
+		})
+	}
+
+	r := &REST{maxBodyBytes: 16}
+	if got := r.BodyLimit(&RouteEntry{Endpoint: &proto.RestEndpoint{Stream: true}}); got != 0 {
+		t.Errorf("BodyLimit() of stream got = %d, want 0", got)
+	}
+}
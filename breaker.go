@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// BreakerState is a state of the circuit breaker
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+const (
+	DefaultBreakerConsecutiveFailures = 5
+	DefaultBreakerMinRequests         = 20
+	DefaultBreakerWindow              = 10 * time.Second
+	DefaultBreakerOpenTimeout         = 30 * time.Second
+	DefaultBreakerHalfOpenProbes      = 1
+)
+
+// ErrBreakerOpen is matched by BreakerOpenError
+var ErrBreakerOpen = errors.New("circuit breaker is open")
+
+// BreakerOpenError is returned for requests rejected by an open circuit breaker
+type BreakerOpenError struct {
+	Name       string
+	RetryAfter time.Duration // When the breaker will let requests through again
+}
+
+func (e *BreakerOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker of %s is open, retry after %s", e.Name, e.RetryAfter.String())
+}
+
+func (e *BreakerOpenError) Is(target error) bool {
+	return target == ErrBreakerOpen
+}
+
+// CircuitBreaker stops sending requests to a failing instance. It opens after a number of consecutive
+// failures or when error rate within a window is too high. After open timeout a limited number of
+// probe requests is let through: breaker closes when they succeed and opens again when any fails.
+// nil breaker lets every request through
+type CircuitBreaker struct {
+	name                string
+	config              BreakerConfig
+	mutex               sync.Mutex
+	state               BreakerState
+	openedAt            time.Time
+	consecutiveFailures int
+	windowStart         time.Time
+	requests            int
+	failures            int
+	probes              int // Probes in flight while half-open
+	probeSuccesses      int
+}
+
+func NewCircuitBreaker(name string, config BreakerConfig) *CircuitBreaker {
+	if config.ConsecutiveFailures <= 0 {
+		config.ConsecutiveFailures = DefaultBreakerConsecutiveFailures
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = DefaultBreakerMinRequests
+	}
+	if config.Window <= 0 {
+		config.Window = DefaultBreakerWindow
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = DefaultBreakerOpenTimeout
+	}
+	if config.HalfOpenProbes <= 0 {
+		config.HalfOpenProbes = DefaultBreakerHalfOpenProbes
+	}
+	return &CircuitBreaker{
+		name:   name,
+		config: config,
+		state:  BreakerClosed,
+	}
+}
+
+// State returns current state of the breaker
+func (b *CircuitBreaker) State() BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.advance(time.Now())
+	return b.state
+}
+
+// Available returns true when Allow would let a request through
+func (b *CircuitBreaker) Available() bool {
+	if b == nil {
+		return true
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.advance(time.Now())
+	return b.state == BreakerClosed || (b.state == BreakerHalfOpen && b.probes < b.config.HalfOpenProbes)
+}
+
+// Allow checks whether the request may be sent. Every allowed request must be followed by Done
+func (b *CircuitBreaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := time.Now()
+	b.advance(now)
+
+	switch b.state {
+	case BreakerOpen:
+		return &BreakerOpenError{Name: b.name, RetryAfter: b.openedAt.Add(b.config.OpenTimeout).Sub(now)}
+	case BreakerHalfOpen:
+		if b.probes >= b.config.HalfOpenProbes {
+			return &BreakerOpenError{Name: b.name, RetryAfter: time.Second}
+		}
+		b.probes++
+	}
+	return nil
+}
+
+// Done reports result of an allowed request. Requests cancelled by the client say nothing about the instance
+func (b *CircuitBreaker) Done(ctx context.Context, err error) {
+	if b == nil {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := time.Now()
+
+	if b.state == BreakerHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+	if err != nil && ctx.Err() == context.Canceled {
+		return
+	}
+	failed := isInstanceFailure(ctx, err)
+
+	switch b.state {
+	case BreakerHalfOpen:
+		if failed {
+			b.open(now, "probe failed")
+			return
+		}
+		b.probeSuccesses++
+		if b.probeSuccesses >= b.config.HalfOpenProbes {
+			b.close()
+		}
+	case BreakerClosed:
+		if now.Sub(b.windowStart) > b.config.Window {
+			b.windowStart = now
+			b.requests = 0
+			b.failures = 0
+		}
+		b.requests++
+		if !failed {
+			b.consecutiveFailures = 0
+			return
+		}
+		b.failures++
+		b.consecutiveFailures++
+		if b.consecutiveFailures >= b.config.ConsecutiveFailures {
+			b.open(now, fmt.Sprintf("%d consecutive failures", b.consecutiveFailures))
+			return
+		}
+		if b.config.ErrorRate > 0 && b.requests >= b.config.MinRequests &&
+			float64(b.failures)/float64(b.requests) >= b.config.ErrorRate {
+			b.open(now, fmt.Sprintf("%d of %d requests failed", b.failures, b.requests))
+		}
+	}
+}
+
+// advance moves open breaker to half-open once open timeout passes. Must be called with mutex locked
+func (b *CircuitBreaker) advance(now time.Time) {
+	if b.state != BreakerOpen || now.Before(b.openedAt.Add(b.config.OpenTimeout)) {
+		return
+	}
+	log.Infof("Circuit breaker of %s is half-open, probing", b.name)
+	b.state = BreakerHalfOpen
+	b.probes = 0
+	b.probeSuccesses = 0
+}
+
+func (b *CircuitBreaker) open(now time.Time, reason string) {
+	log.Warnf("Circuit breaker of %s is open for %s: %s", b.name, b.config.OpenTimeout.String(), reason)
+	b.state = BreakerOpen
+	b.openedAt = now
+}
+
+func (b *CircuitBreaker) close() {
+	log.Infof("Circuit breaker of %s is closed", b.name)
+	b.state = BreakerClosed
+	b.consecutiveFailures = 0
+	b.windowStart = time.Now()
+	b.requests = 0
+	b.failures = 0
+}
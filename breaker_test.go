@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var errUnavailable = status.Error(codes.Unavailable, "unavailable")
+
+func TestCircuitBreaker_Done(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  BreakerConfig
+		results []error
+		want    BreakerState
+	}{
+		{"Successes", BreakerConfig{}, []error{nil, nil, nil}, BreakerClosed},
+		{"Consecutive failures", BreakerConfig{ConsecutiveFailures: 3}, []error{errUnavailable, errUnavailable, errUnavailable}, BreakerOpen},
+		{"Success resets consecutive failures", BreakerConfig{ConsecutiveFailures: 3}, []error{errUnavailable, errUnavailable, nil, errUnavailable}, BreakerClosed},
+		{"Application errors", BreakerConfig{ConsecutiveFailures: 2}, []error{status.Error(codes.NotFound, ""), status.Error(codes.NotFound, "")}, BreakerClosed},
+		{"Error rate", BreakerConfig{ConsecutiveFailures: 10, ErrorRate: 0.5, MinRequests: 4}, []error{nil, errUnavailable, nil, errUnavailable}, BreakerOpen},
+		{"Error rate below min requests", BreakerConfig{ConsecutiveFailures: 10, ErrorRate: 0.5, MinRequests: 4}, []error{errUnavailable, nil, errUnavailable}, BreakerClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewCircuitBreaker("test", tt.config)
+			for _, result := range tt.results {
+				if err := b.Allow(); err != nil {
+					t.Fatalf("Allow() error = %v", err)
+				}
+				b.Done(context.Background(), result)
+			}
+			if got := b.State(); got != tt.want {
+				t.Errorf("State() got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker_HalfOpen(t *testing.T) {
+	tests := []struct {
+		name  string
+		probe error
+		want  BreakerState
+	}{
+		{"Probe succeeds", nil, BreakerClosed},
+		{"Probe fails", errUnavailable, BreakerOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewCircuitBreaker("test", BreakerConfig{ConsecutiveFailures: 1, OpenTimeout: time.Minute})
+			_ = b.Allow()
+			b.Done(context.Background(), errUnavailable)
+
+			var openErr *BreakerOpenError
+			if err := b.Allow(); !errors.As(err, &openErr) || openErr.RetryAfter <= 0 {
+				t.Fatalf("Allow() error = %v, want BreakerOpenError", err)
+			}
+
+			// Open timeout has passed
+			b.openedAt = time.Now().Add(-2 * time.Minute)
+			if got := b.State(); got != BreakerHalfOpen {
+				t.Fatalf("State() got = %s, want %s", got, BreakerHalfOpen)
+			}
+			if err := b.Allow(); err != nil {
+				t.Fatalf("Allow() probe error = %v", err)
+			}
+			if err := b.Allow(); !errors.Is(err, ErrBreakerOpen) {
+				t.Errorf("Allow() second probe error = %v, want ErrBreakerOpen", err)
+			}
+			b.Done(context.Background(), tt.probe)
+			if got := b.State(); got != tt.want {
+				t.Errorf("State() got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker_Cancelled(t *testing.T) {
+	b := NewCircuitBreaker("test", BreakerConfig{ConsecutiveFailures: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = b.Allow()
+	b.Done(ctx, status.Error(codes.Canceled, "canceled"))
+	if got := b.State(); got != BreakerClosed {
+		t.Errorf("State() got = %s, want %s", got, BreakerClosed)
+	}
+}
+
+func TestHandleBackendError_BreakerOpen(t *testing.T) {
+	pool := newTestPool(BalancerRoundRobin, 0)
+	w := httptest.NewRecorder()
+	err := &BreakerOpenError{Name: "test", RetryAfter: 1500 * time.Millisecond}
+	if !handleBackendError(w, httptest.NewRequest("GET", "/test", nil), pool, err) {
+		t.Fatalf("handleBackendError() got = false, want true")
+	}
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("code got = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After got = %s, want 2", got)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"runtime/metrics"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultBulkheadQueueTimeout = 100 * time.Millisecond
+	DefaultLoadSheddingInterval = time.Second
+	OverloadRetryAfter          = time.Second // Suggested to clients rejected because of load
+)
+
+// heapObjectsMetric is the heap size load shedding compares with the threshold
+const heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+
+// ErrBulkheadFull is returned for requests rejected because the instance has too many requests in flight
+var ErrBulkheadFull = errors.New("too many requests in flight")
+
+// ErrOverloaded is returned for requests shed because the gateway is overloaded
+var ErrOverloaded = errors.New("gateway is overloaded")
+
+// Bulkhead bounds requests in flight to a single instance, so a slow service can't tie up unlimited
+// goroutines of the gateway. Requests over the limit wait in a short queue and are rejected when it's full.
+// nil bulkhead lets every request through
+type Bulkhead struct {
+	name         string
+	slots        chan struct{}
+	maxQueue     int64
+	queueTimeout time.Duration
+	queued       atomic.Int64
+	rejected     atomic.Uint64
+}
+
+// NewBulkhead returns nil when concurrency is not limited. Queue holds as many requests as there are slots
+// unless set, negative max queue rejects requests over the limit right away
+func NewBulkhead(name string, config BulkheadConfig) *Bulkhead {
+	if config.MaxConcurrent <= 0 {
+		return nil
+	}
+	if config.MaxQueue == 0 {
+		config.MaxQueue = config.MaxConcurrent
+	}
+	if config.MaxQueue < 0 {
+		config.MaxQueue = 0
+	}
+	if config.QueueTimeout <= 0 {
+		config.QueueTimeout = DefaultBulkheadQueueTimeout
+	}
+	return &Bulkhead{
+		name:         name,
+		slots:        make(chan struct{}, config.MaxConcurrent),
+		maxQueue:     int64(config.MaxQueue),
+		queueTimeout: config.QueueTimeout,
+	}
+}
+
+// Acquire takes a slot for the request, waiting in the queue when all the slots are taken.
+// Release must be called once the request is over
+func (b *Bulkhead) Acquire(ctx context.Context) (release func(), err error) {
+	if b == nil {
+		return func() {}, nil
+	}
+	release = func() { <-b.slots }
+	select {
+	case b.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	if b.queued.Add(1) > b.maxQueue {
+		b.queued.Add(-1)
+		return nil, b.reject("queue is full")
+	}
+	defer b.queued.Add(-1)
+	timer := time.NewTimer(b.queueTimeout)
+	defer timer.Stop()
+	select {
+	case b.slots <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, b.reject(fmt.Sprintf("no slot within %s", b.queueTimeout.String()))
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (b *Bulkhead) reject(reason string) error {
+	b.rejected.Add(1)
+	log.Debugf("Bulkhead of %s rejected request: %s", b.name, reason)
+	return fmt.Errorf("%w for %s: %s", ErrBulkheadFull, b.name, reason)
+}
+
+// BulkheadStatus describes requests of the instance
+type BulkheadStatus struct {
+	InFlight int    `json:"in_flight"`
+	Queued   int    `json:"queued"`
+	Rejected uint64 `json:"rejected"`
+}
+
+// Status returns nil when concurrency is not limited
+func (b *Bulkhead) Status() *BulkheadStatus {
+	if b == nil {
+		return nil
+	}
+	return &BulkheadStatus{
+		InFlight: len(b.slots),
+		Queued:   int(b.queued.Load()),
+		Rejected: b.rejected.Load(),
+	}
+}
+
+// LoadStatus describes load of the gateway
+type LoadStatus struct {
+	InFlight       int64  `json:"in_flight"`
+	MaxInFlight    int64  `json:"max_in_flight,omitempty"`
+	HeapBytes      uint64 `json:"heap_bytes"`
+	MaxHeapBytes   uint64 `json:"max_heap_bytes,omitempty"`
+	Shed           uint64 `json:"shed"`
+	Overloaded     bool   `json:"overloaded"`
+	OverloadReason string `json:"overload_reason,omitempty"`
+}
+
+// LoadShedder rejects requests to services while total requests in flight or heap size of the
+// gateway are over the thresholds, so a single slow backend can't take down all the routes.
+// Heap is sampled at most once per interval
+type LoadShedder struct {
+	maxInFlight  int64
+	maxHeapBytes uint64
+	interval     time.Duration
+	inFlight     atomic.Int64
+	heapBytes    atomic.Uint64
+	sampledAt    atomic.Int64 // Unix nanoseconds of the last heap sample
+	shed         atomic.Uint64
+}
+
+func NewLoadShedder(config LoadSheddingConfig) *LoadShedder {
+	s := &LoadShedder{
+		maxInFlight:  int64(config.MaxInFlight),
+		maxHeapBytes: config.MaxHeapBytes,
+		interval:     config.Interval,
+	}
+	if s.interval <= 0 {
+		s.interval = DefaultLoadSheddingInterval
+	}
+	return s
+}
+
+// Acquire counts the request as in flight unless the gateway is overloaded.
+// Release must be called once the request is over
+func (s *LoadShedder) Acquire() (release func(), err error) {
+	if s == nil {
+		return func() {}, nil
+	}
+	inFlight := s.inFlight.Add(1)
+	release = func() { s.inFlight.Add(-1) }
+	if reason := s.overloaded(inFlight); reason != "" {
+		release()
+		s.shed.Add(1)
+		return nil, fmt.Errorf("%w: %s", ErrOverloaded, reason)
+	}
+	return release, nil
+}
+
+// overloaded returns the reason why the gateway is overloaded or empty string if it's not
+func (s *LoadShedder) overloaded(inFlight int64) string {
+	if s.maxInFlight > 0 && inFlight > s.maxInFlight {
+		return fmt.Sprintf("over %d requests in flight", s.maxInFlight)
+	}
+	if s.maxHeapBytes > 0 {
+		if heap := s.heap(time.Now()); heap > s.maxHeapBytes {
+			return fmt.Sprintf("heap is %d bytes", heap)
+		}
+	}
+	return ""
+}
+
+// heap returns size of heap objects, sampling it when the previous sample is too old
+func (s *LoadShedder) heap(now time.Time) uint64 {
+	sampledAt := s.sampledAt.Load()
+	if now.UnixNano()-sampledAt < s.interval.Nanoseconds() || !s.sampledAt.CompareAndSwap(sampledAt, now.UnixNano()) {
+		return s.heapBytes.Load()
+	}
+	sample := []metrics.Sample{{Name: heapObjectsMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() == metrics.KindUint64 {
+		s.heapBytes.Store(sample[0].Value.Uint64())
+	}
+	return s.heapBytes.Load()
+}
+
+// Status returns a snapshot of the gateway load
+func (s *LoadShedder) Status() LoadStatus {
+	if s == nil {
+		return LoadStatus{}
+	}
+	inFlight := s.inFlight.Load()
+	status := LoadStatus{
+		InFlight:     inFlight,
+		MaxInFlight:  s.maxInFlight,
+		HeapBytes:    s.heap(time.Now()),
+		MaxHeapBytes: s.maxHeapBytes,
+		Shed:         s.shed.Load(),
+	}
+	status.OverloadReason = s.overloaded(inFlight + 1)
+	status.Overloaded = status.OverloadReason != ""
+	return status
+}
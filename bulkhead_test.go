@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/savageking-io/ogbrest/kafka"
+	"github.com/savageking-io/ogbrest/proto"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBulkhead_Acquire(t *testing.T) {
+	b := NewBulkhead("test", BulkheadConfig{MaxConcurrent: 1, MaxQueue: 1, QueueTimeout: time.Second})
+	release, err := b.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	// Second request waits in the queue until the slot is released
+	acquired := make(chan error, 1)
+	go func() {
+		release, err := b.Acquire(context.Background())
+		if err == nil {
+			release()
+		}
+		acquired <- err
+	}()
+	for b.Status().Queued == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Queue is full
+	if _, err := b.Acquire(context.Background()); !errors.Is(err, ErrBulkheadFull) {
+		t.Errorf("Acquire() error = %v, want %v", err, ErrBulkheadFull)
+	}
+
+	release()
+	if err := <-acquired; err != nil {
+		t.Errorf("queued Acquire() error = %v", err)
+	}
+	if status := b.Status(); status.InFlight != 0 || status.Queued != 0 || status.Rejected != 1 {
+		t.Errorf("Status() got = %+v", status)
+	}
+
+	// Queued request gives up after the timeout
+	b = NewBulkhead("test", BulkheadConfig{MaxConcurrent: 1, MaxQueue: 1, QueueTimeout: time.Millisecond})
+	release, _ = b.Acquire(context.Background())
+	defer release()
+	if _, err := b.Acquire(context.Background()); !errors.Is(err, ErrBulkheadFull) {
+		t.Errorf("Acquire() error = %v, want %v", err, ErrBulkheadFull)
+	}
+
+	if release, err := (*Bulkhead)(nil).Acquire(context.Background()); err != nil || release == nil {
+		t.Errorf("nil Acquire() error = %v", err)
+	}
+}
+
+func TestNewBulkhead_MaxQueue(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxQueue int
+		want     int64
+	}{
+		{"Default", 0, 2},
+		{"Set", 5, 5},
+		{"No queue", -1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBulkhead("test", BulkheadConfig{MaxConcurrent: 2, MaxQueue: tt.maxQueue})
+			if b.maxQueue != tt.want {
+				t.Errorf("NewBulkhead() max queue got = %d, want %d", b.maxQueue, tt.want)
+			}
+		})
+	}
+}
+
+func TestREST_proxyHandlerLoadShedding(t *testing.T) {
+	r := &REST{shedder: NewLoadShedder(LoadSheddingConfig{MaxInFlight: 1}), kafka: new(kafka.Publisher)}
+	release, err := r.shedder.Acquire()
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	backend := &testBackend{response: &proto.RestApiResponse{HttpCode: 200}}
+	handler := r.proxyHandler(&proto.RestEndpoint{Method: "GET", Path: "/"}, backend)
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/test", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" || backend.calls != 0 {
+		t.Errorf("overloaded got = %d, calls %d", w.Code, backend.calls)
+	}
+	if status := r.shedder.Status(); status.Shed != 1 || !status.Overloaded {
+		t.Errorf("Status() got = %+v", status)
+	}
+
+	release()
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/test", nil))
+	if w.Code != http.StatusOK || backend.calls != 1 {
+		t.Errorf("got = %d, calls %d", w.Code, backend.calls)
+	}
+	if got := r.shedder.Status().InFlight; got != 0 {
+		t.Errorf("in flight got = %d, want 0", got)
+	}
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/savageking-io/ogbrest/proto"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultCacheMaxEntries    = 10000
+	DefaultCacheMaxBytes      = 64 << 20
+	DefaultCacheMaxEntryBytes = 1 << 20
+)
+
+const (
+	CacheKeyPath  = "path"
+	CacheKeyQuery = "query"
+	CacheKeyUser  = "user"
+)
+
+// cacheEntry is a response stored by ResponseCache
+type cacheEntry struct {
+	key       string
+	label     string // Service that produced the response
+	path      string
+	response  *proto.RestApiResponse
+	storedAt  time.Time
+	expiresAt time.Time
+	size      int64
+}
+
+// CacheStatus describes usage of the response cache
+type CacheStatus struct {
+	Enabled bool   `json:"enabled"`
+	Entries int    `json:"entries"`
+	Bytes   int64  `json:"bytes"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+}
+
+// ResponseCache keeps responses to GET requests in memory for as long as Cache-Control or Expires
+// headers of the response allow. Least recently used entries are evicted when the cache is full.
+// nil cache stores nothing
+type ResponseCache struct {
+	mutex         sync.Mutex
+	maxEntries    int
+	maxBytes      int64
+	maxEntryBytes int64
+	keyQuery      bool
+	keyUser       bool
+	entries       map[string]*list.Element
+	lru           *list.List // Front is the most recently used entry
+	size          int64
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+}
+
+// NewResponseCache returns nil when cache is disabled
+func NewResponseCache(config CacheConfig) (*ResponseCache, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+	c := &ResponseCache{
+		maxEntries:    config.MaxEntries,
+		maxBytes:      config.MaxBytes,
+		maxEntryBytes: config.MaxEntryBytes,
+		entries:       make(map[string]*list.Element),
+		lru:           list.New(),
+	}
+	if c.maxEntries <= 0 {
+		c.maxEntries = DefaultCacheMaxEntries
+	}
+	if c.maxBytes <= 0 {
+		c.maxBytes = DefaultCacheMaxBytes
+	}
+	if c.maxEntryBytes <= 0 {
+		c.maxEntryBytes = DefaultCacheMaxEntryBytes
+	}
+	key := config.Key
+	if len(key) == 0 {
+		key = []string{CacheKeyPath, CacheKeyQuery}
+	}
+	for _, part := range key {
+		switch strings.ToLower(part) {
+		case CacheKeyPath:
+		case CacheKeyQuery:
+			c.keyQuery = true
+		case CacheKeyUser:
+			c.keyUser = true
+		default:
+			return nil, fmt.Errorf("unknown cache key part %s", part)
+		}
+	}
+	log.Infof("Response cache enabled: %d entries, %d bytes", c.maxEntries, c.maxBytes)
+	return c, nil
+}
+
+// Key returns cache key of the request. Path is the one requests are routed by, so requests of different
+// routes never share entries. Private keys include the user or the API key and are only built for
+// authenticated requests
+func (c *ResponseCache) Key(label string, req *http.Request, private bool) (string, bool) {
+	identity := identityFromContext(req.Context())
+	key := fmt.Sprintf("%s|%s|%s", label, req.Method, routingPath(req))
+	if c.keyQuery {
+		key += "?" + req.URL.RawQuery
+	}
+	if !private && !c.keyUser {
+		return key, true
+	}
+	if identity == nil {
+		// Anonymous requests share entries, but can't have private ones
+		return key, !private
+	}
+	return key + "|" + identity.subject(), true
+}
+
+// Get returns fresh response stored under the key and time it was stored
+func (c *ResponseCache) Get(key string) (*proto.RestApiResponse, time.Time, bool) {
+	if c == nil {
+		return nil, time.Time{}, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if !time.Now().Before(entry.expiresAt) {
+		c.remove(element)
+		return nil, time.Time{}, false
+	}
+	c.lru.MoveToFront(element)
+	return entry.response, entry.storedAt, true
+}
+
+// Lookup finds response to the request, preferring an entry private to the user over a shared one
+func (c *ResponseCache) Lookup(label string, req *http.Request) (*proto.RestApiResponse, time.Time, bool) {
+	if c == nil {
+		return nil, time.Time{}, false
+	}
+	if key, ok := c.Key(label, req, true); ok {
+		if response, storedAt, found := c.Get(key); found {
+			c.hits.Add(1)
+			return response, storedAt, true
+		}
+	}
+	if key, ok := c.Key(label, req, false); ok {
+		if response, storedAt, found := c.Get(key); found {
+			c.hits.Add(1)
+			return response, storedAt, true
+		}
+	}
+	c.misses.Add(1)
+	return nil, time.Time{}, false
+}
+
+// Store keeps the response when its headers allow caching. Responses to authenticated requests are
+// private to the user unless marked public
+func (c *ResponseCache) Store(label string, req *http.Request, response *proto.RestApiResponse) bool {
+	if c == nil || response == nil {
+		return false
+	}
+	ttl, private, ok := cachePolicy(response, time.Now())
+	if !ok {
+		return false
+	}
+	if identityFromContext(req.Context()) != nil && !isPublicResponse(response) {
+		private = true
+	}
+	key, ok := c.Key(label, req, private)
+	if !ok {
+		return false
+	}
+	size := responseSize(response)
+	if size > c.maxEntryBytes {
+		log.Debugf("Response to %s %s is too large to cache: %d bytes", req.Method, req.URL.Path, size)
+		return false
+	}
+
+	now := time.Now()
+	entry := &cacheEntry{
+		key:       key,
+		label:     label,
+		path:      req.URL.Path,
+		response:  response,
+		storedAt:  now,
+		expiresAt: now.Add(ttl),
+		size:      size,
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.size += size
+	for len(c.entries) > c.maxEntries || c.size > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+	log.Debugf("Cached response to %s %s of [%s] for %s", req.Method, req.URL.Path, label, ttl.String())
+	return true
+}
+
+// Purge removes entries of the service with the given paths or path prefixes, or all of them.
+// Returns number of removed entries
+func (c *ResponseCache) Purge(label string, paths []string, prefixes []string, all bool) int {
+	if c == nil {
+		return 0
+	}
+	exact := make(map[string]bool)
+	for _, path := range paths {
+		exact[path] = true
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	removed := 0
+	for element := c.lru.Front(); element != nil; {
+		next := element.Next()
+		entry := element.Value.(*cacheEntry)
+		if entry.label == label && (all || exact[entry.path] || hasAnyPrefix(entry.path, prefixes)) {
+			c.remove(element)
+			removed++
+		}
+		element = next
+	}
+	return removed
+}
+
+// Status returns a snapshot of cache usage
+func (c *ResponseCache) Status() CacheStatus {
+	if c == nil {
+		return CacheStatus{}
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return CacheStatus{
+		Enabled: true,
+		Entries: len(c.entries),
+		Bytes:   c.size,
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+	}
+}
+
+// remove deletes the entry. Must be called with mutex locked
+func (c *ResponseCache) remove(element *list.Element) {
+	entry := element.Value.(*cacheEntry)
+	c.lru.Remove(element)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
+
+func hasAnyPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// cachePolicy returns how long the response may be cached and whether it's private to the user.
+// Only successful responses with explicit freshness are cached
+func cachePolicy(response *proto.RestApiResponse, now time.Time) (time.Duration, bool, bool) {
+	if response.HttpCode != 0 && response.HttpCode != http.StatusOK {
+		return 0, false, false
+	}
+	if responseHeader(response, "Set-Cookie") != "" {
+		return 0, false, false
+	}
+	if vary := responseHeader(response, "Vary"); vary != "" && !strings.EqualFold(vary, "Accept-Encoding") {
+		return 0, false, false
+	}
+
+	directives := parseCacheControl(responseHeader(response, "Cache-Control"))
+	if _, ok := directives["no-store"]; ok {
+		return 0, false, false
+	}
+	if _, ok := directives["no-cache"]; ok {
+		return 0, false, false
+	}
+	_, private := directives["private"]
+
+	var ttl time.Duration
+	if value, ok := directives["s-maxage"]; ok && !private {
+		seconds, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, false, false
+		}
+		ttl = time.Duration(seconds) * time.Second
+	} else if value, ok := directives["max-age"]; ok {
+		seconds, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, false, false
+		}
+		ttl = time.Duration(seconds) * time.Second
+	} else if expires := responseHeader(response, "Expires"); expires != "" {
+		at, err := http.ParseTime(expires)
+		if err != nil {
+			return 0, false, false
+		}
+		ttl = at.Sub(now)
+	}
+	if ttl <= 0 {
+		return 0, false, false
+	}
+	return ttl, private, true
+}
+
+// isPublicResponse returns true when the response may be shared between users
+func isPublicResponse(response *proto.RestApiResponse) bool {
+	directives := parseCacheControl(responseHeader(response, "Cache-Control"))
+	_, public := directives["public"]
+	_, shared := directives["s-maxage"]
+	return public || shared
+}
+
+// parseCacheControl returns directives by lowercase name with their values
+func parseCacheControl(value string) map[string]string {
+	result := make(map[string]string)
+	for _, directive := range strings.Split(value, ",") {
+		directive = strings.TrimSpace(directive)
+		if directive == "" {
+			continue
+		}
+		name, argument, _ := strings.Cut(directive, "=")
+		result[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(argument), `"`)
+	}
+	return result
+}
+
+// responseHeader returns the first value of the header
+func responseHeader(response *proto.RestApiResponse, key string) string {
+	for _, header := range response.Headers {
+		if !strings.EqualFold(header.Key, key) {
+			continue
+		}
+		if len(header.Values) > 0 {
+			return header.Values[0]
+		}
+		return header.Value
+	}
+	return ""
+}
+
+func responseBody(response *proto.RestApiResponse) []byte {
+	if len(response.RawBody) > 0 {
+		return response.RawBody
+	}
+	return []byte(response.Body)
+}
+
+func responseSize(response *proto.RestApiResponse) int64 {
+	size := int64(len(response.RawBody) + len(response.Body) + len(response.ContentType))
+	for _, header := range response.Headers {
+		size += int64(len(header.Key) + len(header.Value))
+		for _, value := range header.Values {
+			size += int64(len(value))
+		}
+	}
+	return size
+}
+
+// withETag makes sure successful response carries an ETag. Gateway generates one from the body
+// when the service didn't provide it
+func withETag(response *proto.RestApiResponse) string {
+	if etag := responseHeader(response, "ETag"); etag != "" {
+		return etag
+	}
+	sum := sha256.Sum256(responseBody(response))
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	response.Headers = append(response.Headers, &proto.RestHeader{Key: "ETag", Value: etag})
+	return etag
+}
+
+// etagMatches checks If-None-Match header against the ETag using weak comparison
+func etagMatches(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
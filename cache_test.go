@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"github.com/savageking-io/ogbrest/kafka"
+	"github.com/savageking-io/ogbrest/proto"
+	protobuf "google.golang.org/protobuf/proto"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testBackend answers every request with a copy of the response and counts calls
+type testBackend struct {
+	response *proto.RestApiResponse
+	calls    int
+}
+
+func (b *testBackend) ServiceLabel() string { return "test" }
+func (b *testBackend) RequestContext(parent context.Context, endpoint *proto.RestEndpoint) (context.Context, context.CancelFunc) {
+	return context.WithCancel(parent)
+}
+func (b *testBackend) HandleRestRequest(ctx context.Context, endpoint *proto.RestEndpoint, request *proto.RestApiRequest) (*proto.RestApiResponse, error) {
+	b.calls++
+	return protobuf.Clone(b.response).(*proto.RestApiResponse), nil
+}
+func (b *testBackend) NewRestStream(ctx context.Context, head *proto.RestApiRequest) (proto.RestInterService_NewRestStreamRequestClient, error) {
+	return nil, ErrClientNotConnected
+}
+
+func cacheControl(value string) []*proto.RestHeader {
+	return []*proto.RestHeader{{Key: "Cache-Control", Value: value}}
+}
+
+func TestCachePolicy(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	tests := []struct {
+		name        string
+		response    *proto.RestApiResponse
+		wantTTL     time.Duration
+		wantPrivate bool
+		wantOk      bool
+	}{
+		{"No headers", &proto.RestApiResponse{HttpCode: 200}, 0, false, false},
+		{"Max age", &proto.RestApiResponse{HttpCode: 200, Headers: cacheControl("max-age=60")}, time.Minute, false, true},
+		{"Shared max age", &proto.RestApiResponse{Headers: cacheControl("max-age=60, s-maxage=10")}, 10 * time.Second, false, true},
+		{"Private", &proto.RestApiResponse{Headers: cacheControl("private, max-age=60")}, time.Minute, true, true},
+		{"No store", &proto.RestApiResponse{Headers: cacheControl("no-store, max-age=60")}, 0, false, false},
+		{"No cache", &proto.RestApiResponse{Headers: cacheControl("no-cache")}, 0, false, false},
+		{"Error", &proto.RestApiResponse{HttpCode: 500, Headers: cacheControl("max-age=60")}, 0, false, false},
+		{"Set-Cookie", &proto.RestApiResponse{Headers: append(cacheControl("max-age=60"), &proto.RestHeader{Key: "Set-Cookie", Value: "a=1"})}, 0, false, false},
+		{"Expires", &proto.RestApiResponse{Headers: []*proto.RestHeader{{Key: "Expires", Value: now.Add(time.Hour).UTC().Format(http.TimeFormat)}}}, time.Hour, false, true},
+		{"Expired", &proto.RestApiResponse{Headers: []*proto.RestHeader{{Key: "Expires", Value: now.Add(-time.Hour).UTC().Format(http.TimeFormat)}}}, 0, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ttl, private, ok := cachePolicy(tt.response, now)
+			if ok != tt.wantOk || private != tt.wantPrivate || ttl.Round(time.Second) != tt.wantTTL {
+				t.Errorf("cachePolicy() got = %s, %v, %v, want %s, %v, %v", ttl, private, ok, tt.wantTTL, tt.wantPrivate, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	c, err := NewResponseCache(CacheConfig{Enabled: true, MaxEntries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	public := &proto.RestApiResponse{Headers: cacheControl("max-age=60"), Body: "public"}
+	anonymous := func(target string) *http.Request {
+		return httptest.NewRequest("GET", target, nil)
+	}
+	user := func(target string, id int32) *http.Request {
+		req := httptest.NewRequest("GET", target, nil)
+		return req.WithContext(withIdentity(req.Context(), &Identity{UserId: id}))
+	}
+
+	c.Store("news", anonymous("/news/1"), public)
+	if _, _, ok := c.Lookup("news", anonymous("/news/1?page=2")); ok {
+		t.Errorf("Lookup() found entry with different query")
+	}
+	if _, _, ok := c.Lookup("news", user("/news/1", 1)); !ok {
+		t.Errorf("Lookup() shared entry not found for user")
+	}
+
+	// Responses to authenticated requests are private unless marked public
+	c.Store("news", user("/news/2", 1), &proto.RestApiResponse{Headers: cacheControl("max-age=60"), Body: "user 1"})
+	if _, _, ok := c.Lookup("news", user("/news/2", 2)); ok {
+		t.Errorf("Lookup() private entry found for another user")
+	}
+	if response, _, ok := c.Lookup("news", user("/news/2", 1)); !ok || response.Body != "user 1" {
+		t.Errorf("Lookup() private entry got = %v, %v", response, ok)
+	}
+
+	// Least recently used entry is evicted
+	c.Store("news", anonymous("/news/3"), public)
+	if _, _, ok := c.Lookup("news", anonymous("/news/1")); ok {
+		t.Errorf("Lookup() least recently used entry was not evicted")
+	}
+	if got := c.Status().Entries; got != 2 {
+		t.Errorf("entries got = %d, want 2", got)
+	}
+
+	if removed := c.Purge("news", nil, []string{"/news/"}, false); removed != 2 {
+		t.Errorf("Purge() got = %d, want 2", removed)
+	}
+
+	// Encoded slash is routed apart from the path it decodes to, e.g. to /news/{id} instead of /news/a/b
+	c.Store("news", anonymous("/news/a/b"), public)
+	if _, _, ok := c.Lookup("news", anonymous("/news/a%2Fb")); ok {
+		t.Errorf("Lookup() found entry of another route for encoded path")
+	}
+}
+
+func TestREST_proxyHandlerCache_APIKeys(t *testing.T) {
+	cache, err := NewResponseCache(CacheConfig{Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := NewAPIKeys(AuthConfig{APIKeys: APIKeysConfig{Keys: []APIKeyConfig{
+		{Name: "matchmaking", Hash: HashAPIKey("server-key")},
+		{Name: "stats", Hash: HashAPIKey("stats-key")},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &REST{cache: cache, apiKeys: keys, kafka: new(kafka.Publisher)}
+	// Service answers every key with its own private response
+	backend := &testBackend{response: &proto.RestApiResponse{HttpCode: 200, Headers: cacheControl("private, max-age=60")}}
+	r.routes = NewRouteTable(r.JWTMiddleware())
+	_ = r.routes.ReplaceServiceRoutes("match", []*RouteEntry{
+		{Method: "GET", Pattern: "/match/config", Handler: func(w http.ResponseWriter, req *http.Request) {
+			backend.response.Body = identityFromContext(req.Context()).APIKey
+			r.proxyHandler(&proto.RestEndpoint{Method: "GET", Path: "/config"}, backend)(w, req)
+		}},
+	})
+
+	steps := []struct {
+		name      string
+		key       string
+		wantBody  string
+		wantCache string
+	}{
+		{"First key", "server-key", "matchmaking", "MISS"},
+		{"Second key", "stats-key", "stats", "MISS"},
+		{"First key again", "server-key", "matchmaking", "HIT"},
+		{"Second key again", "stats-key", "stats", "HIT"},
+	}
+	for _, step := range steps {
+		req := httptest.NewRequest("GET", "/match/config", nil)
+		req.Header.Set(DefaultAPIKeyHeader, step.key)
+		w := httptest.NewRecorder()
+		r.routes.ServeHTTP(w, req)
+		if w.Body.String() != step.wantBody || w.Header().Get("X-Cache") != step.wantCache {
+			t.Errorf("%s: got = %s, %s, want %s, %s", step.name, w.Body.String(), w.Header().Get("X-Cache"), step.wantBody, step.wantCache)
+		}
+	}
+	if backend.calls != 2 {
+		t.Errorf("service calls got = %d, want 2", backend.calls)
+	}
+}
+
+func TestREST_proxyHandlerCache(t *testing.T) {
+	cache, err := NewResponseCache(CacheConfig{Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &REST{cache: cache, kafka: new(kafka.Publisher)}
+	backend := &testBackend{response: &proto.RestApiResponse{HttpCode: 200, Headers: cacheControl("max-age=60"), Body: "catalog"}}
+	handler := r.proxyHandler(&proto.RestEndpoint{Method: "GET", Path: "/"}, backend)
+
+	steps := []struct {
+		name        string
+		ifNoneMatch string
+		wantCode    int
+		wantCache   string
+		wantCalls   int
+	}{
+		{"Miss", "", http.StatusOK, "MISS", 1},
+		{"Hit", "", http.StatusOK, "HIT", 1},
+		{"Not modified", "etag", http.StatusNotModified, "HIT", 1},
+	}
+	etag := ""
+	for _, step := range steps {
+		req := httptest.NewRequest("GET", "/catalog", nil)
+		if step.ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != step.wantCode || w.Header().Get("X-Cache") != step.wantCache || backend.calls != step.wantCalls {
+			t.Errorf("%s: got code %d, X-Cache %s, calls %d", step.name, w.Code, w.Header().Get("X-Cache"), backend.calls)
+		}
+		etag = w.Header().Get("ETag")
+		if etag == "" {
+			t.Errorf("%s: ETag is not set", step.name)
+		}
+	}
+
+	purge := &proto.GatewayEvent{Payload: &proto.GatewayEvent_CachePurge{CachePurge: &proto.CachePurge{Paths: []string{"/"}}}}
+	r.HandleGatewayEvent("test", "/catalog", purge)
+	handler(httptest.NewRecorder(), httptest.NewRequest("GET", "/catalog", nil))
+	if backend.calls != 2 {
+		t.Errorf("calls after purge got = %d, want 2", backend.calls)
+	}
+}
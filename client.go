@@ -7,29 +7,97 @@ import (
 	"github.com/savageking-io/ogbrest/proto"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"sync"
 	"time"
 )
 
-// RegisterNewRouteHandler A handle from REST to add new routes
-type RegisterNewRouteHandler func(root, method, uri string, client *Client) error
+// DefaultRequestTimeout is used when neither service configuration nor endpoint define a timeout
+const DefaultRequestTimeout = 30 * time.Second
 
-// AddRouteToIgnoreListHandler A handle from REST to add path to auth ignore list
-type AddRouteToIgnoreListHandler func(uri string)
+// ErrClientNotConnected is returned for requests to a client without connection to the service
+var ErrClientNotConnected = errors.New("connection is not initialized")
 
+// UpdateRoutesHandler A handle to replace routes of the service with ones received by the client
+type UpdateRoutesHandler func(root string, endpoints []*proto.RestEndpoint, client *Client) error
+
+// RemoveRoutesHandler A handle from REST to remove all routes of the service
+type RemoveRoutesHandler func(label string)
+
+// GatewayEventHandler A handle to apply events sent by the service to the gateway
+type GatewayEventHandler func(event *proto.GatewayEvent, client *Client)
+
+// ClientState describes where the client is in its lifecycle
+type ClientState string
+
+const (
+	ClientStateDisconnected   ClientState = "disconnected"
+	ClientStateConnecting     ClientState = "connecting"
+	ClientStateAuthenticating ClientState = "authenticating"
+	ClientStateFetchingRoutes ClientState = "fetching_routes"
+	ClientStateReady          ClientState = "ready"
+	ClientStateDegraded       ClientState = "degraded" // Connection was ready before and is being restored. Routes are kept, requests fail fast
+	ClientStateStopped        ClientState = "stopped"
+)
+
+const (
+	DefaultReconnectInitialBackoff = time.Second
+	DefaultReconnectMaxBackoff     = 30 * time.Second
+	DefaultConnectTimeout          = 10 * time.Second // Deadline of every call made while connecting
+)
+
+// ClientStatus is a snapshot of the client used for diagnostics
+type ClientStatus struct {
+	Label       string          `json:"label"`
+	Host        string          `json:"host"`
+	Port        uint16          `json:"port"`
+	State       ClientState     `json:"state"`
+	ServiceId   uint16          `json:"service_id"`
+	LastError   string          `json:"last_error,omitempty"`
+	LastErrorAt *time.Time      `json:"last_error_at,omitempty"`
+	Reconnects  int             `json:"reconnects"`
+	Breaker     BreakerState    `json:"breaker"`
+	LastPingAt  *time.Time      `json:"last_ping_at,omitempty"`
+	PingRTTMs   float64         `json:"ping_rtt_ms"` // Round trip time of the last successful ping
+	PingMisses  int             `json:"ping_misses"` // Pings failed in a row
+	Bulkhead    *BulkheadStatus `json:"bulkhead,omitempty"`
+}
+
+// Client is a connection to a single instance of the service. Connection is owned by a supervisor
+// goroutine started with Start: it goes through the start sequence, monitors health of the ready
+// service, reconnects with exponential backoff when a step fails and restarts the sequence when
+// ScheduleRestart is called or the service becomes unhealthy
 type Client struct {
-	Label                       string // Unique label of the service to help developers identify it
-	Host                        string
-	Port                        uint16
-	Token                       string
-	ServiceId                   uint16 // ServiceId provided by the client during the authentication step
-	conn                        *grpc.ClientConn
-	client                      proto.RestInterServiceClient
-	registerNewRouteHandler     RegisterNewRouteHandler
-	addRouteToIgnoreListHandler AddRouteToIgnoreListHandler
-}
-
-func (c *Client) Init(config *ServiceConfig, routeRegistrationHandler RegisterNewRouteHandler, addRouteToIgnoreListHandler AddRouteToIgnoreListHandler) error {
+	Label               string // Unique label of the service to help developers identify it
+	Host                string
+	Port                uint16
+	Token               string
+	ServiceId           uint16 // ServiceId provided by the client during the authentication step
+	connMutex           sync.RWMutex
+	conn                *grpc.ClientConn
+	client              proto.RestInterServiceClient
+	updateRoutesHandler UpdateRoutesHandler
+	eventHandler        GatewayEventHandler
+	reconnect           ReconnectConfig
+	health              HealthConfig
+	breaker             *CircuitBreaker
+	bulkhead            *Bulkhead
+	supervisorMutex     sync.Mutex
+	cancel              context.CancelFunc // Stops the supervisor. nil when supervisor is not running
+	restart             chan struct{}      // Wakes the supervisor up to restart the start sequence
+	statusMutex         sync.RWMutex
+	state               ClientState
+	lastError           error
+	lastErrorAt         time.Time
+	reconnects          int
+	lastPingAt          time.Time
+	pingRTT             time.Duration
+	pingMisses          int
+}
+
+func (c *Client) Init(config *ServiceConfig, updateRoutesHandler UpdateRoutesHandler) error {
 	log.Traceln("Client::Init")
 	if config == nil {
 		return fmt.Errorf("no service configuration provided")
@@ -39,95 +107,230 @@ func (c *Client) Init(config *ServiceConfig, routeRegistrationHandler RegisterNe
 	c.Host = config.Hostname
 	c.Port = config.Port
 	c.Token = config.Token
-	c.registerNewRouteHandler = routeRegistrationHandler
-	c.addRouteToIgnoreListHandler = addRouteToIgnoreListHandler
+	c.updateRoutesHandler = updateRoutesHandler
+	c.reconnect = config.Reconnect
+	if c.reconnect.InitialBackoff <= 0 {
+		c.reconnect.InitialBackoff = DefaultReconnectInitialBackoff
+	}
+	if c.reconnect.MaxBackoff <= 0 {
+		c.reconnect.MaxBackoff = DefaultReconnectMaxBackoff
+	}
+	c.health = config.Health
+	if c.health.Interval <= 0 {
+		c.health.Interval = DefaultHealthInterval
+	}
+	if c.health.Timeout <= 0 {
+		c.health.Timeout = DefaultHealthTimeout
+	}
+	if c.health.MaxMisses <= 0 {
+		c.health.MaxMisses = DefaultHealthMaxMisses
+	}
+	c.breaker = NewCircuitBreaker(fmt.Sprintf("[%s] %s:%d", c.Label, c.Host, c.Port), config.Breaker)
+	c.bulkhead = NewBulkhead(fmt.Sprintf("[%s] %s:%d", c.Label, c.Host, c.Port), config.Bulkhead)
 	return nil
 }
 
+// Start launches the supervisor and waits for the first start sequence. When it fails the error
+// is returned and the supervisor keeps reconnecting. Starting a running client does nothing
 func (c *Client) Start() error {
 	log.Traceln("Client::Start")
-	var err error
-	log.Infof("Connecing client [%s] to %s:%d", c.Label, c.Host, c.Port)
-	c.conn, err = grpc.NewClient(fmt.Sprintf("%s:%d", c.Host, c.Port), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	c.supervisorMutex.Lock()
+	if c.cancel != nil {
+		c.supervisorMutex.Unlock()
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.restart = make(chan struct{}, 1)
+	restart := c.restart
+	c.supervisorMutex.Unlock()
+
+	c.setState(ClientStateDisconnected)
+	started := make(chan error, 1)
+	go c.supervise(ctx, restart, started)
+	return <-started
+}
+
+// ScheduleRestart asks the supervisor to run the start sequence again. Requests made while a
+// restart is pending are merged into one
+func (c *Client) ScheduleRestart() {
+	log.Traceln("Client::ScheduleRestart")
+	c.supervisorMutex.Lock()
+	defer c.supervisorMutex.Unlock()
+	if c.cancel == nil {
+		log.Debugf("Client [%s] is not running, restart ignored", c.Label)
+		return
+	}
+	select {
+	case c.restart <- struct{}{}:
+		log.Infof("Restart of client [%s] scheduled", c.Label)
+	default:
+	}
+}
+
+// supervise runs the start sequence until it succeeds, waiting longer after every failed attempt.
+// Once the client is ready, it monitors the service until it becomes unhealthy or a restart is requested.
+// Result of the first attempt is sent to started
+func (c *Client) supervise(ctx context.Context, restart <-chan struct{}, started chan<- error) {
+	log.Traceln("Client::supervise")
+	attempt := 0
+	wasReady := false
+	for {
+		// Restarts requested before this attempt are served by it
+		select {
+		case <-restart:
+		default:
+		}
+
+		err := c.connect(ctx)
+		if started != nil {
+			started <- err
+			started = nil
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err == nil {
+			attempt = 0
+			wasReady = true
+			eventsCtx, stopEvents := context.WithCancel(ctx)
+			go c.receiveEvents(eventsCtx)
+			err := c.monitor(ctx, restart)
+			stopEvents()
+			if err != nil {
+				log.Warnf("Service [%s] at %s:%d is unhealthy, reconnecting: %s", c.Label, c.Host, c.Port, err.Error())
+				c.setFailed(ctx, ClientStateDegraded, err)
+			} else if ctx.Err() != nil {
+				return
+			} else {
+				log.Infof("Restarting client [%s]", c.Label)
+				c.transition(ctx, ClientStateDegraded)
+			}
+			c.countReconnect()
+			continue
+		}
+
+		attempt++
+		state := ClientStateDisconnected
+		if wasReady {
+			state = ClientStateDegraded
+		}
+		c.setFailed(ctx, state, err)
+		log.Errorf("Start of client [%s] failed, attempt %d: %s", c.Label, attempt, err.Error())
+
+		if c.reconnect.MaxAttempts > 0 && attempt >= c.reconnect.MaxAttempts {
+			log.Errorf("Client [%s] gave up after %d attempts, waiting for restart", c.Label, attempt)
+			c.transition(ctx, ClientStateDisconnected)
+			select {
+			case <-ctx.Done():
+				return
+			case <-restart:
+			}
+			attempt = 0
+		} else {
+			backoff := exponentialBackoff(c.reconnect.InitialBackoff, c.reconnect.MaxBackoff, attempt)
+			log.Infof("Reconnecting client [%s] in %s", c.Label, backoff.String())
+			if !waitBackoff(ctx, backoff) {
+				return
+			}
+		}
+		c.countReconnect()
+	}
+}
+
+// connect replaces connection to the service and goes through the start sequence
+func (c *Client) connect(ctx context.Context) error {
+	log.Traceln("Client::connect")
+	c.transition(ctx, ClientStateConnecting)
+	log.Infof("Connecting client [%s] to %s:%d", c.Label, c.Host, c.Port)
+	conn, err := grpc.NewClient(fmt.Sprintf("%s:%d", c.Host, c.Port), grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
 		return err
 	}
 
+	c.connMutex.Lock()
+	if ctx.Err() != nil {
+		// Stopped while connecting
+		c.connMutex.Unlock()
+		_ = conn.Close()
+		return ctx.Err()
+	}
+	previous := c.conn
+	c.conn = conn
+	c.client = nil
+	c.connMutex.Unlock()
+	if previous != nil {
+		_ = previous.Close()
+	}
+
 	log.Infof("Client [%s] connected", c.Label)
-	if c.authenticate() != nil {
-		return fmt.Errorf("authentication failed")
+	c.transition(ctx, ClientStateAuthenticating)
+	if err := c.authenticate(); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
 	}
 
 	log.Infof("Client [%s] authenticated. Requesting REST data", c.Label)
-	if c.requestRestData() != nil {
-		return fmt.Errorf("requesting REST data failed")
+	c.transition(ctx, ClientStateFetchingRoutes)
+	if err := c.requestRestData(); err != nil {
+		return fmt.Errorf("requesting REST data failed: %w", err)
 	}
+	c.transition(ctx, ClientStateReady)
 	log.Infof("Client [%s] start sequence complete", c.Label)
 
 	return nil
 }
 
-func (c *Client) ScheduleRestart() {
-	// @TODO: Make wait time configurable
-	log.Traceln("Client::ScheduleRestart")
-	waitTime := time.Second * 3
-	log.Infof("Scheduling restart of client [%s] in %s", c.Label, waitTime.String())
-	go func() {
-		startedAt := time.Now()
-		for {
-			time.Sleep(time.Millisecond * 100)
-			if time.Since(startedAt) > waitTime {
-				log.Infof("Restarting client [%s]", c.Label)
-				if err := c.Start(); err != nil {
-					log.Errorf("Failed to restart client [%s]: %s", c.Label, err.Error())
-					c.ScheduleRestart()
-					return
-				}
-				log.Infof("Client [%s] restarted successfully", c.Label)
-				break
-			}
-		}
-	}()
-}
-
 func (c *Client) authenticate() error {
 	log.Traceln("Client::authenticate")
+	c.connMutex.Lock()
 	if c.conn == nil {
+		c.connMutex.Unlock()
 		return fmt.Errorf("connection is not initialized")
 	}
 	c.client = proto.NewRestInterServiceClient(c.conn)
+	client := c.client
+	c.connMutex.Unlock()
 
 	log.Infof("Authenticating client [%s]", c.Label)
 
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultConnectTimeout)
+	defer cancel()
 	authRequest := &proto.AuthenticateServiceRequest{
 		Token: c.Token,
 	}
-	authResponse, err := c.client.AuthInterService(context.Background(), authRequest)
+	authResponse, err := client.AuthInterService(ctx, authRequest)
 	if err != nil {
 		log.Errorf("Authentication failed for client [%s]: %s", c.Label, err.Error())
 		return err
 	}
 	if authResponse.Code != 0 {
 		log.Errorf("Authentication failed for client [%s]. Code %d: %s", c.Label, authResponse.Code, authResponse.Error)
-		return fmt.Errorf("authentication failed")
+		return fmt.Errorf("authentication failed: %s", authResponse.Error)
 	}
+	c.statusMutex.Lock()
+	c.ServiceId = uint16(authResponse.ServiceId)
+	c.statusMutex.Unlock()
 	return nil
 }
 
 func (c *Client) requestRestData() error {
 	log.Traceln("Client::requestRestData")
-	if c.conn == nil {
+	conn, client := c.connection()
+	if conn == nil {
 		return fmt.Errorf("connection is not initialized")
 	}
-	if c.client == nil {
+	if client == nil {
 		return fmt.Errorf("client is not initialized")
 	}
 
 	log.Infof("Requesting REST data for client [%s]", c.Label)
 
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultConnectTimeout)
+	defer cancel()
 	// @TODO: Add version support
 	restRequest := &proto.RestDataRequest{}
-	restResponse, err := c.client.RequestRestData(context.Background(), restRequest)
+	restResponse, err := client.RequestRestData(ctx, restRequest)
 	if err != nil {
 		log.Errorf("Requesting REST data failed for client [%s]: %s", c.Label, err.Error())
 		return err
@@ -139,34 +342,204 @@ func (c *Client) requestRestData() error {
 
 	for _, endpoint := range restResponse.Endpoints {
 		log.Infof("Registering route %s:%s for client [%s]", endpoint.Method, endpoint.Path, c.Label)
-		if err := c.registerNewRouteHandler(restResponse.Root, endpoint.Method, endpoint.Path, c); err != nil {
-			log.Errorf("Registering route failed: %s", err.Error())
-			return err
+	}
+	// Routes are replaced as a whole, so restarts and changed definitions don't leave stale routes behind
+	if err := c.updateRoutesHandler(restResponse.Root, restResponse.Endpoints, c); err != nil {
+		log.Errorf("Registering routes failed: %s", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// receiveEvents subscribes to gateway events of the ready service until the context is done. Subscription
+// is restored after errors. Services that don't implement GatewayEvents are not subscribed
+func (c *Client) receiveEvents(ctx context.Context) {
+	log.Traceln("Client::receiveEvents")
+	_, client := c.connection()
+	if client == nil || c.eventHandler == nil {
+		return
+	}
+	for attempt := 1; ; attempt++ {
+		stream, err := client.GatewayEvents(ctx, &proto.GatewayEventsRequest{})
+		for err == nil {
+			var event *proto.GatewayEvent
+			if event, err = stream.Recv(); err == nil {
+				attempt = 1
+				c.eventHandler(event, c)
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if status.Code(err) == codes.Unimplemented {
+			log.Debugf("Service [%s] doesn't send gateway events", c.Label)
+			return
 		}
-		if endpoint.SkipAuthMiddleware {
-			log.Infof("Adding route %s:/%s%s to ignore list for client [%s]", endpoint.Method, restResponse.Root, endpoint.Path, c.Label)
-			c.addRouteToIgnoreListHandler(fmt.Sprintf("%s:/%s%s", endpoint.Method, restResponse.Root, endpoint.Path))
+		log.Warnf("Gateway events of client [%s] interrupted: %s", c.Label, err.Error())
+		if !waitBackoff(ctx, exponentialBackoff(c.reconnect.InitialBackoff, c.reconnect.MaxBackoff, attempt)) {
+			return
 		}
 	}
+}
 
-	return nil
+// Stop stops the supervisor and closes connection to the service
+func (c *Client) Stop() error {
+	log.Traceln("Client::Stop")
+	log.Infof("Stopping client [%s] of %s:%d", c.Label, c.Host, c.Port)
+	c.supervisorMutex.Lock()
+	if c.cancel != nil {
+		c.cancel()
+		c.cancel = nil
+	}
+	c.supervisorMutex.Unlock()
+	c.setState(ClientStateStopped)
+
+	c.connMutex.Lock()
+	conn := c.conn
+	c.conn = nil
+	c.client = nil
+	c.connMutex.Unlock()
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
+}
+
+// Status returns a snapshot of the client state
+func (c *Client) Status() ClientStatus {
+	c.statusMutex.RLock()
+	defer c.statusMutex.RUnlock()
+	status := ClientStatus{
+		Label:      c.Label,
+		Host:       c.Host,
+		Port:       c.Port,
+		State:      c.state,
+		ServiceId:  c.ServiceId,
+		Reconnects: c.reconnects,
+		Breaker:    c.breaker.State(),
+		PingRTTMs:  float64(c.pingRTT.Microseconds()) / 1000,
+		PingMisses: c.pingMisses,
+		Bulkhead:   c.bulkhead.Status(),
+	}
+	if status.State == "" {
+		status.State = ClientStateDisconnected
+	}
+	if !c.lastPingAt.IsZero() {
+		lastPingAt := c.lastPingAt
+		status.LastPingAt = &lastPingAt
+	}
+	if c.lastError != nil {
+		lastErrorAt := c.lastErrorAt
+		status.LastError = c.lastError.Error()
+		status.LastErrorAt = &lastErrorAt
+	}
+	return status
+}
+
+// State returns current lifecycle state of the client
+func (c *Client) State() ClientState {
+	c.statusMutex.RLock()
+	defer c.statusMutex.RUnlock()
+	if c.state == "" {
+		return ClientStateDisconnected
+	}
+	return c.state
+}
+
+// connection returns current connection and its client
+func (c *Client) connection() (*grpc.ClientConn, proto.RestInterServiceClient) {
+	c.connMutex.RLock()
+	defer c.connMutex.RUnlock()
+	return c.conn, c.client
+}
+
+func (c *Client) setState(state ClientState) {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+	c.state = state
+}
+
+// transition changes state on behalf of the supervisor. Once the supervisor is stopped its
+// context is done and the state set by Stop is kept
+func (c *Client) transition(ctx context.Context, state ClientState) {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+	if ctx.Err() != nil {
+		return
+	}
+	c.state = state
+}
+
+// setFailed records error of the start sequence
+func (c *Client) setFailed(ctx context.Context, state ClientState, err error) {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+	c.lastError = err
+	c.lastErrorAt = time.Now()
+	if ctx.Err() == nil {
+		c.state = state
+	}
+}
+
+func (c *Client) countReconnect() {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+	c.reconnects++
+}
+
+// isServing returns true when requests may be sent to the service
+func (c *Client) isServing() bool {
+	return c.State() == ClientStateReady
+}
+
+// requestContext derives context for a call to the service from the client request, so the call is
+// cancelled when the client disconnects. Endpoint timeout takes precedence over the service default.
+// Stream endpoints may transfer large bodies and are only limited when the endpoint declares its own timeout
+func requestContext(parent context.Context, endpoint *proto.RestEndpoint, defaultTimeout time.Duration) (context.Context, context.CancelFunc) {
+	timeout := defaultTimeout
+	if endpoint != nil && endpoint.Stream {
+		timeout = 0
+	}
+	if endpoint != nil && endpoint.TimeoutMs > 0 {
+		timeout = time.Duration(endpoint.TimeoutMs) * time.Millisecond
+	}
+	if timeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, timeout)
 }
 
-func (c *Client) HandleRestRequest(request *proto.RestApiRequest) (*proto.RestApiResponse, error) {
+func (c *Client) HandleRestRequest(ctx context.Context, request *proto.RestApiRequest) (*proto.RestApiResponse, error) {
 	log.Traceln("Client::HandleRestRequest")
-	if c.conn == nil {
-		return nil, fmt.Errorf("connection is not initialized")
+	conn, client := c.connection()
+	if conn == nil {
+		return nil, ErrClientNotConnected
 	}
-	if c.client == nil {
+	if client == nil {
 		return nil, fmt.Errorf("client is not initialized")
 	}
 	if request == nil {
 		return nil, fmt.Errorf("request is not initialized")
 	}
+	if !c.isServing() {
+		return nil, ErrClientNotConnected
+	}
+
+	release, err := c.bulkhead.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := c.breaker.Allow(); err != nil {
+		return nil, err
+	}
 
 	log.Debugf("Handling REST request %s:%s for client [%s]", request.Method, request.Uri, c.Label)
 
-	restResponse, err := c.client.NewRestRequest(context.Background(), request)
+	restResponse, err := client.NewRestRequest(ctx, request)
+	c.breaker.Done(ctx, err)
 	if err != nil {
 		if errors.Is(err, grpc.ErrServerStopped) {
 			c.ScheduleRestart()
@@ -184,3 +557,53 @@ func (c *Client) HandleRestRequest(request *proto.RestApiRequest) (*proto.RestAp
 
 	return restResponse, nil
 }
+
+// NewRestStream opens NewRestStreamRequest to the service and sends the request head.
+// Closing the stream is controlled by ctx
+func (c *Client) NewRestStream(ctx context.Context, head *proto.RestApiRequest) (proto.RestInterService_NewRestStreamRequestClient, error) {
+	log.Traceln("Client::NewRestStream")
+	conn, client := c.connection()
+	if conn == nil {
+		return nil, ErrClientNotConnected
+	}
+	if client == nil {
+		return nil, fmt.Errorf("client is not initialized")
+	}
+	if head == nil {
+		return nil, fmt.Errorf("request is not initialized")
+	}
+	if !c.isServing() {
+		return nil, ErrClientNotConnected
+	}
+
+	release, err := c.bulkhead.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.breaker.Allow(); err != nil {
+		release()
+		return nil, err
+	}
+
+	log.Debugf("Handling REST stream request %s:%s for client [%s]", head.Method, head.Uri, c.Label)
+
+	// Only opening the stream counts for the breaker, failures while transferring the body are not tracked
+	stream, err := client.NewRestStreamRequest(ctx)
+	if err != nil {
+		c.breaker.Done(ctx, err)
+		release()
+		log.Warnf("Opening REST stream failed for client [%s]: %s", c.Label, err.Error())
+		return nil, err
+	}
+	if err := stream.Send(&proto.RestStreamRequest{Payload: &proto.RestStreamRequest_Head{Head: head}}); err != nil {
+		c.breaker.Done(ctx, err)
+		release()
+		log.Warnf("Sending REST stream head failed for client [%s]: %s", c.Label, err.Error())
+		return nil, err
+	}
+	c.breaker.Done(ctx, nil)
+	// Stream holds the slot until the request is over
+	context.AfterFunc(ctx, release)
+	return stream, nil
+}
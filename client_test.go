@@ -1,22 +1,25 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"github.com/savageking-io/ogbrest/proto"
 	"google.golang.org/grpc"
+	"net"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestClient_HandleRestRequest(t *testing.T) {
 	type fields struct {
-		Label                       string
-		Host                        string
-		Port                        uint16
-		Token                       string
-		conn                        *grpc.ClientConn
-		client                      proto.RestInterServiceClient
-		registerNewRouteHandler     RegisterNewRouteHandler
-		addRouteToIgnoreListHandler AddRouteToIgnoreListHandler
+		Label               string
+		Host                string
+		Port                uint16
+		Token               string
+		conn                *grpc.ClientConn
+		client              proto.RestInterServiceClient
+		updateRoutesHandler UpdateRoutesHandler
 	}
 	type args struct {
 		request *proto.RestApiRequest
@@ -37,16 +40,15 @@ func TestClient_HandleRestRequest(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := &Client{
-				Label:                       tt.fields.Label,
-				Host:                        tt.fields.Host,
-				Port:                        tt.fields.Port,
-				Token:                       tt.fields.Token,
-				conn:                        tt.fields.conn,
-				client:                      tt.fields.client,
-				registerNewRouteHandler:     tt.fields.registerNewRouteHandler,
-				addRouteToIgnoreListHandler: tt.fields.addRouteToIgnoreListHandler,
-			}
-			got, err := c.HandleRestRequest(tt.args.request)
+				Label:               tt.fields.Label,
+				Host:                tt.fields.Host,
+				Port:                tt.fields.Port,
+				Token:               tt.fields.Token,
+				conn:                tt.fields.conn,
+				client:              tt.fields.client,
+				updateRoutesHandler: tt.fields.updateRoutesHandler,
+			}
+			got, err := c.HandleRestRequest(context.Background(), tt.args.request)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("HandleRestRequest() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -60,19 +62,17 @@ func TestClient_HandleRestRequest(t *testing.T) {
 
 func TestClient_Init(t *testing.T) {
 	type fields struct {
-		Label                       string
-		Host                        string
-		Port                        uint16
-		Token                       string
-		conn                        *grpc.ClientConn
-		client                      proto.RestInterServiceClient
-		registerNewRouteHandler     RegisterNewRouteHandler
-		addRouteToIgnoreListHandler AddRouteToIgnoreListHandler
+		Label               string
+		Host                string
+		Port                uint16
+		Token               string
+		conn                *grpc.ClientConn
+		client              proto.RestInterServiceClient
+		updateRoutesHandler UpdateRoutesHandler
 	}
 	type args struct {
-		config                      *ServiceConfig
-		routeRegistrationHandler    RegisterNewRouteHandler
-		addRouteToIgnoreListHandler AddRouteToIgnoreListHandler
+		config              *ServiceConfig
+		updateRoutesHandler UpdateRoutesHandler
 	}
 	tests := []struct {
 		name    string
@@ -85,16 +85,15 @@ func TestClient_Init(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := &Client{
-				Label:                       tt.fields.Label,
-				Host:                        tt.fields.Host,
-				Port:                        tt.fields.Port,
-				Token:                       tt.fields.Token,
-				conn:                        tt.fields.conn,
-				client:                      tt.fields.client,
-				registerNewRouteHandler:     tt.fields.registerNewRouteHandler,
-				addRouteToIgnoreListHandler: tt.fields.addRouteToIgnoreListHandler,
-			}
-			if err := c.Init(tt.args.config, tt.args.routeRegistrationHandler, tt.args.addRouteToIgnoreListHandler); (err != nil) != tt.wantErr {
+				Label:               tt.fields.Label,
+				Host:                tt.fields.Host,
+				Port:                tt.fields.Port,
+				Token:               tt.fields.Token,
+				conn:                tt.fields.conn,
+				client:              tt.fields.client,
+				updateRoutesHandler: tt.fields.updateRoutesHandler,
+			}
+			if err := c.Init(tt.args.config, tt.args.updateRoutesHandler); (err != nil) != tt.wantErr {
 				t.Errorf("Init() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -103,14 +102,13 @@ func TestClient_Init(t *testing.T) {
 
 func TestClient_ScheduleRestart(t *testing.T) {
 	type fields struct {
-		Label                       string
-		Host                        string
-		Port                        uint16
-		Token                       string
-		conn                        *grpc.ClientConn
-		client                      proto.RestInterServiceClient
-		registerNewRouteHandler     RegisterNewRouteHandler
-		addRouteToIgnoreListHandler AddRouteToIgnoreListHandler
+		Label               string
+		Host                string
+		Port                uint16
+		Token               string
+		conn                *grpc.ClientConn
+		client              proto.RestInterServiceClient
+		updateRoutesHandler UpdateRoutesHandler
 	}
 	tests := []struct {
 		name   string
@@ -121,14 +119,13 @@ func TestClient_ScheduleRestart(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := &Client{
-				Label:                       tt.fields.Label,
-				Host:                        tt.fields.Host,
-				Port:                        tt.fields.Port,
-				Token:                       tt.fields.Token,
-				conn:                        tt.fields.conn,
-				client:                      tt.fields.client,
-				registerNewRouteHandler:     tt.fields.registerNewRouteHandler,
-				addRouteToIgnoreListHandler: tt.fields.addRouteToIgnoreListHandler,
+				Label:               tt.fields.Label,
+				Host:                tt.fields.Host,
+				Port:                tt.fields.Port,
+				Token:               tt.fields.Token,
+				conn:                tt.fields.conn,
+				client:              tt.fields.client,
+				updateRoutesHandler: tt.fields.updateRoutesHandler,
 			}
 			c.ScheduleRestart()
 		})
@@ -137,14 +134,13 @@ func TestClient_ScheduleRestart(t *testing.T) {
 
 func TestClient_Start(t *testing.T) {
 	type fields struct {
-		Label                       string
-		Host                        string
-		Port                        uint16
-		Token                       string
-		conn                        *grpc.ClientConn
-		client                      proto.RestInterServiceClient
-		registerNewRouteHandler     RegisterNewRouteHandler
-		addRouteToIgnoreListHandler AddRouteToIgnoreListHandler
+		Label               string
+		Host                string
+		Port                uint16
+		Token               string
+		conn                *grpc.ClientConn
+		client              proto.RestInterServiceClient
+		updateRoutesHandler UpdateRoutesHandler
 	}
 	tests := []struct {
 		name    string
@@ -156,14 +152,13 @@ func TestClient_Start(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := &Client{
-				Label:                       tt.fields.Label,
-				Host:                        tt.fields.Host,
-				Port:                        tt.fields.Port,
-				Token:                       tt.fields.Token,
-				conn:                        tt.fields.conn,
-				client:                      tt.fields.client,
-				registerNewRouteHandler:     tt.fields.registerNewRouteHandler,
-				addRouteToIgnoreListHandler: tt.fields.addRouteToIgnoreListHandler,
+				Label:               tt.fields.Label,
+				Host:                tt.fields.Host,
+				Port:                tt.fields.Port,
+				Token:               tt.fields.Token,
+				conn:                tt.fields.conn,
+				client:              tt.fields.client,
+				updateRoutesHandler: tt.fields.updateRoutesHandler,
 			}
 			if err := c.Start(); (err != nil) != tt.wantErr {
 				t.Errorf("Start() error = %v, wantErr %v", err, tt.wantErr)
@@ -174,14 +169,13 @@ func TestClient_Start(t *testing.T) {
 
 func TestClient_authenticate(t *testing.T) {
 	type fields struct {
-		Label                       string
-		Host                        string
-		Port                        uint16
-		Token                       string
-		conn                        *grpc.ClientConn
-		client                      proto.RestInterServiceClient
-		registerNewRouteHandler     RegisterNewRouteHandler
-		addRouteToIgnoreListHandler AddRouteToIgnoreListHandler
+		Label               string
+		Host                string
+		Port                uint16
+		Token               string
+		conn                *grpc.ClientConn
+		client              proto.RestInterServiceClient
+		updateRoutesHandler UpdateRoutesHandler
 	}
 	tests := []struct {
 		name    string
@@ -193,14 +187,13 @@ func TestClient_authenticate(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := &Client{
-				Label:                       tt.fields.Label,
-				Host:                        tt.fields.Host,
-				Port:                        tt.fields.Port,
-				Token:                       tt.fields.Token,
-				conn:                        tt.fields.conn,
-				client:                      tt.fields.client,
-				registerNewRouteHandler:     tt.fields.registerNewRouteHandler,
-				addRouteToIgnoreListHandler: tt.fields.addRouteToIgnoreListHandler,
+				Label:               tt.fields.Label,
+				Host:                tt.fields.Host,
+				Port:                tt.fields.Port,
+				Token:               tt.fields.Token,
+				conn:                tt.fields.conn,
+				client:              tt.fields.client,
+				updateRoutesHandler: tt.fields.updateRoutesHandler,
 			}
 			if err := c.authenticate(); (err != nil) != tt.wantErr {
 				t.Errorf("authenticate() error = %v, wantErr %v", err, tt.wantErr)
@@ -211,14 +204,13 @@ func TestClient_authenticate(t *testing.T) {
 
 func TestClient_requestRestData(t *testing.T) {
 	type fields struct {
-		Label                       string
-		Host                        string
-		Port                        uint16
-		Token                       string
-		conn                        *grpc.ClientConn
-		client                      proto.RestInterServiceClient
-		registerNewRouteHandler     RegisterNewRouteHandler
-		addRouteToIgnoreListHandler AddRouteToIgnoreListHandler
+		Label               string
+		Host                string
+		Port                uint16
+		Token               string
+		conn                *grpc.ClientConn
+		client              proto.RestInterServiceClient
+		updateRoutesHandler UpdateRoutesHandler
 	}
 	tests := []struct {
 		name    string
@@ -230,14 +222,13 @@ func TestClient_requestRestData(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := &Client{
-				Label:                       tt.fields.Label,
-				Host:                        tt.fields.Host,
-				Port:                        tt.fields.Port,
-				Token:                       tt.fields.Token,
-				conn:                        tt.fields.conn,
-				client:                      tt.fields.client,
-				registerNewRouteHandler:     tt.fields.registerNewRouteHandler,
-				addRouteToIgnoreListHandler: tt.fields.addRouteToIgnoreListHandler,
+				Label:               tt.fields.Label,
+				Host:                tt.fields.Host,
+				Port:                tt.fields.Port,
+				Token:               tt.fields.Token,
+				conn:                tt.fields.conn,
+				client:              tt.fields.client,
+				updateRoutesHandler: tt.fields.updateRoutesHandler,
 			}
 			if err := c.requestRestData(); (err != nil) != tt.wantErr {
 				t.Errorf("requestRestData() error = %v, wantErr %v", err, tt.wantErr)
@@ -245,3 +236,158 @@ func TestClient_requestRestData(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_HandleRestRequestState(t *testing.T) {
+	tests := []struct {
+		name  string
+		state ClientState
+	}{
+		{"Disconnected", ClientStateDisconnected},
+		{"Connecting", ClientStateConnecting},
+		{"Authenticating", ClientStateAuthenticating},
+		{"Fetching routes", ClientStateFetchingRoutes},
+		{"Degraded", ClientStateDegraded},
+		{"Stopped", ClientStateStopped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{conn: &grpc.ClientConn{}, client: proto.NewRestInterServiceClient(&grpc.ClientConn{}), state: tt.state}
+			if _, err := c.HandleRestRequest(context.Background(), &proto.RestApiRequest{}); !errors.Is(err, ErrClientNotConnected) {
+				t.Errorf("HandleRestRequest() error = %v, want %v", err, ErrClientNotConnected)
+			}
+			if _, err := c.NewRestStream(context.Background(), &proto.RestApiRequest{}); !errors.Is(err, ErrClientNotConnected) {
+				t.Errorf("NewRestStream() error = %v, want %v", err, ErrClientNotConnected)
+			}
+		})
+	}
+}
+
+func TestClient_Supervisor(t *testing.T) {
+	// Nothing listens on the port, so every start sequence fails
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := uint16(listener.Addr().(*net.TCPAddr).Port)
+	_ = listener.Close()
+
+	c := &Client{}
+	config := &ServiceConfig{
+		Label:     "test",
+		Hostname:  "127.0.0.1",
+		Port:      port,
+		Reconnect: ReconnectConfig{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, MaxAttempts: 2},
+	}
+	if err := c.Init(config, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	waitStatus := func(name string, check func(status ClientStatus) bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !check(c.Status()) {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: status got = %+v", name, c.Status())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	if err := c.Start(); err == nil {
+		t.Fatalf("Start() expected error")
+	}
+	if err := c.Start(); err != nil {
+		t.Errorf("Start() of running client error = %v", err)
+	}
+	waitStatus("Gave up", func(status ClientStatus) bool {
+		return status.Reconnects == 1 && status.State == ClientStateDisconnected && status.LastError != ""
+	})
+
+	// Client that gave up goes through max attempts again after a restart
+	c.ScheduleRestart()
+	waitStatus("Restarted", func(status ClientStatus) bool {
+		return status.Reconnects == 3 && status.State == ClientStateDisconnected
+	})
+	time.Sleep(50 * time.Millisecond)
+	if got := c.Status().Reconnects; got != 3 {
+		t.Errorf("reconnects got = %d, want 3", got)
+	}
+
+	if err := c.Stop(); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+	c.ScheduleRestart()
+	if got := c.State(); got != ClientStateStopped {
+		t.Errorf("State() got = %s, want %s", got, ClientStateStopped)
+	}
+}
+
+func TestClient_receiveEvents(t *testing.T) {
+	service := &testService{events: make(chan *proto.GatewayEvent)}
+	server, port := startTestService(t, service)
+	defer server.Stop()
+
+	received := make(chan *proto.GatewayEvent, 1)
+	c := &Client{}
+	if err := c.Init(&ServiceConfig{Label: "test", Hostname: "127.0.0.1", Port: port}, func(root string, endpoints []*proto.RestEndpoint, client *Client) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	c.eventHandler = func(event *proto.GatewayEvent, client *Client) {
+		received <- event
+	}
+	if err := c.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer c.Stop()
+
+	purge := &proto.GatewayEvent{Payload: &proto.GatewayEvent_CachePurge{CachePurge: &proto.CachePurge{All: true}}}
+	select {
+	case service.events <- purge:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("client didn't subscribe to events")
+	}
+	select {
+	case event := <-received:
+		if !event.GetCachePurge().GetAll() {
+			t.Errorf("event got = %v, want %v", event, purge)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("event was not received")
+	}
+}
+
+func TestServicePool_RequestContext(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestTimeout time.Duration // Of the service
+		endpoint       *proto.RestEndpoint
+		want           time.Duration // No deadline when 0
+	}{
+		{"Default", 0, &proto.RestEndpoint{}, DefaultRequestTimeout},
+		{"No endpoint", 0, nil, DefaultRequestTimeout},
+		{"Service timeout", 10 * time.Second, &proto.RestEndpoint{}, 10 * time.Second},
+		{"Endpoint overrides service", 10 * time.Second, &proto.RestEndpoint{TimeoutMs: 500}, 500 * time.Millisecond},
+		{"Endpoint overrides default", 0, &proto.RestEndpoint{TimeoutMs: 60000}, time.Minute},
+		{"Service without timeout", -1, &proto.RestEndpoint{}, 0},
+		{"Stream", 10 * time.Second, &proto.RestEndpoint{Stream: true}, 0},
+		{"Stream with endpoint timeout", 10 * time.Second, &proto.RestEndpoint{Stream: true, TimeoutMs: 500}, 500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewServicePool(&ServiceConfig{Label: "test", RequestTimeout: tt.requestTimeout}, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			started := time.Now()
+			ctx, cancel := p.RequestContext(context.Background(), tt.endpoint)
+			defer cancel()
+			deadline, ok := ctx.Deadline()
+			if ok != (tt.want != 0) {
+				t.Fatalf("RequestContext() has deadline = %v, want %v", ok, tt.want != 0)
+			}
+			if got := deadline.Sub(started); ok && (got < tt.want || got > tt.want+time.Second) {
+				t.Errorf("RequestContext() timeout got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	ogb "github.com/savageking-io/ogbcommon"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DNSRecordSRV = "srv"
+	DNSRecordA   = "a"
+)
+
+const (
+	DefaultDNSRefreshInterval = 30 * time.Second
+	DefaultFileInterval       = 5 * time.Second
+)
+
+// DiscoveryProvider finds instances of services. Providers are polled and report complete
+// lists of instances by service label
+type DiscoveryProvider interface {
+	Name() string
+	Interval() time.Duration
+	Discover(ctx context.Context) (map[string][]ServiceInstanceConfig, error)
+}
+
+// Discovery polls providers and keeps service pools in sync with instances they report.
+// Instances listed in the configuration are always kept
+type Discovery struct {
+	mutex     sync.Mutex
+	pools     map[string]*ServicePool
+	static    map[string][]ServiceInstanceConfig
+	providers []DiscoveryProvider
+	found     map[string]map[string][]ServiceInstanceConfig // Last result of each provider by provider name
+}
+
+func NewDiscovery(config *DiscoveryConfig, services []ServiceConfig, pools map[string]*ServicePool) (*Discovery, error) {
+	log.Traceln("Discovery::NewDiscovery")
+	if config == nil {
+		return nil, fmt.Errorf("no discovery configuration provided")
+	}
+	d := &Discovery{
+		pools:  make(map[string]*ServicePool),
+		static: make(map[string][]ServiceInstanceConfig),
+		found:  make(map[string]map[string][]ServiceInstanceConfig),
+	}
+	for label, pool := range pools {
+		d.pools[label] = pool
+	}
+
+	dnsServices := make(map[string]ServiceDNSConfig)
+	for _, service := range services {
+		d.static[service.Label] = service.InstanceList()
+		if service.DNS == nil {
+			continue
+		}
+		if err := validateDNSConfig(service.DNS); err != nil {
+			return nil, fmt.Errorf("service [%s]: %w", service.Label, err)
+		}
+		dnsServices[service.Label] = *service.DNS
+	}
+	if len(dnsServices) > 0 {
+		d.providers = append(d.providers, NewDNSDiscovery(net.DefaultResolver, dnsServices, config.RefreshInterval))
+	}
+	if config.File != "" {
+		d.providers = append(d.providers, NewFileDiscovery(config.File, config.FileInterval))
+	}
+	return d, nil
+}
+
+func validateDNSConfig(config *ServiceDNSConfig) error {
+	if config.Name == "" {
+		return fmt.Errorf("dns name is not set")
+	}
+	switch strings.ToLower(config.Type) {
+	case "", DNSRecordSRV:
+	case DNSRecordA:
+		if config.Port == 0 {
+			return fmt.Errorf("dns port is required for A records")
+		}
+	default:
+		return fmt.Errorf("unknown dns record type %s", config.Type)
+	}
+	return nil
+}
+
+// Run polls every provider until the context is done
+func (d *Discovery) Run(ctx context.Context) {
+	log.Traceln("Discovery::Run")
+	var wg sync.WaitGroup
+	for _, provider := range d.providers {
+		wg.Add(1)
+		go func(provider DiscoveryProvider) {
+			defer wg.Done()
+			log.Infof("Starting %s discovery, refreshing every %s", provider.Name(), provider.Interval().String())
+			ticker := time.NewTicker(provider.Interval())
+			defer ticker.Stop()
+			for {
+				d.Refresh(ctx, provider)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}(provider)
+	}
+	wg.Wait()
+}
+
+// Refresh queries the provider and updates pools of services it reports or used to report.
+// When provider fails, instances it found before are kept
+func (d *Discovery) Refresh(ctx context.Context, provider DiscoveryProvider) {
+	log.Traceln("Discovery::Refresh")
+	result, err := provider.Discover(ctx)
+	if err != nil {
+		log.Warnf("%s discovery failed: %s", provider.Name(), err.Error())
+		return
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	labels := make(map[string]bool)
+	for label := range d.found[provider.Name()] {
+		labels[label] = true
+	}
+	for label := range result {
+		labels[label] = true
+	}
+	d.found[provider.Name()] = result
+
+	for label := range labels {
+		pool, ok := d.pools[label]
+		if !ok {
+			log.Warnf("%s discovery found instances of unknown service [%s]", provider.Name(), label)
+			continue
+		}
+		pool.SetInstances(d.instances(label))
+	}
+}
+
+// instances merges configured and discovered instances of the service. Must be called with mutex locked
+func (d *Discovery) instances(label string) []ServiceInstanceConfig {
+	var result []ServiceInstanceConfig
+	seen := make(map[string]bool)
+	add := func(instances []ServiceInstanceConfig) {
+		for _, instance := range instances {
+			if seen[instance.Address()] {
+				continue
+			}
+			seen[instance.Address()] = true
+			result = append(result, instance)
+		}
+	}
+	add(d.static[label])
+	for _, provider := range d.providers {
+		add(d.found[provider.Name()][label])
+	}
+	return result
+}
+
+// dnsResolver is a subset of net.Resolver used for discovery
+type dnsResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// DNSDiscovery finds instances with SRV or A lookups
+type DNSDiscovery struct {
+	resolver dnsResolver
+	services map[string]ServiceDNSConfig
+	interval time.Duration
+	last     map[string][]ServiceInstanceConfig
+}
+
+func NewDNSDiscovery(resolver dnsResolver, services map[string]ServiceDNSConfig, interval time.Duration) *DNSDiscovery {
+	if interval <= 0 {
+		interval = DefaultDNSRefreshInterval
+	}
+	return &DNSDiscovery{
+		resolver: resolver,
+		services: services,
+		interval: interval,
+		last:     make(map[string][]ServiceInstanceConfig),
+	}
+}
+
+func (d *DNSDiscovery) Name() string {
+	return "DNS"
+}
+
+func (d *DNSDiscovery) Interval() time.Duration {
+	return d.interval
+}
+
+// Discover looks up every service. Names that don't exist have no instances, while on other
+// failures instances found by the previous lookup are kept
+func (d *DNSDiscovery) Discover(ctx context.Context) (map[string][]ServiceInstanceConfig, error) {
+	result := make(map[string][]ServiceInstanceConfig)
+	for label, config := range d.services {
+		instances, err := d.lookup(ctx, config)
+		if err != nil {
+			var dnsErr *net.DNSError
+			if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+				log.Warnf("DNS lookup of %s for [%s] failed: %s", config.Name, label, err.Error())
+				result[label] = d.last[label]
+				continue
+			}
+			log.Debugf("DNS record %s for [%s] not found", config.Name, label)
+		}
+		result[label] = instances
+	}
+	d.last = result
+	return result, nil
+}
+
+func (d *DNSDiscovery) lookup(ctx context.Context, config ServiceDNSConfig) ([]ServiceInstanceConfig, error) {
+	var result []ServiceInstanceConfig
+	if strings.ToLower(config.Type) == DNSRecordA {
+		hosts, err := d.resolver.LookupHost(ctx, config.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, host := range hosts {
+			result = append(result, ServiceInstanceConfig{Hostname: host, Port: config.Port})
+		}
+	} else {
+		_, records, err := d.resolver.LookupSRV(ctx, "", "", config.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			result = append(result, ServiceInstanceConfig{Hostname: strings.TrimSuffix(record.Target, "."), Port: record.Port})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Address() < result[j].Address()
+	})
+	return result, nil
+}
+
+// FileDiscovery reads instances from a local file and re-reads it when it changes.
+// File maps service labels to lists of instances. JSON is accepted as it is valid YAML:
+//
+//	user:
+//	  - hostname: 10.0.0.5
+//	    port: 12121
+type FileDiscovery struct {
+	path     string
+	interval time.Duration
+	modTime  time.Time
+	size     int64
+	last     map[string][]ServiceInstanceConfig
+}
+
+func NewFileDiscovery(path string, interval time.Duration) *FileDiscovery {
+	if interval <= 0 {
+		interval = DefaultFileInterval
+	}
+	return &FileDiscovery{
+		path:     path,
+		interval: interval,
+	}
+}
+
+func (d *FileDiscovery) Name() string {
+	return "File"
+}
+
+func (d *FileDiscovery) Interval() time.Duration {
+	return d.interval
+}
+
+// Discover returns instances from the file. File that can't be read or parsed, e.g. while it's
+// being written, is reported as an error so previous instances are kept
+func (d *FileDiscovery) Discover(ctx context.Context) (map[string][]ServiceInstanceConfig, error) {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return nil, err
+	}
+	if d.last != nil && info.ModTime().Equal(d.modTime) && info.Size() == d.size {
+		return d.last, nil
+	}
+
+	result := make(map[string][]ServiceInstanceConfig)
+	if err := ogb.ReadYAMLConfig(d.path, &result); err != nil {
+		return nil, err
+	}
+	for label, instances := range result {
+		for _, instance := range instances {
+			if instance.Hostname == "" || instance.Port == 0 {
+				return nil, fmt.Errorf("instance of [%s] in %s must have hostname and port", label, d.path)
+			}
+		}
+	}
+	log.Infof("Loaded instances of %d services from %s", len(result), d.path)
+	d.modTime = info.ModTime()
+	d.size = info.Size()
+	d.last = result
+	return result, nil
+}
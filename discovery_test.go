@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testResolver struct {
+	srv   map[string][]*net.SRV
+	hosts map[string][]string
+	err   error
+}
+
+func (r *testResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	if r.err != nil {
+		return "", nil, r.err
+	}
+	records, ok := r.srv[name]
+	if !ok {
+		return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return name, records, nil
+}
+
+func (r *testResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	hosts, ok := r.hosts[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return hosts, nil
+}
+
+func TestDNSDiscovery_Discover(t *testing.T) {
+	services := map[string]ServiceDNSConfig{
+		"user": {Name: "_grpc._tcp.user.local"},
+		"test": {Name: "test.local", Type: "a", Port: 9001},
+	}
+	previous := map[string][]ServiceInstanceConfig{
+		"user": {{Hostname: "old", Port: 1}},
+		"test": {{Hostname: "10.0.0.9", Port: 9001}},
+	}
+	tests := []struct {
+		name     string
+		resolver *testResolver
+		want     map[string][]ServiceInstanceConfig
+	}{
+		{"Records found", &testResolver{
+			srv:   map[string][]*net.SRV{"_grpc._tcp.user.local": {{Target: "user-2.local.", Port: 12121}, {Target: "user-1.local.", Port: 12121}}},
+			hosts: map[string][]string{"test.local": {"10.0.0.1"}},
+		}, map[string][]ServiceInstanceConfig{
+			"user": {{Hostname: "user-1.local", Port: 12121}, {Hostname: "user-2.local", Port: 12121}},
+			"test": {{Hostname: "10.0.0.1", Port: 9001}},
+		}},
+		{"Records not found", &testResolver{}, map[string][]ServiceInstanceConfig{"user": nil, "test": nil}},
+		{"Lookup failure keeps previous", &testResolver{err: errors.New("timeout")}, previous},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDNSDiscovery(tt.resolver, services, 0)
+			d.last = previous
+			got, err := d.Discover(context.Background())
+			if err != nil {
+				t.Errorf("Discover() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Discover() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileDiscovery_Discover(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    map[string][]ServiceInstanceConfig
+		wantErr bool
+	}{
+		{"YAML", "instances.yaml", "user:\n  - hostname: user-1\n    port: 12121\n", map[string][]ServiceInstanceConfig{"user": {{Hostname: "user-1", Port: 12121}}}, false},
+		{"JSON", "instances.json", `{"user": [{"hostname": "user-1", "port": 12121}], "test": []}`, map[string][]ServiceInstanceConfig{"user": {{Hostname: "user-1", Port: 12121}}, "test": {}}, false},
+		{"Missing port", "instances.yaml", "user:\n  - hostname: user-1\n", nil, true},
+		{"Invalid", "instances.yaml", "user: [", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := NewFileDiscovery(path, 0).Discover(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Discover() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Discover() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileDiscovery_Changes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "instances.yaml")
+	_ = os.WriteFile(path, []byte("user:\n  - hostname: user-1\n    port: 1\n"), 0644)
+	d := NewFileDiscovery(path, 0)
+	if _, err := d.Discover(context.Background()); err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+
+	// Partially written file keeps previous instances in Discovery, change is picked up once it's valid
+	modTime := time.Now().Add(time.Second)
+	_ = os.WriteFile(path, []byte("user:\n  - hostname: user-2\n    port"), 0644)
+	_ = os.Chtimes(path, modTime, modTime)
+	if _, err := d.Discover(context.Background()); err == nil {
+		t.Errorf("Discover() expected error for invalid file")
+	}
+	_ = os.WriteFile(path, []byte("user:\n  - hostname: user-2\n    port: 2\n"), 0644)
+	_ = os.Chtimes(path, modTime, modTime)
+	got, err := d.Discover(context.Background())
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	want := map[string][]ServiceInstanceConfig{"user": {{Hostname: "user-2", Port: 2}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Discover() got = %v, want %v", got, want)
+	}
+}
+
+type testProvider struct {
+	result map[string][]ServiceInstanceConfig
+	err    error
+}
+
+func (p *testProvider) Name() string            { return "Test" }
+func (p *testProvider) Interval() time.Duration { return time.Second }
+func (p *testProvider) Discover(ctx context.Context) (map[string][]ServiceInstanceConfig, error) {
+	return p.result, p.err
+}
+
+func TestDiscovery_Refresh(t *testing.T) {
+	service := ServiceConfig{Label: "user", Hostname: "static", Port: 1}
+	pool, err := NewServicePool(&service, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDiscovery(&DiscoveryConfig{}, []ServiceConfig{service}, map[string]*ServicePool{"user": pool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &testProvider{}
+	d.providers = append(d.providers, provider)
+
+	addresses := func() []string {
+		var result []string
+		for _, client := range pool.Instances() {
+			result = append(result, ServiceInstanceConfig{Hostname: client.Host, Port: client.Port}.Address())
+		}
+		return result
+	}
+
+	steps := []struct {
+		name     string
+		result   map[string][]ServiceInstanceConfig
+		err      error
+		expected []string
+	}{
+		{"Instances discovered", map[string][]ServiceInstanceConfig{"user": {{"user-1", 2}, {"static", 1}}, "unknown": {{"x", 1}}}, nil, []string{"static:1", "user-1:2"}},
+		{"Failure keeps instances", nil, errors.New("failed"), []string{"static:1", "user-1:2"}},
+		{"Instance replaced", map[string][]ServiceInstanceConfig{"user": {{"user-2", 2}}}, nil, []string{"static:1", "user-2:2"}},
+		{"Label removed keeps static", map[string][]ServiceInstanceConfig{}, nil, []string{"static:1"}},
+	}
+	for _, step := range steps {
+		provider.result, provider.err = step.result, step.err
+		d.Refresh(context.Background(), provider)
+		if got := addresses(); !reflect.DeepEqual(got, step.expected) {
+			t.Errorf("%s: instances got = %v, want %v", step.name, got, step.expected)
+		}
+	}
+}
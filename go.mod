@@ -5,9 +5,10 @@ go 1.25.1
 require (
 	github.com/go-chi/chi/v5 v5.2.3
 	github.com/go-chi/cors v1.2.2
+	github.com/golang-jwt/jwt/v5 v5.3.1
 	github.com/gorilla/websocket v1.5.3
 	github.com/savageking-io/ogbcommon v0.2.0
-	github.com/savageking-io/ogbrest/proto v0.8.0
+	github.com/savageking-io/ogbrest/proto v0.9.0
 	github.com/savageking-io/ogbuser/client v0.4.0
 	github.com/savageking-io/ogbuser/proto v0.4.0
 	github.com/segmentio/kafka-go v0.4.49
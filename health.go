@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/savageking-io/ogbrest/proto"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"time"
+)
+
+const (
+	DefaultHealthInterval  = 5 * time.Second
+	DefaultHealthTimeout   = 2 * time.Second
+	DefaultHealthMaxMisses = 3
+)
+
+// monitor checks health of the ready service: pings it periodically and watches state of the connection.
+// Returns nil when restart is requested or the client is stopped, and the reason when the service is unhealthy
+func (c *Client) monitor(ctx context.Context, restart <-chan struct{}) error {
+	log.Traceln("Client::monitor")
+	conn, client := c.connection()
+	if conn == nil || client == nil {
+		return ErrClientNotConnected
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	changes := make(chan connectivity.State, 1)
+	go watchConnectivity(watchCtx, conn, changes)
+
+	ticker := time.NewTicker(c.health.Interval)
+	defer ticker.Stop()
+	misses := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-restart:
+			return nil
+		case state := <-changes:
+			log.Debugf("Connection of client [%s] is %s", c.Label, state.String())
+			if state == connectivity.TransientFailure || state == connectivity.Shutdown {
+				return fmt.Errorf("connection is %s", state.String())
+			}
+		case <-ticker.C:
+			err := c.ping(ctx, client)
+			if err == nil {
+				misses = 0
+				continue
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+			misses++
+			log.Warnf("Ping of client [%s] failed, %d of %d missed: %s", c.Label, misses, c.health.MaxMisses, err.Error())
+			if misses >= c.health.MaxMisses {
+				return fmt.Errorf("%d pings missed: %w", misses, err)
+			}
+		}
+	}
+}
+
+// ping sends Ping to the service and records round trip time. Services that don't implement Ping
+// still respond, so they are considered alive
+func (c *Client) ping(ctx context.Context, client proto.RestInterServiceClient) error {
+	ctx, cancel := context.WithTimeout(ctx, c.health.Timeout)
+	defer cancel()
+	sentAt := time.Now()
+	_, err := client.Ping(ctx, &proto.PingMessage{SentAt: timestamppb.New(sentAt)})
+	if err != nil && status.Code(err) != codes.Unimplemented {
+		c.statusMutex.Lock()
+		c.pingMisses++
+		c.statusMutex.Unlock()
+		return err
+	}
+	rtt := time.Since(sentAt)
+	log.Tracef("Ping of client [%s] took %s", c.Label, rtt.String())
+	c.statusMutex.Lock()
+	c.pingRTT = rtt
+	c.lastPingAt = sentAt
+	c.pingMisses = 0
+	c.statusMutex.Unlock()
+	return nil
+}
+
+// watchConnectivity sends every state change of the connection until the context is done
+func watchConnectivity(ctx context.Context, conn *grpc.ClientConn, changes chan<- connectivity.State) {
+	state := conn.GetState()
+	for conn.WaitForStateChange(ctx, state) {
+		state = conn.GetState()
+		select {
+		case changes <- state:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"github.com/savageking-io/ogbrest/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testService struct {
+	proto.UnimplementedRestInterServiceServer
+	pingFails atomic.Bool
+	auths     atomic.Int32
+	events    chan *proto.GatewayEvent
+}
+
+func (s *testService) AuthInterService(ctx context.Context, in *proto.AuthenticateServiceRequest) (*proto.AuthenticateServiceResponse, error) {
+	s.auths.Add(1)
+	return &proto.AuthenticateServiceResponse{ServiceId: 7}, nil
+}
+
+func (s *testService) RequestRestData(ctx context.Context, in *proto.RestDataRequest) (*proto.RestDataDefinition, error) {
+	return &proto.RestDataDefinition{Root: "test"}, nil
+}
+
+func (s *testService) Ping(ctx context.Context, in *proto.PingMessage) (*proto.PingMessage, error) {
+	if s.pingFails.Load() {
+		return nil, status.Error(codes.Internal, "ping failed")
+	}
+	return in, nil
+}
+
+func (s *testService) GatewayEvents(in *proto.GatewayEventsRequest, stream proto.RestInterService_GatewayEventsServer) error {
+	if s.events == nil {
+		return status.Error(codes.Unimplemented, "not implemented")
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event := <-s.events:
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// startTestService serves the service on a random local port
+func startTestService(t *testing.T, service *testService) (*grpc.Server, uint16) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	proto.RegisterRestInterServiceServer(server, service)
+	go func() { _ = server.Serve(listener) }()
+	return server, uint16(listener.Addr().(*net.TCPAddr).Port)
+}
+
+func TestClient_HealthCheck(t *testing.T) {
+	service := &testService{}
+	server, port := startTestService(t, service)
+	defer server.Stop()
+
+	c := &Client{}
+	config := &ServiceConfig{
+		Label:     "test",
+		Hostname:  "127.0.0.1",
+		Port:      port,
+		Reconnect: ReconnectConfig{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+		Health:    HealthConfig{Interval: 5 * time.Millisecond, Timeout: time.Second, MaxMisses: 2},
+	}
+	if err := c.Init(config, func(root string, endpoints []*proto.RestEndpoint, client *Client) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer c.Stop()
+
+	waitStatus := func(name string, check func(status ClientStatus) bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !check(c.Status()) {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: status got = %+v", name, c.Status())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	waitStatus("Pinged", func(status ClientStatus) bool {
+		return status.State == ClientStateReady && status.ServiceId == 7 && status.LastPingAt != nil
+	})
+
+	// Missed pings make the service unhealthy, client reconnects and pings fail again
+	service.pingFails.Store(true)
+	waitStatus("Unhealthy", func(status ClientStatus) bool {
+		return status.Reconnects >= 2 && service.auths.Load() >= 3
+	})
+
+	service.pingFails.Store(false)
+	waitStatus("Recovered", func(status ClientStatus) bool {
+		return status.State == ClientStateReady && status.PingMisses == 0
+	})
+
+	// Service shutdown is noticed from the connection state
+	reconnects := c.Status().Reconnects
+	server.Stop()
+	waitStatus("Server stopped", func(status ClientStatus) bool {
+		return status.Reconnects > reconnects && status.State != ClientStateReady
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/savageking-io/ogbrest/proto"
+	"sort"
+)
+
+type contextKey string
+
+const identityContextKey contextKey = "identity"
+
+const (
+	AuthMethodJWT    = "jwt"
+	AuthMethodAPIKey = "api_key"
+)
+
+// Identity describes the caller authenticated by JWTMiddleware
+type Identity struct {
+	UserId     int32
+	Claims     map[string][]string // Claims of the token once it is validated, or owner, roles and scopes of the API key
+	AuthMethod string              // How the caller was authenticated: jwt or api_key
+	APIKey     string              // Name of the API key. Empty for tokens
+	apiKey     *APIKey
+}
+
+func withIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityContextKey, identity)
+}
+
+// identityFromContext returns nil if request didn't pass through authentication
+func identityFromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(identityContextKey).(*Identity)
+	return identity
+}
+
+// subject tells callers apart in rate limits and private cache entries. API keys are told apart by name,
+// as keys without user id or acting as the same user must not share them
+func (i *Identity) subject() string {
+	if i.AuthMethod == AuthMethodAPIKey {
+		return "key:" + i.APIKey
+	}
+	return fmt.Sprintf("user:%d", i.UserId)
+}
+
+func (i *Identity) toProto() *proto.RestIdentity {
+	if i == nil {
+		return nil
+	}
+	result := &proto.RestIdentity{
+		UserId:     i.UserId,
+		AuthMethod: i.AuthMethod,
+		ApiKey:     i.APIKey,
+	}
+	keys := make([]string, 0, len(i.Claims))
+	for key := range i.Claims {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		result.Claims = append(result.Claims, &proto.RestClaim{
+			Key:    key,
+			Values: i.Claims[key],
+		})
+	}
+	return result
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/savageking-io/ogbrest/proto"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIdentity_toProto(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity *Identity
+		want     string
+	}{
+		{"Anonymous", nil, "<nil>"},
+		{"Token", &Identity{UserId: 7, AuthMethod: AuthMethodJWT, Claims: map[string][]string{"scope": {"chat"}, "roles": {"player", "moderator"}}}, "7 jwt  [roles=[player moderator] scope=[chat]]"},
+		{"API key", &Identity{AuthMethod: AuthMethodAPIKey, APIKey: "matchmaking", Claims: map[string][]string{"owner": {"servers"}}}, "0 api_key matchmaking [owner=[servers]]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatIdentity(tt.identity.toProto()); got != tt.want {
+				t.Errorf("toProto() got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// formatIdentity prints identity with claims in the order they are sent
+func formatIdentity(identity *proto.RestIdentity) string {
+	if identity == nil {
+		return "<nil>"
+	}
+	claims := make([]string, 0, len(identity.Claims))
+	for _, claim := range identity.Claims {
+		claims = append(claims, fmt.Sprintf("%s=%v", claim.Key, claim.Values))
+	}
+	return fmt.Sprintf("%d %s %s %v", identity.UserId, identity.AuthMethod, identity.ApiKey, claims)
+}
+
+func TestREST_JWTMiddleware_ForwardsIdentity(t *testing.T) {
+	r := &REST{remote: &fakeTokenValidator{valid: true, userId: 7}}
+	r.routes = NewRouteTable(r.JWTMiddleware())
+	var request *proto.RestApiRequest
+	_ = r.routes.ReplaceServiceRoutes("user", []*RouteEntry{
+		{Method: "GET", Pattern: "/user/profile", Handler: func(w http.ResponseWriter, req *http.Request) {
+			request = r.httpRequestHeadToProto(req)
+		}},
+		{Method: "GET", Pattern: "/user/public", Auth: AuthPolicyNone, Handler: func(w http.ResponseWriter, req *http.Request) {
+			request = r.httpRequestHeadToProto(req)
+		}},
+	})
+	token := signToken(t, jwt.SigningMethodHS256, []byte("secret"), "", jwt.MapClaims{"sub": 7, "roles": []string{"player"}})
+	tests := []struct {
+		name  string
+		path  string
+		token string
+		want  string
+	}{
+		{"Validated by the user service", "/user/profile", "Bearer " + token, "7 jwt  [roles=[player] sub=[7]]"},
+		{"Opaque token", "/user/profile", "Bearer opaque", "7 jwt  []"},
+		{"Endpoint without auth", "/user/public", "", "<nil>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request = nil
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", tt.token)
+			}
+			r.routes.ServeHTTP(httptest.NewRecorder(), req)
+			if request == nil {
+				t.Fatalf("%s didn't reach the handler", tt.path)
+			}
+			if got := formatIdentity(request.Identity); got != tt.want {
+				t.Errorf("forwarded identity got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
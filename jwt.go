@@ -0,0 +1,468 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	AuthModeRemote = "remote" // Every token is validated by the user service
+	AuthModeLocal  = "local"  // Tokens are verified by the gateway with signing keys
+)
+
+const (
+	DefaultKeysRefreshInterval = 15 * time.Minute
+	DefaultUserIdClaim         = "sub"
+	// minKeysRefreshInterval limits refreshes triggered by tokens signed with unknown keys
+	minKeysRefreshInterval = 10 * time.Second
+	keysFetchTimeout       = 10 * time.Second
+)
+
+// DefaultJWTAlgorithms are signing algorithms accepted when configuration doesn't list them.
+// Only asymmetric algorithms are supported, as the gateway never holds signing secrets
+var DefaultJWTAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// ErrNoSigningKey is returned when the token can't be verified because its signing key is not known.
+// Such tokens are validated by the user service when fallback is enabled
+var ErrNoSigningKey = errors.New("no signing key for token")
+
+var (
+	ErrInvalidToken           = errors.New("invalid or expired token")
+	ErrUserServiceUnavailable = errors.New("user service is not initialized")
+)
+
+// TokenValidator validates tokens remotely. Implemented by the user service client
+type TokenValidator interface {
+	ValidateToken(ctx context.Context, token string) (bool, int32, error)
+}
+
+// keySet is an immutable set of signing keys
+type keySet struct {
+	byId      map[string]crypto.PublicKey // Keys of JWKS document by key id
+	anonymous []crypto.PublicKey          // Keys without id, e.g. PEM keys. Tried only for tokens without key id
+	fetchedAt time.Time
+}
+
+// TokenVerifier verifies signature, expiry and claims of JWT locally. Signing keys come from a JWKS
+// document and PEM files and are refreshed periodically, so rotated keys are picked up
+type TokenVerifier struct {
+	jwksURL     string
+	publicKeys  []string
+	interval    time.Duration
+	userIdClaim string
+	parser      *jwt.Parser
+	httpClient  *http.Client
+	keys        atomic.Pointer[keySet]
+	refreshing  atomic.Bool
+	lastRefresh atomic.Int64 // Unix nanoseconds of the last refresh attempt
+	stop        chan struct{}
+	stopOnce    sync.Once
+}
+
+// NewTokenVerifier loads signing keys. Failing to load them is not fatal: keys are fetched again on refresh
+func NewTokenVerifier(config AuthConfig) (*TokenVerifier, error) {
+	if config.JWKSURL == "" && len(config.PublicKeys) == 0 {
+		return nil, fmt.Errorf("jwks_url or public_keys are required to verify tokens locally")
+	}
+	v := &TokenVerifier{
+		jwksURL:     config.JWKSURL,
+		publicKeys:  config.PublicKeys,
+		interval:    config.RefreshInterval,
+		userIdClaim: config.UserIdClaim,
+		httpClient:  &http.Client{Timeout: keysFetchTimeout},
+		stop:        make(chan struct{}),
+	}
+	if v.interval <= 0 {
+		v.interval = DefaultKeysRefreshInterval
+	}
+	if v.userIdClaim == "" {
+		v.userIdClaim = DefaultUserIdClaim
+	}
+	algorithms := config.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = DefaultJWTAlgorithms
+	}
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(algorithms),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(config.Leeway),
+	}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+	v.parser = jwt.NewParser(options...)
+
+	if err := v.Refresh(context.Background()); err != nil {
+		log.Errorf("Failed to load signing keys: %s", err.Error())
+	}
+	return v, nil
+}
+
+// Start refreshes keys periodically until Stop is called
+func (v *TokenVerifier) Start() {
+	log.Traceln("TokenVerifier::Start")
+	go func() {
+		ticker := time.NewTicker(v.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-v.stop:
+				return
+			case <-ticker.C:
+				if err := v.Refresh(context.Background()); err != nil {
+					log.Errorf("Failed to refresh signing keys: %s", err.Error())
+				}
+			}
+		}
+	}()
+}
+
+func (v *TokenVerifier) Stop() {
+	if v == nil {
+		return
+	}
+	v.stopOnce.Do(func() {
+		close(v.stop)
+	})
+}
+
+// Refresh loads keys from JWKS document and PEM files. When one of them fails to load, its previous keys
+// are kept and keys of the other one are still updated
+func (v *TokenVerifier) Refresh(ctx context.Context) error {
+	log.Traceln("TokenVerifier::Refresh")
+	v.lastRefresh.Store(time.Now().UnixNano())
+	previous := v.keys.Load()
+	if previous == nil {
+		previous = &keySet{}
+	}
+	keys := &keySet{byId: previous.byId, anonymous: previous.anonymous, fetchedAt: time.Now()}
+	var errs []error
+	loaded := false
+	if v.jwksURL != "" {
+		fetched := &keySet{byId: make(map[string]crypto.PublicKey)}
+		if err := v.fetchJWKS(ctx, fetched); err != nil {
+			errs = append(errs, err)
+		} else {
+			keys.byId = fetched.byId
+			loaded = true
+		}
+	}
+	if len(v.publicKeys) > 0 {
+		if anonymous, err := v.readPublicKeys(); err != nil {
+			errs = append(errs, err)
+		} else {
+			keys.anonymous = anonymous
+			loaded = true
+		}
+	}
+	if loaded {
+		v.keys.Store(keys)
+		log.Debugf("Loaded %d signing keys with id and %d without", len(keys.byId), len(keys.anonymous))
+	}
+	return errors.Join(errs...)
+}
+
+// readPublicKeys reads all the PEM files. Keys are used only if every file is read
+func (v *TokenVerifier) readPublicKeys() ([]crypto.PublicKey, error) {
+	var result []crypto.PublicKey
+	for _, path := range v.publicKeys {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public key %s: %w", path, err)
+		}
+		parsed, err := parsePEMPublicKeys(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+		}
+		result = append(result, parsed...)
+	}
+	return result, nil
+}
+
+func (v *TokenVerifier) fetchJWKS(ctx context.Context, keys *keySet) error {
+	ctx, cancel := context.WithTimeout(ctx, keysFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.jwksURL, nil)
+	if err != nil {
+		return err
+	}
+	response, err := v.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: %s", response.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read JWKS: %w", err)
+	}
+	return parseJWKS(body, keys)
+}
+
+// refreshSoon refreshes keys in background, at most once per minKeysRefreshInterval
+func (v *TokenVerifier) refreshSoon() {
+	if time.Since(time.Unix(0, v.lastRefresh.Load())) < minKeysRefreshInterval || !v.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer v.refreshing.Store(false)
+		if err := v.Refresh(context.Background()); err != nil {
+			log.Errorf("Failed to refresh signing keys: %s", err.Error())
+		}
+	}()
+}
+
+// Verify checks signature, expiry and claims of the token and returns the caller it was issued to.
+// ErrNoSigningKey is returned when the token is signed with a key the gateway doesn't know
+func (v *TokenVerifier) Verify(token string) (*Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, v.keyFunc)
+	if err != nil {
+		if errors.Is(err, ErrNoSigningKey) {
+			return nil, ErrNoSigningKey
+		}
+		return nil, err
+	}
+	userId, err := claimUserId(claims[v.userIdClaim])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s claim: %w", v.userIdClaim, err)
+	}
+	return &Identity{UserId: userId, Claims: claimValues(claims), AuthMethod: AuthMethodJWT}, nil
+}
+
+func (v *TokenVerifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	keys := v.keys.Load()
+	if keys == nil {
+		v.refreshSoon()
+		return nil, ErrNoSigningKey
+	}
+	if id, ok := token.Header["kid"].(string); ok && id != "" {
+		if key, found := keys.byId[id]; found {
+			return key, nil
+		}
+		// Key may have been rotated since the last refresh. Keys without id are not tried, as the token
+		// names a key the gateway doesn't know
+		v.refreshSoon()
+		return nil, ErrNoSigningKey
+	}
+	if len(keys.anonymous) == 0 {
+		return nil, ErrNoSigningKey
+	}
+	set := jwt.VerificationKeySet{}
+	for _, key := range keys.anonymous {
+		set.Keys = append(set.Keys, key)
+	}
+	return set, nil
+}
+
+// claimUserId accepts numeric user id as a number or a string
+func claimUserId(value interface{}) (int32, error) {
+	switch id := value.(type) {
+	case float64:
+		if id != float64(int32(id)) {
+			return 0, fmt.Errorf("%v is not a valid user id", id)
+		}
+		return int32(id), nil
+	case string:
+		parsed, err := strconv.ParseInt(id, 10, 32)
+		if err != nil {
+			return 0, err
+		}
+		return int32(parsed), nil
+	case nil:
+		return 0, fmt.Errorf("claim is missing")
+	}
+	return 0, fmt.Errorf("unsupported type %T", value)
+}
+
+// unverifiedClaims returns claims of JWT without verifying its signature, or nil if token is not JWT.
+// Only for tokens already validated by the user service
+func unverifiedClaims(token string) map[string][]string {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return nil
+	}
+	return claimValues(claims)
+}
+
+// claimValues converts claims to Identity claims. Arrays keep every value, other values are formatted
+func claimValues(claims jwt.MapClaims) map[string][]string {
+	result := make(map[string][]string, len(claims))
+	for key, value := range claims {
+		switch v := value.(type) {
+		case string:
+			result[key] = []string{v}
+		case []interface{}:
+			values := make([]string, 0, len(v))
+			for _, item := range v {
+				values = append(values, fmt.Sprint(item))
+			}
+			result[key] = values
+		case float64:
+			result[key] = []string{strconv.FormatFloat(v, 'f', -1, 64)}
+		case bool:
+			result[key] = []string{strconv.FormatBool(v)}
+		}
+	}
+	return result
+}
+
+// jsonWebKey is a single key of JWKS document. Only public key parameters are read
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS adds signature keys of the document to the set. Keys of unsupported types are skipped
+func parseJWKS(data []byte, keys *keySet) error {
+	var document struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("invalid JWKS: %w", err)
+	}
+	for _, jwk := range document.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			log.Warnf("Skipping JWKS key %s: %s", jwk.Kid, err.Error())
+			continue
+		}
+		if jwk.Kid == "" {
+			keys.anonymous = append(keys.anonymous, key)
+			continue
+		}
+		keys.byId[jwk.Kid] = key
+	}
+	return nil
+}
+
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// parsePEMPublicKeys reads every public key or certificate of PEM data
+func parsePEMPublicKeys(data []byte) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		case "RSA PUBLIC KEY":
+			key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		case "CERTIFICATE":
+			certificate, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, certificate.PublicKey)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no public keys found")
+	}
+	return keys, nil
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeTokenValidator struct {
+	valid  bool
+	userId int32
+	calls  int
+}
+
+func (f *fakeTokenValidator) ValidateToken(ctx context.Context, token string) (bool, int32, error) {
+	f.calls++
+	return f.valid, f.userId, nil
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func jwksServer(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) *httptest.Server {
+	t.Helper()
+	encode := func(value *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(value.Bytes())
+	}
+	document := map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": encode(rsaKey.N), "e": encode(big.NewInt(int64(rsaKey.E)))},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": encode(ecKey.X), "y": encode(ecKey.Y)},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": encode(rsaKey.N), "e": encode(big.NewInt(int64(rsaKey.E)))},
+	}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_ = json.NewEncoder(w).Encode(document)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestTokenVerifier_Verify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := jwksServer(t, rsaKey, ecKey)
+
+	verifier, err := NewTokenVerifier(AuthConfig{JWKSURL: server.URL, Issuer: "ogbuser"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := func(sub interface{}, expires time.Duration) jwt.MapClaims {
+		return jwt.MapClaims{"sub": sub, "iss": "ogbuser", "exp": time.Now().Add(expires).Unix(), "roles": []string{"admin", "qa"}}
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		want    int32
+		wantErr error
+	}{
+		{"RSA", signToken(t, jwt.SigningMethodRS256, rsaKey, "rsa", claims(42, time.Minute)), 42, nil},
+		{"EC with string subject", signToken(t, jwt.SigningMethodES256, ecKey, "ec", claims("7", time.Minute)), 7, nil},
+		{"Expired", signToken(t, jwt.SigningMethodRS256, rsaKey, "rsa", claims(42, -time.Minute)), 0, jwt.ErrTokenExpired},
+		{"Bad signature", signToken(t, jwt.SigningMethodRS256, otherKey, "rsa", claims(42, time.Minute)), 0, jwt.ErrTokenSignatureInvalid},
+		{"Unknown key", signToken(t, jwt.SigningMethodRS256, otherKey, "other", claims(42, time.Minute)), 0, ErrNoSigningKey},
+		{"Encryption key", signToken(t, jwt.SigningMethodRS256, rsaKey, "enc", claims(42, time.Minute)), 0, ErrNoSigningKey},
+		{"Wrong issuer", signToken(t, jwt.SigningMethodRS256, rsaKey, "rsa", jwt.MapClaims{"sub": 42, "iss": "other", "exp": time.Now().Add(time.Minute).Unix()}), 0, jwt.ErrTokenInvalidIssuer},
+		{"Symmetric algorithm", signToken(t, jwt.SigningMethodHS256, []byte("secret"), "rsa", claims(42, time.Minute)), 0, jwt.ErrTokenSignatureInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := verifier.Verify(tt.token)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if identity.UserId != tt.want {
+				t.Errorf("Verify() user id got = %d, want %d", identity.UserId, tt.want)
+			}
+			if roles := identity.Claims["roles"]; len(roles) != 2 || roles[0] != "admin" {
+				t.Errorf("Verify() roles got = %v", roles)
+			}
+		})
+	}
+}
+
+// writePublicKey writes public part of the key to a PEM file and returns its path
+func writePublicKey(t *testing.T, key *rsa.PrivateKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "user.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTokenVerifier_PublicKeys(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := writePublicKey(t, key)
+
+	verifier, err := NewTokenVerifier(AuthConfig{PublicKeys: []string{path}, UserIdClaim: "uid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := signToken(t, jwt.SigningMethodPS256, key, "", jwt.MapClaims{"uid": 5, "exp": time.Now().Add(time.Minute).Unix()})
+	identity, err := verifier.Verify(token)
+	if err != nil || identity.UserId != 5 {
+		t.Errorf("Verify() got = %v, %v", identity, err)
+	}
+	token = signToken(t, jwt.SigningMethodRS256, key, "", jwt.MapClaims{"uid": 5})
+	if _, err := verifier.Verify(token); err == nil {
+		t.Errorf("Verify() accepted token without expiry")
+	}
+
+	if _, err := NewTokenVerifier(AuthConfig{}); err == nil {
+		t.Errorf("NewTokenVerifier() accepted configuration without keys")
+	}
+}
+
+func TestTokenVerifier_Refresh(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unavailable atomic.Bool
+	var fetches atomic.Int32
+	jwks := jwksServer(t, rsaKey, ecKey)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fetches.Add(1)
+		if unavailable.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		jwks.Config.Handler.ServeHTTP(w, req)
+	}))
+	t.Cleanup(server.Close)
+	path := writePublicKey(t, pemKey)
+
+	// JWKS is down at start, so only PEM keys are loaded
+	unavailable.Store(true)
+	verifier, err := NewTokenVerifier(AuthConfig{JWKSURL: server.URL, PublicKeys: []string{path}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := time.Now().Add(time.Minute).Unix()
+	jwksToken := signToken(t, jwt.SigningMethodRS256, rsaKey, "rsa", jwt.MapClaims{"sub": 1, "exp": expires})
+	pemToken := signToken(t, jwt.SigningMethodRS256, pemKey, "", jwt.MapClaims{"sub": 2, "exp": expires})
+	rotatedToken := signToken(t, jwt.SigningMethodRS256, pemKey, "rotated", jwt.MapClaims{"sub": 2, "exp": expires})
+	if _, err := verifier.Verify(pemToken); err != nil {
+		t.Errorf("Verify() of PEM key error = %v", err)
+	}
+
+	// Key id of the token is unknown, so keys are refreshed. PEM file can't be read this time
+	unavailable.Store(false)
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	verifier.lastRefresh.Store(0)
+	if _, err := verifier.Verify(jwksToken); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("Verify() of unknown key id error = %v, want %v", err, ErrNoSigningKey)
+	}
+	for deadline := time.Now().Add(time.Second); fetches.Load() < 2 || verifier.refreshing.Load(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("unknown key id didn't trigger refresh")
+		}
+	}
+
+	verify := func(step string) {
+		t.Helper()
+		for _, token := range []string{jwksToken, pemToken} {
+			if _, err := verifier.Verify(token); err != nil {
+				t.Errorf("%s: Verify() error = %v", step, err)
+			}
+		}
+		// Token names a key of neither source, PEM keys are not tried even though one of them signed it
+		if _, err := verifier.Verify(rotatedToken); !errors.Is(err, ErrNoSigningKey) {
+			t.Errorf("%s: Verify() of unknown key id error = %v, want %v", step, err, ErrNoSigningKey)
+		}
+	}
+	verify("Fetched JWKS and previous PEM keys")
+
+	unavailable.Store(true)
+	if err := verifier.Refresh(context.Background()); err == nil {
+		t.Errorf("Refresh() error is nil without any source")
+	}
+	verify("No source")
+}
+
+func TestREST_authenticate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewTokenVerifier(AuthConfig{JWKSURL: jwksServer(t, key, ecKey).URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := signToken(t, jwt.SigningMethodRS256, key, "rsa", jwt.MapClaims{"sub": 1, "exp": time.Now().Add(time.Minute).Unix()})
+	expired := signToken(t, jwt.SigningMethodRS256, key, "rsa", jwt.MapClaims{"sub": 1, "exp": time.Now().Add(-time.Minute).Unix()})
+	unknown := signToken(t, jwt.SigningMethodRS256, key, "legacy", jwt.MapClaims{"sub": 1, "exp": time.Now().Add(time.Minute).Unix()})
+
+	tests := []struct {
+		name      string
+		verifier  *TokenVerifier
+		fallback  bool
+		remote    *fakeTokenValidator
+		token     string
+		want      int32
+		wantErr   error
+		wantCalls int
+	}{
+		{"Remote", nil, false, &fakeTokenValidator{valid: true, userId: 9}, valid, 9, nil, 1},
+		{"Remote invalid", nil, false, &fakeTokenValidator{}, valid, 0, ErrInvalidToken, 1},
+		{"Remote not initialized", nil, false, nil, valid, 0, ErrUserServiceUnavailable, 0},
+		{"Local", verifier, true, &fakeTokenValidator{valid: true, userId: 9}, valid, 1, nil, 0},
+		{"Local expired is not sent to user service", verifier, true, &fakeTokenValidator{valid: true, userId: 9}, expired, 0, ErrInvalidToken, 0},
+		{"Unknown key with fallback", verifier, true, &fakeTokenValidator{valid: true, userId: 9}, unknown, 9, nil, 1},
+		{"Unknown key without fallback", verifier, false, &fakeTokenValidator{valid: true, userId: 9}, unknown, 0, ErrInvalidToken, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &REST{verifier: tt.verifier, authFallback: tt.fallback}
+			if tt.remote != nil {
+				r.remote = tt.remote
+			}
+			identity, err := r.authenticate(context.Background(), tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && identity.UserId != tt.want {
+				t.Errorf("authenticate() user id got = %d, want %d", identity.UserId, tt.want)
+			}
+			if tt.remote != nil && tt.remote.calls != tt.wantCalls {
+				t.Errorf("user service calls got = %d, want %d", tt.remote.calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -6,6 +6,8 @@ import (
 	log "github.com/sirupsen/logrus"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	kafka "github.com/segmentio/kafka-go"
@@ -20,8 +22,27 @@ type Config struct {
 }
 
 type Publisher struct {
-	writer  *kafka.Writer
-	enabled bool
+	writer    *kafka.Writer
+	enabled   bool
+	closed    bool
+	pending   sync.WaitGroup // Events being published in background
+	closeLock sync.RWMutex
+	brokers   []string
+	topic     string
+	published atomic.Uint64
+	failed    atomic.Uint64
+	lastError string
+	errMutex  sync.Mutex
+}
+
+// Status describes publisher configuration and delivery counters
+type Status struct {
+	Enabled   bool     `json:"enabled"`
+	Brokers   []string `json:"brokers,omitempty"`
+	Topic     string   `json:"topic,omitempty"`
+	Published uint64   `json:"published"`
+	Failed    uint64   `json:"failed"`
+	LastError string   `json:"last_error,omitempty"`
 }
 
 type RequestSchema struct {
@@ -32,6 +53,17 @@ type RequestSchema struct {
 	Source  string            `json:"source"`
 }
 
+// RateLimitSchema describes request rejected by rate limiting
+type RateLimitSchema struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Route  string `json:"route,omitempty"` // Pattern of the route
+	Source string `json:"source"`
+	UserId int32  `json:"user_id,omitempty"`
+	Key    string `json:"key"`    // What clients are told apart by
+	Policy string `json:"policy"` // Limit that was exceeded in RateLimit-Policy format
+}
+
 var eventPublisher = &Publisher{}
 
 func parseCompression(compression string) kafka.Compression {
@@ -79,6 +111,8 @@ func (p *Publisher) Init(cfg Config) error {
 		RequiredAcks:           acks,
 	}
 	p.enabled = true
+	p.brokers = cfg.Brokers
+	p.topic = cfg.Topic
 	if cfg.ClientID != "" {
 		p.writer.Transport = &kafka.Transport{ClientID: cfg.ClientID}
 	}
@@ -86,7 +120,14 @@ func (p *Publisher) Init(cfg Config) error {
 	return nil
 }
 
+// Close waits for events being published in background and closes the writer. Events logged
+// after that are dropped
 func (p *Publisher) Close() error {
+	log.Traceln("Kafka::Publisher::Close")
+	p.closeLock.Lock()
+	p.closed = true
+	p.closeLock.Unlock()
+	p.pending.Wait()
 	if p.writer != nil {
 		return p.writer.Close()
 	}
@@ -104,16 +145,70 @@ func (p *Publisher) Publish(ctx context.Context, key, value []byte) error {
 		Value: value,
 		Time:  time.Now(),
 	}
-	return p.writer.WriteMessages(ctx, msg)
+	if err := p.writer.WriteMessages(ctx, msg); err != nil {
+		p.failed.Add(1)
+		p.errMutex.Lock()
+		p.lastError = err.Error()
+		p.errMutex.Unlock()
+		return err
+	}
+	p.published.Add(1)
+	return nil
+}
+
+// Status returns a snapshot of the publisher state
+func (p *Publisher) Status() Status {
+	p.errMutex.Lock()
+	defer p.errMutex.Unlock()
+	return Status{
+		Enabled:   p.enabled,
+		Brokers:   p.brokers,
+		Topic:     p.topic,
+		Published: p.published.Load(),
+		Failed:    p.failed.Load(),
+		LastError: p.lastError,
+	}
 }
 
 func (p *Publisher) LogRequest(req *http.Request) {
 	log.Traceln("Kafka::Publisher::LogRequest")
+	p.publishAsync("request", func() {
+		p.logRequestInternal(req)
+	})
+}
+
+// LogRateLimited publishes event about the rejected request
+func (p *Publisher) LogRateLimited(event RateLimitSchema) {
+	log.Traceln("Kafka::Publisher::LogRateLimited")
+	p.publishAsync("rate limit event", func() {
+		data, err := json.Marshal(event)
+		if err != nil {
+			log.Errorf("Failed to marshal rate limit event: %s", err.Error())
+			return
+		}
+		if err := p.Publish(context.Background(), []byte("rate_limit"), data); err != nil {
+			log.Errorf("Failed to publish rate limit event to Kafka: %s", err.Error())
+		}
+	})
+}
+
+// publishAsync runs publish in background unless the publisher is disabled or closed
+func (p *Publisher) publishAsync(what string, publish func()) {
 	if !p.enabled {
-		log.Debugf("Skipping request logging: Kafka not enabled")
+		log.Debugf("Skipping %s logging: Kafka not enabled", what)
+		return
+	}
+	p.closeLock.RLock()
+	defer p.closeLock.RUnlock()
+	if p.closed {
+		log.Debugf("Skipping %s logging: Kafka publisher is closed", what)
 		return
 	}
-	go p.logRequestInternal(req)
+	p.pending.Add(1)
+	go func() {
+		defer p.pending.Done()
+		publish()
+	}()
 }
 
 func (p *Publisher) logRequestInternal(req *http.Request) {
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	ogb "github.com/savageking-io/ogbcommon"
 	user_client "github.com/savageking-io/ogbuser/client"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
@@ -99,8 +102,72 @@ func Serve(c *cli.Context) error {
 		return err
 	}
 
-	if err := rest.Start(); err != nil {
-		log.Errorf("Failed to start REST: %s", err.Error())
+	var admin *Admin
+	if AppConfig.Admin.Port != 0 {
+		admin = &Admin{}
+		if err := admin.Init(&AppConfig.Admin, &rest, &service); err != nil {
+			log.Errorf("Failed to initialize admin API: %s", err.Error())
+			return err
+		}
+		go func() {
+			if err := admin.Start(); err != nil {
+				log.Errorf("Admin API failed: %s", err.Error())
+			}
+		}()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- rest.Start()
+	}()
+
+	select {
+	case err := <-served:
+		if err != nil {
+			log.Errorf("Failed to start REST: %s", err.Error())
+		}
+	case <-ctx.Done():
+		log.Infof("Shutdown signal received")
 	}
+	// Second signal terminates immediately
+	stop()
+
+	shutdown(&rest, admin, &service, userClient)
 	return nil
 }
+
+// shutdown drains in-flight requests first, as they still need connections to services, and then
+// closes connections and flushes pending events
+func shutdown(rest *REST, admin *Admin, service *Service, userClient *user_client.Client) {
+	log.Traceln("shutdown")
+	timeout := AppConfig.Rest.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	log.Infof("Shutting down, draining requests for up to %s", timeout.String())
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if admin != nil {
+		if err := admin.Shutdown(ctx); err != nil {
+			log.Errorf("Failed to shut down admin API: %s", err.Error())
+		}
+	}
+	if err := rest.Shutdown(ctx); err != nil {
+		log.Errorf("Failed to shut down REST: %s", err.Error())
+	}
+	service.Stop()
+	if err := rest.kafka.Close(); err != nil {
+		log.Errorf("Failed to close Kafka publisher: %s", err.Error())
+	}
+	// User client only connects when it's configured
+	if AppConfig.UserClient.Hostname != "" && AppConfig.UserClient.Port != 0 {
+		if err := userClient.Disconnect(); err != nil {
+			log.Errorf("Failed to disconnect user client: %s", err.Error())
+		}
+	}
+	log.Infof("Shutdown complete")
+}
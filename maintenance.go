@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"net/netip"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaintenanceMessage    = "service is under maintenance"
+	DefaultMaintenanceRetryAfter = 5 * time.Minute
+)
+
+// MaintenanceWindow puts all the routes of a service or a single route under maintenance.
+// Requests to them are answered with 503 unless the user or the client IP is allowed
+type MaintenanceWindow struct {
+	Service    string    `json:"service,omitempty"` // Label of the service. Empty for a single route
+	Method     string    `json:"method,omitempty"`
+	Pattern    string    `json:"pattern,omitempty"`     // Full pattern including service root, e.g. /user/{id}
+	Message    string    `json:"message,omitempty"`     // Error returned to clients
+	RetryAfter string    `json:"retry_after,omitempty"` // Duration clients are told to wait, e.g. 10m
+	AllowUsers []int32   `json:"allow_users,omitempty"` // Users that get through, e.g. QA
+	AllowIPs   []string  `json:"allow_ips,omitempty"`   // IPs or CIDR ranges that get through
+	Since      time.Time `json:"since"`
+	retryAfter time.Duration
+	allowIPs   []netip.Prefix
+}
+
+// key identifies the window: one per service and one per route
+func (m *MaintenanceWindow) key() string {
+	if m.Service != "" {
+		return "service:" + m.Service
+	}
+	return "route:" + routeKey(m.Method, m.Pattern)
+}
+
+// allows returns true when the request may get through maintenance
+func (m *MaintenanceWindow) allows(req *http.Request, ip string) bool {
+	if identity := identityFromContext(req.Context()); identity != nil && slices.Contains(m.AllowUsers, identity.UserId) {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	for _, prefix := range m.allowIPs {
+		if prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Maintenance holds services and routes under maintenance. Windows are toggled at runtime through
+// the admin API, while defaults and services under maintenance on start come from configuration
+type Maintenance struct {
+	mutex        sync.RWMutex
+	defaults     MaintenanceConfig
+	forwardedFor bool
+	windows      map[string]*MaintenanceWindow
+}
+
+// NewMaintenance creates maintenance state and puts services listed in the configuration under maintenance.
+// Client IP is taken from X-Forwarded-For when forwardedFor is set
+func NewMaintenance(config MaintenanceConfig, forwardedFor bool) (*Maintenance, error) {
+	if config.Message == "" {
+		config.Message = DefaultMaintenanceMessage
+	}
+	if config.RetryAfter <= 0 {
+		config.RetryAfter = DefaultMaintenanceRetryAfter
+	}
+	m := &Maintenance{
+		defaults:     config,
+		forwardedFor: forwardedFor,
+		windows:      make(map[string]*MaintenanceWindow),
+	}
+	for _, label := range config.Services {
+		if _, err := m.Enable(MaintenanceWindow{Service: label}); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Enable puts the service or the route under maintenance, replacing previous window of it.
+// Settings that are not provided are taken from configuration
+func (m *Maintenance) Enable(window MaintenanceWindow) (*MaintenanceWindow, error) {
+	log.Traceln("Maintenance::Enable")
+	if m == nil {
+		return nil, fmt.Errorf("maintenance is not initialized")
+	}
+	window.Method = strings.ToUpper(window.Method)
+	if window.Service == "" && (window.Method == "" || window.Pattern == "") {
+		return nil, fmt.Errorf("service or method and pattern are required")
+	}
+	if window.Service != "" && (window.Method != "" || window.Pattern != "") {
+		return nil, fmt.Errorf("either service or route can be put under maintenance")
+	}
+	if window.Message == "" {
+		window.Message = m.defaults.Message
+	}
+	window.retryAfter = m.defaults.RetryAfter
+	if window.RetryAfter != "" {
+		retryAfter, err := time.ParseDuration(window.RetryAfter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry_after: %w", err)
+		}
+		window.retryAfter = retryAfter
+	}
+	window.RetryAfter = window.retryAfter.String()
+	if window.AllowUsers == nil {
+		window.AllowUsers = m.defaults.AllowUsers
+	}
+	if window.AllowIPs == nil {
+		window.AllowIPs = m.defaults.AllowIPs
+	}
+	for _, value := range window.AllowIPs {
+		prefix, err := parseIPPrefix(value)
+		if err != nil {
+			return nil, err
+		}
+		window.allowIPs = append(window.allowIPs, prefix)
+	}
+	window.Since = time.Now()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.windows[window.key()] = &window
+	log.Warnf("Maintenance enabled for %s", window.key())
+	return &window, nil
+}
+
+// Disable ends maintenance of the service or the route. Returns false if it wasn't under maintenance
+func (m *Maintenance) Disable(service, method, pattern string) bool {
+	log.Traceln("Maintenance::Disable")
+	if m == nil {
+		return false
+	}
+	window := MaintenanceWindow{Service: service, Method: strings.ToUpper(method), Pattern: pattern}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.windows[window.key()]; !ok {
+		return false
+	}
+	delete(m.windows, window.key())
+	log.Infof("Maintenance disabled for %s", window.key())
+	return true
+}
+
+// Find returns maintenance window of the route. Window of the route itself wins over window of its service
+func (m *Maintenance) Find(route *RouteEntry) *MaintenanceWindow {
+	if m == nil || route == nil {
+		return nil
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if window, ok := m.windows["route:"+routeKey(route.Method, route.Pattern)]; ok {
+		return window
+	}
+	return m.windows["service:"+route.Owner]
+}
+
+// Windows returns all the services and routes under maintenance
+func (m *Maintenance) Windows() []MaintenanceWindow {
+	if m == nil {
+		return nil
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	result := make([]MaintenanceWindow, 0, len(m.windows))
+	for _, window := range m.windows {
+		result = append(result, *window)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].key() < result[j].key()
+	})
+	return result
+}
+
+// parseIPPrefix accepts a single IP or a CIDR range
+func parseIPPrefix(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid IP range %s: %w", value, err)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid IP %s: %w", value, err)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// MaintenanceMiddleware answers requests to routes under maintenance with 503. Must run after
+// JWTMiddleware, so allowed users can be recognized
+func (r *REST) MaintenanceMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			window := r.maintenance.Find(routeFromContext(req.Context()))
+			if window == nil || window.allows(req, clientIP(req, r.maintenance.forwardedFor)) {
+				next.ServeHTTP(w, req)
+				return
+			}
+			log.Debugf("Rejecting %s %s under maintenance of %s", req.Method, req.URL.Path, window.key())
+			w.Header().Set("Retry-After", retryAfterSeconds(window.retryAfter))
+			writeErrorResponse(w, http.StatusServiceUnavailable, window.Message)
+		})
+	}
+}
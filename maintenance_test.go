@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestREST_MaintenanceMiddleware(t *testing.T) {
+	type request struct {
+		method string
+		path   string
+		userId int32 // Anonymous when 0
+		ip     string
+		want   int
+	}
+	tests := []struct {
+		name     string
+		config   MaintenanceConfig
+		windows  []MaintenanceWindow
+		requests []request
+	}{
+		{"No maintenance", MaintenanceConfig{}, nil,
+			[]request{{"GET", "/user/1", 0, "10.0.0.1", http.StatusOK}}},
+		{"Service", MaintenanceConfig{}, []MaintenanceWindow{{Service: "user"}},
+			[]request{{"GET", "/user/1", 0, "10.0.0.1", http.StatusServiceUnavailable}, {"POST", "/user/login", 0, "10.0.0.1", http.StatusServiceUnavailable}, {"GET", "/test", 0, "10.0.0.1", http.StatusOK}}},
+		{"Service from configuration", MaintenanceConfig{Services: []string{"user"}}, nil,
+			[]request{{"GET", "/user/1", 0, "10.0.0.1", http.StatusServiceUnavailable}}},
+		{"Route", MaintenanceConfig{}, []MaintenanceWindow{{Method: "post", Pattern: "/user/login"}},
+			[]request{{"POST", "/user/login", 0, "10.0.0.1", http.StatusServiceUnavailable}, {"GET", "/user/1", 0, "10.0.0.1", http.StatusOK}}},
+		{"Allowed user", MaintenanceConfig{}, []MaintenanceWindow{{Service: "user", AllowUsers: []int32{7}}},
+			[]request{{"GET", "/user/1", 7, "10.0.0.1", http.StatusOK}, {"GET", "/user/1", 8, "10.0.0.1", http.StatusServiceUnavailable}}},
+		{"Allowed IP range", MaintenanceConfig{AllowIPs: []string{"10.1.0.0/16", "192.168.0.5"}}, []MaintenanceWindow{{Service: "user"}},
+			[]request{{"GET", "/user/1", 0, "10.1.2.3", http.StatusOK}, {"GET", "/user/1", 0, "192.168.0.5", http.StatusOK}, {"GET", "/user/1", 0, "10.2.0.1", http.StatusServiceUnavailable}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maintenance, err := NewMaintenance(tt.config, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, window := range tt.windows {
+				if _, err := maintenance.Enable(window); err != nil {
+					t.Fatalf("Enable() error = %v", err)
+				}
+			}
+			r := &REST{maintenance: maintenance}
+			r.routes = NewRouteTable(r.MaintenanceMiddleware())
+			_ = r.routes.ReplaceServiceRoutes("user", []*RouteEntry{
+				{Method: "GET", Pattern: "/user/{id}", Handler: routeHandler(http.StatusOK)},
+				{Method: "POST", Pattern: "/user/login", Handler: routeHandler(http.StatusOK)},
+			})
+			_ = r.routes.ReplaceServiceRoutes("test", []*RouteEntry{{Method: "GET", Pattern: "/test", Handler: routeHandler(http.StatusOK)}})
+
+			for _, rr := range tt.requests {
+				req := httptest.NewRequest(rr.method, rr.path, nil)
+				req.RemoteAddr = rr.ip + ":1234"
+				if rr.userId != 0 {
+					req = req.WithContext(withIdentity(req.Context(), &Identity{UserId: rr.userId}))
+				}
+				w := httptest.NewRecorder()
+				r.routes.ServeHTTP(w, req)
+				if w.Code != rr.want {
+					t.Errorf("%s %s from %s got = %d, want %d", rr.method, rr.path, rr.ip, w.Code, rr.want)
+				}
+				if w.Code == http.StatusServiceUnavailable && w.Header().Get("Retry-After") != "300" {
+					t.Errorf("Retry-After got = %s, want 300", w.Header().Get("Retry-After"))
+				}
+			}
+
+			if len(tt.windows) > 0 && !maintenance.Disable(tt.windows[0].Service, tt.windows[0].Method, tt.windows[0].Pattern) {
+				t.Errorf("Disable() got = false, want true")
+			}
+		})
+	}
+}
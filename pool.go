@@ -0,0 +1,511 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/savageking-io/ogbrest/proto"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	protobuf "google.golang.org/protobuf/proto"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	BalancerRoundRobin       = "round_robin"
+	BalancerLeastOutstanding = "least_outstanding"
+)
+
+const (
+	DefaultMaxFailures  = 5
+	DefaultEjectionTime = 30 * time.Second
+)
+
+// ErrNoInstances is returned when service has no instances to send the request to
+var ErrNoInstances = errors.New("no instances available")
+
+// ErrNoHealthyInstances is returned when none of the service instances is ready to handle requests
+var ErrNoHealthyInstances = errors.New("no healthy instances available")
+
+// Backend serves requests of a single service
+type Backend interface {
+	ServiceLabel() string
+	RequestContext(parent context.Context, endpoint *proto.RestEndpoint) (context.Context, context.CancelFunc)
+	HandleRestRequest(ctx context.Context, endpoint *proto.RestEndpoint, request *proto.RestApiRequest) (*proto.RestApiResponse, error)
+	NewRestStream(ctx context.Context, head *proto.RestApiRequest) (proto.RestInterService_NewRestStreamRequestClient, error)
+}
+
+// ServiceRoutesHandler A handle from REST to replace routes of the service served by backend
+type ServiceRoutesHandler func(root string, endpoints []*proto.RestEndpoint, backend Backend) error
+
+// ServiceEventHandler A handle from REST to apply events of the service. Root is the root of service routes
+type ServiceEventHandler func(label string, root string, event *proto.GatewayEvent)
+
+// poolInstance is a single instance of the service with its balancing state
+type poolInstance struct {
+	client       *Client
+	outstanding  atomic.Int64
+	failures     atomic.Int32
+	ejectedUntil atomic.Int64 // Unix nanoseconds. Instance is not picked until then
+}
+
+func (i *poolInstance) isEjected(now time.Time) bool {
+	return now.UnixNano() < i.ejectedUntil.Load()
+}
+
+// isAvailable returns true when instance is neither ejected nor rejecting requests with its circuit breaker
+func (i *poolInstance) isAvailable(now time.Time) bool {
+	return !i.isEjected(now) && i.client.breaker.Available()
+}
+
+// InstanceStatus is a snapshot of the instance used for diagnostics
+type InstanceStatus struct {
+	ClientStatus
+	Outstanding  int64      `json:"outstanding"`
+	Failures     int32      `json:"failures"`
+	EjectedUntil *time.Time `json:"ejected_until,omitempty"`
+}
+
+// ServiceStatus is a snapshot of the pool used for diagnostics
+type ServiceStatus struct {
+	Label     string           `json:"label"`
+	Balancer  string           `json:"balancer"`
+	Instances []InstanceStatus `json:"instances"`
+}
+
+// ServicePool is a set of instances serving the same service label. Requests are balanced between
+// instances and instances that keep failing are ejected for a while
+type ServicePool struct {
+	Label               string
+	config              ServiceConfig
+	balancer            string
+	maxFailures         int32
+	ejectionTime        time.Duration
+	retry               *RetryPolicy
+	mutex               sync.RWMutex
+	instances           []*poolInstance
+	next                atomic.Uint64
+	started             atomic.Bool // Instances added after Start are started right away
+	lastRoutes          *proto.RestDataDefinition
+	routesMutex         sync.Mutex
+	updateRoutesHandler ServiceRoutesHandler
+	removeRoutesHandler RemoveRoutesHandler
+	eventHandler        ServiceEventHandler
+}
+
+func NewServicePool(config *ServiceConfig, updateRoutesHandler ServiceRoutesHandler, removeRoutesHandler RemoveRoutesHandler) (*ServicePool, error) {
+	log.Traceln("ServicePool::NewServicePool")
+	if config == nil {
+		return nil, fmt.Errorf("no service configuration provided")
+	}
+	p := &ServicePool{
+		Label:               config.Label,
+		config:              *config,
+		balancer:            config.Balancer,
+		maxFailures:         int32(config.MaxFailures),
+		ejectionTime:        config.EjectionTime,
+		retry:               NewRetryPolicy(config.Retry),
+		updateRoutesHandler: updateRoutesHandler,
+		removeRoutesHandler: removeRoutesHandler,
+	}
+	if p.balancer == "" {
+		p.balancer = BalancerRoundRobin
+	}
+	if p.balancer != BalancerRoundRobin && p.balancer != BalancerLeastOutstanding {
+		return nil, fmt.Errorf("unknown balancer %s for service [%s]", p.balancer, p.Label)
+	}
+	if p.maxFailures <= 0 {
+		p.maxFailures = DefaultMaxFailures
+	}
+	if p.ejectionTime <= 0 {
+		p.ejectionTime = DefaultEjectionTime
+	}
+	if p.config.RequestTimeout == 0 {
+		p.config.RequestTimeout = DefaultRequestTimeout
+	}
+
+	for _, instance := range config.InstanceList() {
+		if _, err := p.AddInstance(instance.Hostname, instance.Port); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// AddInstance creates client for a new instance of the service. Client is not started
+func (p *ServicePool) AddInstance(hostname string, port uint16) (*Client, error) {
+	log.Traceln("ServicePool::AddInstance")
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, instance := range p.instances {
+		if instance.client.Host == hostname && instance.client.Port == port {
+			return nil, fmt.Errorf("instance %s:%d of [%s] already exists", hostname, port, p.Label)
+		}
+	}
+
+	instanceConfig := p.config
+	instanceConfig.Hostname = hostname
+	instanceConfig.Port = port
+	client := &Client{}
+	if err := client.Init(&instanceConfig, p.handleRestData); err != nil {
+		return nil, err
+	}
+	client.eventHandler = p.handleEvent
+	p.instances = append(p.instances, &poolInstance{client: client})
+	log.Infof("Service [%s] has %d instances", p.Label, len(p.instances))
+	return client, nil
+}
+
+// RemoveInstance stops client of the instance and removes it from the pool
+func (p *ServicePool) RemoveInstance(hostname string, port uint16) error {
+	log.Traceln("ServicePool::RemoveInstance")
+	p.mutex.Lock()
+	var removed *Client
+	for i, instance := range p.instances {
+		if instance.client.Host == hostname && instance.client.Port == port {
+			removed = instance.client
+			p.instances = append(p.instances[:i], p.instances[i+1:]...)
+			break
+		}
+	}
+	remaining := len(p.instances)
+	p.mutex.Unlock()
+
+	if removed == nil {
+		return fmt.Errorf("instance %s:%d of [%s] not found", hostname, port, p.Label)
+	}
+	log.Infof("Service [%s] has %d instances", p.Label, remaining)
+	if remaining == 0 {
+		// Nothing can serve the routes anymore. Next instance will register them again
+		p.resetRoutes()
+	}
+	return removed.Stop()
+}
+
+// SetInstances brings the pool to the given set of instances: missing ones are added and
+// the rest are removed. New instances are started if the pool was started
+func (p *ServicePool) SetInstances(instances []ServiceInstanceConfig) {
+	log.Traceln("ServicePool::SetInstances")
+	wanted := make(map[string]bool)
+	for _, instance := range instances {
+		wanted[instance.Address()] = true
+	}
+
+	existing := make(map[string]bool)
+	for _, client := range p.Instances() {
+		address := ServiceInstanceConfig{Hostname: client.Host, Port: client.Port}.Address()
+		existing[address] = true
+		if wanted[address] {
+			continue
+		}
+		log.Infof("Instance %s of [%s] is gone", address, p.Label)
+		if err := p.RemoveInstance(client.Host, client.Port); err != nil {
+			log.Errorf("Failed to remove instance %s of [%s]: %s", address, p.Label, err.Error())
+		}
+	}
+
+	for _, instance := range instances {
+		if existing[instance.Address()] {
+			continue
+		}
+		log.Infof("Instance %s of [%s] discovered", instance.Address(), p.Label)
+		client, err := p.AddInstance(instance.Hostname, instance.Port)
+		if err != nil {
+			log.Errorf("Failed to add instance %s of [%s]: %s", instance.Address(), p.Label, err.Error())
+			continue
+		}
+		existing[instance.Address()] = true
+		if p.started.Load() {
+			go p.startClient(client)
+		}
+	}
+}
+
+// Instances returns clients of all the instances
+func (p *ServicePool) Instances() []*Client {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	result := make([]*Client, len(p.instances))
+	for i, instance := range p.instances {
+		result[i] = instance.client
+	}
+	return result
+}
+
+// Start connects all the instances. Instances that fail to start will retry later
+func (p *ServicePool) Start() {
+	log.Traceln("ServicePool::Start")
+	p.started.Store(true)
+	for _, client := range p.Instances() {
+		p.startClient(client)
+	}
+}
+
+// startClient starts the client. Client that fails to start keeps reconnecting by itself
+func (p *ServicePool) startClient(client *Client) {
+	if err := client.Start(); err != nil {
+		log.Errorf("Failed to start REST client: %s", err.Error())
+	}
+}
+
+// Stop stops all the instances and removes routes of the service
+func (p *ServicePool) Stop() {
+	log.Traceln("ServicePool::Stop")
+	p.started.Store(false)
+	p.resetRoutes()
+	for _, client := range p.Instances() {
+		if err := client.Stop(); err != nil {
+			log.Errorf("Failed to stop client [%s]: %s", client.Label, err.Error())
+		}
+	}
+}
+
+// Status returns a snapshot of the pool and all its instances
+func (p *ServicePool) Status() ServiceStatus {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	status := ServiceStatus{
+		Label:     p.Label,
+		Balancer:  p.balancer,
+		Instances: make([]InstanceStatus, 0, len(p.instances)),
+	}
+	now := time.Now()
+	for _, instance := range p.instances {
+		instanceStatus := InstanceStatus{
+			ClientStatus: instance.client.Status(),
+			Outstanding:  instance.outstanding.Load(),
+			Failures:     instance.failures.Load(),
+		}
+		if instance.isEjected(now) {
+			ejectedUntil := time.Unix(0, instance.ejectedUntil.Load())
+			instanceStatus.EjectedUntil = &ejectedUntil
+		}
+		status.Instances = append(status.Instances, instanceStatus)
+	}
+	return status
+}
+
+func (p *ServicePool) ServiceLabel() string {
+	return p.Label
+}
+
+func (p *ServicePool) RequestContext(parent context.Context, endpoint *proto.RestEndpoint) (context.Context, context.CancelFunc) {
+	return requestContext(parent, endpoint, p.config.RequestTimeout)
+}
+
+// HandleRestRequest sends the request to one of the instances. Requests that didn't reach the service
+// are retried, possibly on another instance, when the endpoint allows it and retry budget is not exhausted
+func (p *ServicePool) HandleRestRequest(ctx context.Context, endpoint *proto.RestEndpoint, request *proto.RestApiRequest) (*proto.RestApiResponse, error) {
+	log.Traceln("ServicePool::HandleRestRequest")
+	if p.retry != nil {
+		p.retry.budget.Request()
+	}
+	maxAttempts := p.retry.MaxAttempts(endpoint, request)
+	for attempt := 1; ; attempt++ {
+		response, err := p.handleRestRequest(ctx, request)
+		if attempt >= maxAttempts || !isRetryable(err) || ctx.Err() != nil {
+			return response, err
+		}
+		if !p.retry.budget.TryRetry() {
+			log.Warnf("Retry budget of [%s] is exhausted, not retrying %s %s", p.Label, request.Method, request.Uri)
+			return response, err
+		}
+		backoff := p.retry.Backoff(attempt)
+		log.Infof("Retrying %s %s of [%s] in %s, attempt %d of %d: %s", request.Method, request.Uri, p.Label, backoff.String(), attempt+1, maxAttempts, err.Error())
+		if !waitBackoff(ctx, backoff) {
+			return response, err
+		}
+	}
+}
+
+func (p *ServicePool) handleRestRequest(ctx context.Context, request *proto.RestApiRequest) (*proto.RestApiResponse, error) {
+	instance := p.pick()
+	if instance == nil {
+		return nil, p.noInstanceError()
+	}
+	instance.outstanding.Add(1)
+	defer instance.outstanding.Add(-1)
+
+	response, err := instance.client.HandleRestRequest(ctx, request)
+	p.report(ctx, instance, err)
+	return response, err
+}
+
+func (p *ServicePool) NewRestStream(ctx context.Context, head *proto.RestApiRequest) (proto.RestInterService_NewRestStreamRequestClient, error) {
+	log.Traceln("ServicePool::NewRestStream")
+	instance := p.pick()
+	if instance == nil {
+		return nil, p.noInstanceError()
+	}
+	instance.outstanding.Add(1)
+	stream, err := instance.client.NewRestStream(ctx, head)
+	p.report(ctx, instance, err)
+	if err != nil {
+		instance.outstanding.Add(-1)
+		return nil, err
+	}
+	// Stream is in progress until the request is over
+	context.AfterFunc(ctx, func() {
+		instance.outstanding.Add(-1)
+	})
+	return stream, nil
+}
+
+// handleRestData is called by instances when they receive REST data. All instances are expected
+// to provide the same definition, so routes are only replaced when it changes
+func (p *ServicePool) handleRestData(root string, endpoints []*proto.RestEndpoint, client *Client) error {
+	log.Traceln("ServicePool::handleRestData")
+	if !p.hasClient(client) {
+		log.Debugf("Ignoring routes of [%s] from removed instance %s:%d", p.Label, client.Host, client.Port)
+		return nil
+	}
+	p.routesMutex.Lock()
+	defer p.routesMutex.Unlock()
+
+	definition := &proto.RestDataDefinition{Root: root, Endpoints: endpoints}
+	if p.lastRoutes != nil && protobuf.Equal(p.lastRoutes, definition) {
+		log.Debugf("Routes of [%s] didn't change", p.Label)
+		return nil
+	}
+	if p.updateRoutesHandler == nil {
+		return fmt.Errorf("no routes handler")
+	}
+	if err := p.updateRoutesHandler(root, endpoints, p); err != nil {
+		return err
+	}
+	p.lastRoutes = definition
+	return nil
+}
+
+// SetEventHandler sets handler of events sent by instances. Must be called before the pool is started
+func (p *ServicePool) SetEventHandler(handler ServiceEventHandler) {
+	p.eventHandler = handler
+}
+
+// handleEvent is called by instances for every gateway event they receive
+func (p *ServicePool) handleEvent(event *proto.GatewayEvent, client *Client) {
+	log.Traceln("ServicePool::handleEvent")
+	if p.eventHandler == nil || !p.hasClient(client) {
+		return
+	}
+	p.routesMutex.Lock()
+	routes := p.lastRoutes
+	p.routesMutex.Unlock()
+	if routes == nil {
+		log.Debugf("Ignoring event of [%s] without routes", p.Label)
+		return
+	}
+	p.eventHandler(p.Label, routes.Root, event)
+}
+
+// resetRoutes removes routes of the service, so the next instance that provides them registers them again
+func (p *ServicePool) resetRoutes() {
+	p.routesMutex.Lock()
+	defer p.routesMutex.Unlock()
+	p.lastRoutes = nil
+	if p.removeRoutesHandler != nil {
+		p.removeRoutesHandler(p.Label)
+	}
+}
+
+func (p *ServicePool) hasClient(client *Client) bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	for _, instance := range p.instances {
+		if instance.client == client {
+			return true
+		}
+	}
+	return false
+}
+
+// pick selects instance for the next request. Instances that are not ready are never picked. Ejected
+// instances and instances with open circuit breaker are skipped unless none is available - then all
+// ready instances are considered, as failing fast on all requests is not better. Open breaker still
+// rejects the request with a proper error
+func (p *ServicePool) pick() *poolInstance {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	now := time.Now()
+	candidates := make([]*poolInstance, 0, len(p.instances))
+	ready := make([]*poolInstance, 0, len(p.instances))
+	for _, instance := range p.instances {
+		if !instance.client.isServing() {
+			continue
+		}
+		ready = append(ready, instance)
+		if instance.isAvailable(now) {
+			candidates = append(candidates, instance)
+		}
+	}
+	if len(ready) == 0 {
+		return nil
+	}
+	if len(candidates) == 0 {
+		log.Warnf("No available instances of [%s]", p.Label)
+		candidates = ready
+	}
+
+	offset := int(p.next.Add(1) % uint64(len(candidates)))
+	if p.balancer == BalancerRoundRobin {
+		return candidates[offset]
+	}
+
+	// Least outstanding requests. Start from the round robin offset so ties are spread evenly
+	best := candidates[offset]
+	for i := 1; i < len(candidates); i++ {
+		candidate := candidates[(offset+i)%len(candidates)]
+		if candidate.outstanding.Load() < best.outstanding.Load() {
+			best = candidate
+		}
+	}
+	return best
+}
+
+// noInstanceError explains why pick found no instance
+func (p *ServicePool) noInstanceError() error {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if len(p.instances) == 0 {
+		return ErrNoInstances
+	}
+	return ErrNoHealthyInstances
+}
+
+// report updates instance failure counter with the result of the call
+func (p *ServicePool) report(ctx context.Context, instance *poolInstance, err error) {
+	if errors.Is(err, ErrBreakerOpen) || errors.Is(err, ErrBulkheadFull) {
+		// Request never reached the instance
+		return
+	}
+	if !isInstanceFailure(ctx, err) {
+		instance.failures.Store(0)
+		return
+	}
+	if instance.failures.Add(1) < p.maxFailures {
+		return
+	}
+	instance.failures.Store(0)
+	instance.ejectedUntil.Store(time.Now().Add(p.ejectionTime).UnixNano())
+	log.Warnf("Instance %s:%d of [%s] ejected for %s", instance.client.Host, instance.client.Port, p.Label, p.ejectionTime.String())
+}
+
+// isInstanceFailure returns true for errors that indicate a problem with the instance rather than the request
+func isInstanceFailure(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() == context.Canceled {
+		return false
+	}
+	if errors.Is(err, ErrClientNotConnected) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/savageking-io/ogbrest/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+func newTestPool(balancer string, instances int) *ServicePool {
+	p := &ServicePool{
+		Label:        "test",
+		balancer:     balancer,
+		maxFailures:  2,
+		ejectionTime: time.Minute,
+	}
+	for i := 0; i < instances; i++ {
+		p.instances = append(p.instances, &poolInstance{client: &Client{Label: "test", Host: "localhost", Port: uint16(9000 + i), state: ClientStateReady}})
+	}
+	return p
+}
+
+func TestServicePool_pick(t *testing.T) {
+	tests := []struct {
+		name        string
+		balancer    string
+		instances   int
+		outstanding []int64
+		ejected     []bool
+		picks       int
+		want        map[uint16]int // Expected number of picks per instance port
+	}{
+		{"No instances", BalancerRoundRobin, 0, nil, nil, 1, map[uint16]int{}},
+		{"Round robin", BalancerRoundRobin, 3, nil, nil, 6, map[uint16]int{9000: 2, 9001: 2, 9002: 2}},
+		{"Round robin skips ejected", BalancerRoundRobin, 3, nil, []bool{false, true, false}, 4, map[uint16]int{9000: 2, 9002: 2}},
+		{"All ejected", BalancerRoundRobin, 2, nil, []bool{true, true}, 2, map[uint16]int{9000: 1, 9001: 1}},
+		{"Least outstanding", BalancerLeastOutstanding, 3, []int64{5, 1, 3}, nil, 3, map[uint16]int{9001: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPool(tt.balancer, tt.instances)
+			for i, value := range tt.outstanding {
+				p.instances[i].outstanding.Store(value)
+			}
+			for i, ejected := range tt.ejected {
+				if ejected {
+					p.instances[i].ejectedUntil.Store(time.Now().Add(time.Minute).UnixNano())
+				}
+			}
+			got := make(map[uint16]int)
+			for i := 0; i < tt.picks; i++ {
+				if instance := p.pick(); instance != nil {
+					got[instance.client.Port]++
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("pick() got = %v, want %v", got, tt.want)
+				return
+			}
+			for port, count := range tt.want {
+				if got[port] != count {
+					t.Errorf("pick() got = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestServicePool_report(t *testing.T) {
+	p := newTestPool(BalancerRoundRobin, 1)
+	instance := p.instances[0]
+	ctx := context.Background()
+
+	p.report(ctx, instance, status.Error(codes.Unavailable, "unavailable"))
+	if instance.isEjected(time.Now()) {
+		t.Errorf("instance ejected after a single failure")
+	}
+	p.report(ctx, instance, nil)
+	p.report(ctx, instance, status.Error(codes.Unavailable, "unavailable"))
+	if instance.isEjected(time.Now()) {
+		t.Errorf("successful call must reset failures")
+	}
+	p.report(ctx, instance, status.Error(codes.NotFound, "not found"))
+	if instance.isEjected(time.Now()) {
+		t.Errorf("application errors must not count as failures")
+	}
+	p.report(ctx, instance, ErrClientNotConnected)
+	p.report(ctx, instance, status.Error(codes.DeadlineExceeded, "deadline exceeded"))
+	if !instance.isEjected(time.Now()) {
+		t.Errorf("instance must be ejected after %d failures", p.maxFailures)
+	}
+	if instance.isEjected(time.Now().Add(2 * time.Minute)) {
+		t.Errorf("instance must be readmitted after ejection time")
+	}
+}
+
+func TestServicePool_Unhealthy(t *testing.T) {
+	p := newTestPool(BalancerRoundRobin, 2)
+	p.retry = NewRetryPolicy(RetryConfig{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+	p.instances[0].client.state = ClientStateDegraded
+	for i := 0; i < 4; i++ {
+		if instance := p.pick(); instance == nil || instance.client.Port != 9001 {
+			t.Fatalf("pick() got = %v, want instance 9001", instance)
+		}
+	}
+
+	p.instances[1].client.state = ClientStateConnecting
+	_, err := p.HandleRestRequest(context.Background(), &proto.RestEndpoint{}, &proto.RestApiRequest{Method: "GET"})
+	if !errors.Is(err, ErrNoHealthyInstances) {
+		t.Errorf("HandleRestRequest() error = %v, want %v", err, ErrNoHealthyInstances)
+	}
+	if p.retry.budget.retries != 0 {
+		t.Errorf("retries got = %d, want 0", p.retry.budget.retries)
+	}
+}
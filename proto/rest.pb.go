@@ -259,6 +259,13 @@ type RestEndpoint struct {
 	Path               string                 `protobuf:"bytes,1,opt,name=Path,proto3" json:"Path,omitempty"`
 	Method             string                 `protobuf:"bytes,2,opt,name=Method,proto3" json:"Method,omitempty"`
 	SkipAuthMiddleware bool                   `protobuf:"varint,3,opt,name=SkipAuthMiddleware,proto3" json:"SkipAuthMiddleware,omitempty"`
+	Stream             bool                   `protobuf:"varint,4,opt,name=Stream,proto3" json:"Stream,omitempty"`             // Stream endpoints are proxied with NewRestStreamRequest
+	TimeoutMs          int32                  `protobuf:"varint,5,opt,name=TimeoutMs,proto3" json:"TimeoutMs,omitempty"`       // Overrides gateway's request timeout for this endpoint
+	Idempotent         bool                   `protobuf:"varint,6,opt,name=Idempotent,proto3" json:"Idempotent,omitempty"`     // Requests may be retried regardless of method
+	RateLimit          *RestRateLimit         `protobuf:"bytes,7,opt,name=RateLimit,proto3" json:"RateLimit,omitempty"`        // Limits requests to this endpoint per client. Gateway configuration may override it
+	MaxBodyBytes       int64                  `protobuf:"varint,8,opt,name=MaxBodyBytes,proto3" json:"MaxBodyBytes,omitempty"` // Overrides gateway's request body limit. Unlimited when negative
+	Roles              []string               `protobuf:"bytes,9,rep,name=Roles,proto3" json:"Roles,omitempty"`                // Token must carry at least one of the roles
+	Scopes             []string               `protobuf:"bytes,10,rep,name=Scopes,proto3" json:"Scopes,omitempty"`             // Token must carry all the scopes
 	unknownFields      protoimpl.UnknownFields
 	sizeCache          protoimpl.SizeCache
 }
@@ -314,6 +321,123 @@ func (x *RestEndpoint) GetSkipAuthMiddleware() bool {
 	return false
 }
 
+func (x *RestEndpoint) GetStream() bool {
+	if x != nil {
+		return x.Stream
+	}
+	return false
+}
+
+func (x *RestEndpoint) GetTimeoutMs() int32 {
+	if x != nil {
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+func (x *RestEndpoint) GetIdempotent() bool {
+	if x != nil {
+		return x.Idempotent
+	}
+	return false
+}
+
+func (x *RestEndpoint) GetRateLimit() *RestRateLimit {
+	if x != nil {
+		return x.RateLimit
+	}
+	return nil
+}
+
+func (x *RestEndpoint) GetMaxBodyBytes() int64 {
+	if x != nil {
+		return x.MaxBodyBytes
+	}
+	return 0
+}
+
+func (x *RestEndpoint) GetRoles() []string {
+	if x != nil {
+		return x.Roles
+	}
+	return nil
+}
+
+func (x *RestEndpoint) GetScopes() []string {
+	if x != nil {
+		return x.Scopes
+	}
+	return nil
+}
+
+type RestRateLimit struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Requests      int32                  `protobuf:"varint,1,opt,name=Requests,proto3" json:"Requests,omitempty"` // Requests allowed per period. Disabled when 0
+	PeriodMs      int32                  `protobuf:"varint,2,opt,name=PeriodMs,proto3" json:"PeriodMs,omitempty"`
+	Burst         int32                  `protobuf:"varint,3,opt,name=Burst,proto3" json:"Burst,omitempty"` // Requests allowed at once. Defaults to Requests
+	Key           string                 `protobuf:"bytes,4,opt,name=Key,proto3" json:"Key,omitempty"`      // What clients are told apart by: user, ip or user_ip. Defaults to gateway configuration
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestRateLimit) Reset() {
+	*x = RestRateLimit{}
+	mi := &file_rest_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestRateLimit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestRateLimit) ProtoMessage() {}
+
+func (x *RestRateLimit) ProtoReflect() protoreflect.Message {
+	mi := &file_rest_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestRateLimit.ProtoReflect.Descriptor instead.
+func (*RestRateLimit) Descriptor() ([]byte, []int) {
+	return file_rest_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *RestRateLimit) GetRequests() int32 {
+	if x != nil {
+		return x.Requests
+	}
+	return 0
+}
+
+func (x *RestRateLimit) GetPeriodMs() int32 {
+	if x != nil {
+		return x.PeriodMs
+	}
+	return 0
+}
+
+func (x *RestRateLimit) GetBurst() int32 {
+	if x != nil {
+		return x.Burst
+	}
+	return 0
+}
+
+func (x *RestRateLimit) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
 type RestApiRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Uri           string                 `protobuf:"bytes,1,opt,name=Uri,proto3" json:"Uri,omitempty"`
@@ -322,25 +446,480 @@ type RestApiRequest struct {
 	Body          string                 `protobuf:"bytes,4,opt,name=Body,proto3" json:"Body,omitempty"`
 	Source        string                 `protobuf:"bytes,5,opt,name=Source,proto3" json:"Source,omitempty"`
 	Form          []*RestApiFormData     `protobuf:"bytes,6,rep,name=Form,proto3" json:"Form,omitempty"`
+	RawBody       []byte                 `protobuf:"bytes,7,opt,name=RawBody,proto3" json:"RawBody,omitempty"` // Body as received. Body is only filled when the payload is valid UTF-8
+	ContentType   string                 `protobuf:"bytes,8,opt,name=ContentType,proto3" json:"ContentType,omitempty"`
+	Path          string                 `protobuf:"bytes,9,opt,name=Path,proto3" json:"Path,omitempty"` // Concrete request path, while Uri holds the endpoint pattern
+	RawQuery      string                 `protobuf:"bytes,10,opt,name=RawQuery,proto3" json:"RawQuery,omitempty"`
+	Query         []*RestApiFormData     `protobuf:"bytes,11,rep,name=Query,proto3" json:"Query,omitempty"`
+	PathParams    []*RestPathParam       `protobuf:"bytes,12,rep,name=PathParams,proto3" json:"PathParams,omitempty"` // Values of {placeholders} in the endpoint pattern
+	Identity      *RestIdentity          `protobuf:"bytes,13,opt,name=Identity,proto3" json:"Identity,omitempty"`     // Caller authenticated by the gateway. Not set for endpoints that skip auth
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestApiRequest) Reset() {
+	*x = RestApiRequest{}
+	mi := &file_rest_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestApiRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestApiRequest) ProtoMessage() {}
+
+func (x *RestApiRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rest_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestApiRequest.ProtoReflect.Descriptor instead.
+func (*RestApiRequest) Descriptor() ([]byte, []int) {
+	return file_rest_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *RestApiRequest) GetUri() string {
+	if x != nil {
+		return x.Uri
+	}
+	return ""
+}
+
+func (x *RestApiRequest) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *RestApiRequest) GetHeaders() []*RestHeader {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *RestApiRequest) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *RestApiRequest) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *RestApiRequest) GetForm() []*RestApiFormData {
+	if x != nil {
+		return x.Form
+	}
+	return nil
+}
+
+func (x *RestApiRequest) GetRawBody() []byte {
+	if x != nil {
+		return x.RawBody
+	}
+	return nil
+}
+
+func (x *RestApiRequest) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *RestApiRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *RestApiRequest) GetRawQuery() string {
+	if x != nil {
+		return x.RawQuery
+	}
+	return ""
+}
+
+func (x *RestApiRequest) GetQuery() []*RestApiFormData {
+	if x != nil {
+		return x.Query
+	}
+	return nil
+}
+
+func (x *RestApiRequest) GetPathParams() []*RestPathParam {
+	if x != nil {
+		return x.PathParams
+	}
+	return nil
+}
+
+func (x *RestApiRequest) GetIdentity() *RestIdentity {
+	if x != nil {
+		return x.Identity
+	}
+	return nil
+}
+
+type RestIdentity struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        int32                  `protobuf:"varint,1,opt,name=UserId,proto3" json:"UserId,omitempty"`
+	Claims        []*RestClaim           `protobuf:"bytes,2,rep,name=Claims,proto3" json:"Claims,omitempty"`
+	AuthMethod    string                 `protobuf:"bytes,3,opt,name=AuthMethod,proto3" json:"AuthMethod,omitempty"` // jwt for players, api_key for servers and tools
+	ApiKey        string                 `protobuf:"bytes,4,opt,name=ApiKey,proto3" json:"ApiKey,omitempty"`         // Name of the API key the request was authenticated with
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestIdentity) Reset() {
+	*x = RestIdentity{}
+	mi := &file_rest_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestIdentity) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestIdentity) ProtoMessage() {}
+
+func (x *RestIdentity) ProtoReflect() protoreflect.Message {
+	mi := &file_rest_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestIdentity.ProtoReflect.Descriptor instead.
+func (*RestIdentity) Descriptor() ([]byte, []int) {
+	return file_rest_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *RestIdentity) GetUserId() int32 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *RestIdentity) GetClaims() []*RestClaim {
+	if x != nil {
+		return x.Claims
+	}
+	return nil
+}
+
+func (x *RestIdentity) GetAuthMethod() string {
+	if x != nil {
+		return x.AuthMethod
+	}
+	return ""
+}
+
+func (x *RestIdentity) GetApiKey() string {
+	if x != nil {
+		return x.ApiKey
+	}
+	return ""
+}
+
+type RestClaim struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
+	Values        []string               `protobuf:"bytes,2,rep,name=Values,proto3" json:"Values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestClaim) Reset() {
+	*x = RestClaim{}
+	mi := &file_rest_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestClaim) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestClaim) ProtoMessage() {}
+
+func (x *RestClaim) ProtoReflect() protoreflect.Message {
+	mi := &file_rest_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestClaim.ProtoReflect.Descriptor instead.
+func (*RestClaim) Descriptor() ([]byte, []int) {
+	return file_rest_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *RestClaim) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *RestClaim) GetValues() []string {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+type RestApiFormData struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
+	Value         []string               `protobuf:"bytes,2,rep,name=Value,proto3" json:"Value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestApiFormData) Reset() {
+	*x = RestApiFormData{}
+	mi := &file_rest_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestApiFormData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestApiFormData) ProtoMessage() {}
+
+func (x *RestApiFormData) ProtoReflect() protoreflect.Message {
+	mi := &file_rest_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestApiFormData.ProtoReflect.Descriptor instead.
+func (*RestApiFormData) Descriptor() ([]byte, []int) {
+	return file_rest_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *RestApiFormData) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *RestApiFormData) GetValue() []string {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+type RestPathParam struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
+	Value         string                 `protobuf:"bytes,2,opt,name=Value,proto3" json:"Value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestPathParam) Reset() {
+	*x = RestPathParam{}
+	mi := &file_rest_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestPathParam) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestPathParam) ProtoMessage() {}
+
+func (x *RestPathParam) ProtoReflect() protoreflect.Message {
+	mi := &file_rest_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestPathParam.ProtoReflect.Descriptor instead.
+func (*RestPathParam) Descriptor() ([]byte, []int) {
+	return file_rest_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *RestPathParam) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *RestPathParam) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+type RestApiResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          int32                  `protobuf:"varint,1,opt,name=Code,proto3" json:"Code,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=Error,proto3" json:"Error,omitempty"`
+	HttpCode      int32                  `protobuf:"varint,3,opt,name=HttpCode,proto3" json:"HttpCode,omitempty"`
+	Headers       []*RestHeader          `protobuf:"bytes,4,rep,name=Headers,proto3" json:"Headers,omitempty"`
+	Body          string                 `protobuf:"bytes,5,opt,name=Body,proto3" json:"Body,omitempty"`
+	RawBody       []byte                 `protobuf:"bytes,6,opt,name=RawBody,proto3" json:"RawBody,omitempty"` // Takes precedence over Body when not empty
+	ContentType   string                 `protobuf:"bytes,7,opt,name=ContentType,proto3" json:"ContentType,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestApiResponse) Reset() {
+	*x = RestApiResponse{}
+	mi := &file_rest_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestApiResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestApiResponse) ProtoMessage() {}
+
+func (x *RestApiResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_rest_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestApiResponse.ProtoReflect.Descriptor instead.
+func (*RestApiResponse) Descriptor() ([]byte, []int) {
+	return file_rest_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *RestApiResponse) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *RestApiResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *RestApiResponse) GetHttpCode() int32 {
+	if x != nil {
+		return x.HttpCode
+	}
+	return 0
+}
+
+func (x *RestApiResponse) GetHeaders() []*RestHeader {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *RestApiResponse) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *RestApiResponse) GetRawBody() []byte {
+	if x != nil {
+		return x.RawBody
+	}
+	return nil
+}
+
+func (x *RestApiResponse) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+type RestStreamRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*RestStreamRequest_Head
+	//	*RestStreamRequest_Chunk
+	Payload       isRestStreamRequest_Payload `protobuf_oneof:"Payload"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *RestApiRequest) Reset() {
-	*x = RestApiRequest{}
-	mi := &file_rest_proto_msgTypes[5]
+func (x *RestStreamRequest) Reset() {
+	*x = RestStreamRequest{}
+	mi := &file_rest_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *RestApiRequest) String() string {
+func (x *RestStreamRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*RestApiRequest) ProtoMessage() {}
+func (*RestStreamRequest) ProtoMessage() {}
 
-func (x *RestApiRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_rest_proto_msgTypes[5]
+func (x *RestStreamRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rest_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -351,76 +930,158 @@ func (x *RestApiRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use RestApiRequest.ProtoReflect.Descriptor instead.
-func (*RestApiRequest) Descriptor() ([]byte, []int) {
-	return file_rest_proto_rawDescGZIP(), []int{5}
+// Deprecated: Use RestStreamRequest.ProtoReflect.Descriptor instead.
+func (*RestStreamRequest) Descriptor() ([]byte, []int) {
+	return file_rest_proto_rawDescGZIP(), []int{12}
 }
 
-func (x *RestApiRequest) GetUri() string {
+func (x *RestStreamRequest) GetPayload() isRestStreamRequest_Payload {
 	if x != nil {
-		return x.Uri
+		return x.Payload
 	}
-	return ""
+	return nil
 }
 
-func (x *RestApiRequest) GetMethod() string {
+func (x *RestStreamRequest) GetHead() *RestApiRequest {
 	if x != nil {
-		return x.Method
+		if x, ok := x.Payload.(*RestStreamRequest_Head); ok {
+			return x.Head
+		}
 	}
-	return ""
+	return nil
 }
 
-func (x *RestApiRequest) GetHeaders() []*RestHeader {
+func (x *RestStreamRequest) GetChunk() []byte {
 	if x != nil {
-		return x.Headers
+		if x, ok := x.Payload.(*RestStreamRequest_Chunk); ok {
+			return x.Chunk
+		}
 	}
 	return nil
 }
 
-func (x *RestApiRequest) GetBody() string {
+type isRestStreamRequest_Payload interface {
+	isRestStreamRequest_Payload()
+}
+
+type RestStreamRequest_Head struct {
+	Head *RestApiRequest `protobuf:"bytes,1,opt,name=Head,proto3,oneof"`
+}
+
+type RestStreamRequest_Chunk struct {
+	Chunk []byte `protobuf:"bytes,2,opt,name=Chunk,proto3,oneof"`
+}
+
+func (*RestStreamRequest_Head) isRestStreamRequest_Payload() {}
+
+func (*RestStreamRequest_Chunk) isRestStreamRequest_Payload() {}
+
+type RestStreamResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*RestStreamResponse_Head
+	//	*RestStreamResponse_Chunk
+	Payload       isRestStreamResponse_Payload `protobuf_oneof:"Payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestStreamResponse) Reset() {
+	*x = RestStreamResponse{}
+	mi := &file_rest_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestStreamResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestStreamResponse) ProtoMessage() {}
+
+func (x *RestStreamResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_rest_proto_msgTypes[13]
 	if x != nil {
-		return x.Body
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return ""
+	return mi.MessageOf(x)
 }
 
-func (x *RestApiRequest) GetSource() string {
+// Deprecated: Use RestStreamResponse.ProtoReflect.Descriptor instead.
+func (*RestStreamResponse) Descriptor() ([]byte, []int) {
+	return file_rest_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *RestStreamResponse) GetPayload() isRestStreamResponse_Payload {
 	if x != nil {
-		return x.Source
+		return x.Payload
 	}
-	return ""
+	return nil
 }
 
-func (x *RestApiRequest) GetForm() []*RestApiFormData {
+func (x *RestStreamResponse) GetHead() *RestApiResponse {
 	if x != nil {
-		return x.Form
+		if x, ok := x.Payload.(*RestStreamResponse_Head); ok {
+			return x.Head
+		}
 	}
 	return nil
 }
 
-type RestApiFormData struct {
+func (x *RestStreamResponse) GetChunk() []byte {
+	if x != nil {
+		if x, ok := x.Payload.(*RestStreamResponse_Chunk); ok {
+			return x.Chunk
+		}
+	}
+	return nil
+}
+
+type isRestStreamResponse_Payload interface {
+	isRestStreamResponse_Payload()
+}
+
+type RestStreamResponse_Head struct {
+	Head *RestApiResponse `protobuf:"bytes,1,opt,name=Head,proto3,oneof"`
+}
+
+type RestStreamResponse_Chunk struct {
+	Chunk []byte `protobuf:"bytes,2,opt,name=Chunk,proto3,oneof"`
+}
+
+func (*RestStreamResponse_Head) isRestStreamResponse_Payload() {}
+
+func (*RestStreamResponse_Chunk) isRestStreamResponse_Payload() {}
+
+type RestHeader struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Key           string                 `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
-	Value         []string               `protobuf:"bytes,2,rep,name=Value,proto3" json:"Value,omitempty"`
+	Value         string                 `protobuf:"bytes,2,opt,name=Value,proto3" json:"Value,omitempty"`   // First value of the header
+	Values        []string               `protobuf:"bytes,3,rep,name=Values,proto3" json:"Values,omitempty"` // All values of the header. Takes precedence over Value when not empty
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *RestApiFormData) Reset() {
-	*x = RestApiFormData{}
-	mi := &file_rest_proto_msgTypes[6]
+func (x *RestHeader) Reset() {
+	*x = RestHeader{}
+	mi := &file_rest_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *RestApiFormData) String() string {
+func (x *RestHeader) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*RestApiFormData) ProtoMessage() {}
+func (*RestHeader) ProtoMessage() {}
 
-func (x *RestApiFormData) ProtoReflect() protoreflect.Message {
-	mi := &file_rest_proto_msgTypes[6]
+func (x *RestHeader) ProtoReflect() protoreflect.Message {
+	mi := &file_rest_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -431,51 +1092,55 @@ func (x *RestApiFormData) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use RestApiFormData.ProtoReflect.Descriptor instead.
-func (*RestApiFormData) Descriptor() ([]byte, []int) {
-	return file_rest_proto_rawDescGZIP(), []int{6}
+// Deprecated: Use RestHeader.ProtoReflect.Descriptor instead.
+func (*RestHeader) Descriptor() ([]byte, []int) {
+	return file_rest_proto_rawDescGZIP(), []int{14}
 }
 
-func (x *RestApiFormData) GetKey() string {
+func (x *RestHeader) GetKey() string {
 	if x != nil {
 		return x.Key
 	}
 	return ""
 }
 
-func (x *RestApiFormData) GetValue() []string {
+func (x *RestHeader) GetValue() string {
 	if x != nil {
 		return x.Value
 	}
+	return ""
+}
+
+func (x *RestHeader) GetValues() []string {
+	if x != nil {
+		return x.Values
+	}
 	return nil
 }
 
-type RestApiResponse struct {
+type PingMessage struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Code          int32                  `protobuf:"varint,1,opt,name=Code,proto3" json:"Code,omitempty"`
-	Error         string                 `protobuf:"bytes,2,opt,name=Error,proto3" json:"Error,omitempty"`
-	HttpCode      int32                  `protobuf:"varint,3,opt,name=HttpCode,proto3" json:"HttpCode,omitempty"`
-	Headers       []*RestHeader          `protobuf:"bytes,4,rep,name=Headers,proto3" json:"Headers,omitempty"`
-	Body          string                 `protobuf:"bytes,5,opt,name=Body,proto3" json:"Body,omitempty"`
+	SentAt        *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=SentAt,proto3" json:"SentAt,omitempty"`
+	RepliedAt     *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=RepliedAt,proto3" json:"RepliedAt,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *RestApiResponse) Reset() {
-	*x = RestApiResponse{}
-	mi := &file_rest_proto_msgTypes[7]
+func (x *PingMessage) Reset() {
+	*x = PingMessage{}
+	mi := &file_rest_proto_msgTypes[15]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *RestApiResponse) String() string {
+func (x *PingMessage) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*RestApiResponse) ProtoMessage() {}
+func (*PingMessage) ProtoMessage() {}
 
-func (x *RestApiResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_rest_proto_msgTypes[7]
+func (x *PingMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_rest_proto_msgTypes[15]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -486,69 +1151,169 @@ func (x *RestApiResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use RestApiResponse.ProtoReflect.Descriptor instead.
-func (*RestApiResponse) Descriptor() ([]byte, []int) {
-	return file_rest_proto_rawDescGZIP(), []int{7}
+// Deprecated: Use PingMessage.ProtoReflect.Descriptor instead.
+func (*PingMessage) Descriptor() ([]byte, []int) {
+	return file_rest_proto_rawDescGZIP(), []int{15}
 }
 
-func (x *RestApiResponse) GetCode() int32 {
+func (x *PingMessage) GetSentAt() *timestamppb.Timestamp {
 	if x != nil {
-		return x.Code
+		return x.SentAt
 	}
-	return 0
+	return nil
 }
 
-func (x *RestApiResponse) GetError() string {
+func (x *PingMessage) GetRepliedAt() *timestamppb.Timestamp {
 	if x != nil {
-		return x.Error
+		return x.RepliedAt
 	}
-	return ""
+	return nil
 }
 
-func (x *RestApiResponse) GetHttpCode() int32 {
+type GatewayEventsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GatewayEventsRequest) Reset() {
+	*x = GatewayEventsRequest{}
+	mi := &file_rest_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GatewayEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GatewayEventsRequest) ProtoMessage() {}
+
+func (x *GatewayEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_rest_proto_msgTypes[16]
 	if x != nil {
-		return x.HttpCode
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return 0
+	return mi.MessageOf(x)
 }
 
-func (x *RestApiResponse) GetHeaders() []*RestHeader {
+// Deprecated: Use GatewayEventsRequest.ProtoReflect.Descriptor instead.
+func (*GatewayEventsRequest) Descriptor() ([]byte, []int) {
+	return file_rest_proto_rawDescGZIP(), []int{16}
+}
+
+type GatewayEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*GatewayEvent_CachePurge
+	//	*GatewayEvent_TokenRevocation
+	Payload       isGatewayEvent_Payload `protobuf_oneof:"Payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GatewayEvent) Reset() {
+	*x = GatewayEvent{}
+	mi := &file_rest_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GatewayEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GatewayEvent) ProtoMessage() {}
+
+func (x *GatewayEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_rest_proto_msgTypes[17]
 	if x != nil {
-		return x.Headers
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GatewayEvent.ProtoReflect.Descriptor instead.
+func (*GatewayEvent) Descriptor() ([]byte, []int) {
+	return file_rest_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *GatewayEvent) GetPayload() isGatewayEvent_Payload {
+	if x != nil {
+		return x.Payload
 	}
 	return nil
 }
 
-func (x *RestApiResponse) GetBody() string {
+func (x *GatewayEvent) GetCachePurge() *CachePurge {
 	if x != nil {
-		return x.Body
+		if x, ok := x.Payload.(*GatewayEvent_CachePurge); ok {
+			return x.CachePurge
+		}
 	}
-	return ""
+	return nil
 }
 
-type RestHeader struct {
+func (x *GatewayEvent) GetTokenRevocation() *TokenRevocation {
+	if x != nil {
+		if x, ok := x.Payload.(*GatewayEvent_TokenRevocation); ok {
+			return x.TokenRevocation
+		}
+	}
+	return nil
+}
+
+type isGatewayEvent_Payload interface {
+	isGatewayEvent_Payload()
+}
+
+type GatewayEvent_CachePurge struct {
+	CachePurge *CachePurge `protobuf:"bytes,1,opt,name=CachePurge,proto3,oneof"`
+}
+
+type GatewayEvent_TokenRevocation struct {
+	TokenRevocation *TokenRevocation `protobuf:"bytes,2,opt,name=TokenRevocation,proto3,oneof"`
+}
+
+func (*GatewayEvent_CachePurge) isGatewayEvent_Payload() {}
+
+func (*GatewayEvent_TokenRevocation) isGatewayEvent_Payload() {}
+
+// CachePurge removes cached responses of the service. Paths are relative to the service root,
+// entries are removed regardless of query string and user
+type CachePurge struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Key           string                 `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
-	Value         string                 `protobuf:"bytes,2,opt,name=Value,proto3" json:"Value,omitempty"`
+	Paths         []string               `protobuf:"bytes,1,rep,name=Paths,proto3" json:"Paths,omitempty"`
+	Prefixes      []string               `protobuf:"bytes,2,rep,name=Prefixes,proto3" json:"Prefixes,omitempty"`
+	All           bool                   `protobuf:"varint,3,opt,name=All,proto3" json:"All,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *RestHeader) Reset() {
-	*x = RestHeader{}
-	mi := &file_rest_proto_msgTypes[8]
+func (x *CachePurge) Reset() {
+	*x = CachePurge{}
+	mi := &file_rest_proto_msgTypes[18]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *RestHeader) String() string {
+func (x *CachePurge) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*RestHeader) ProtoMessage() {}
+func (*CachePurge) ProtoMessage() {}
 
-func (x *RestHeader) ProtoReflect() protoreflect.Message {
-	mi := &file_rest_proto_msgTypes[8]
+func (x *CachePurge) ProtoReflect() protoreflect.Message {
+	mi := &file_rest_proto_msgTypes[18]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -559,48 +1324,56 @@ func (x *RestHeader) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use RestHeader.ProtoReflect.Descriptor instead.
-func (*RestHeader) Descriptor() ([]byte, []int) {
-	return file_rest_proto_rawDescGZIP(), []int{8}
+// Deprecated: Use CachePurge.ProtoReflect.Descriptor instead.
+func (*CachePurge) Descriptor() ([]byte, []int) {
+	return file_rest_proto_rawDescGZIP(), []int{18}
 }
 
-func (x *RestHeader) GetKey() string {
+func (x *CachePurge) GetPaths() []string {
 	if x != nil {
-		return x.Key
+		return x.Paths
 	}
-	return ""
+	return nil
 }
 
-func (x *RestHeader) GetValue() string {
+func (x *CachePurge) GetPrefixes() []string {
 	if x != nil {
-		return x.Value
+		return x.Prefixes
 	}
-	return ""
+	return nil
 }
 
-type PingMessage struct {
+func (x *CachePurge) GetAll() bool {
+	if x != nil {
+		return x.All
+	}
+	return false
+}
+
+// TokenRevocation removes cached results of token validation, e.g. when users log out or are banned
+type TokenRevocation struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	SentAt        *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=SentAt,proto3" json:"SentAt,omitempty"`
-	RepliedAt     *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=RepliedAt,proto3" json:"RepliedAt,omitempty"`
+	UserIds       []int32                `protobuf:"varint,1,rep,packed,name=UserIds,proto3" json:"UserIds,omitempty"`
+	Tokens        []string               `protobuf:"bytes,2,rep,name=Tokens,proto3" json:"Tokens,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *PingMessage) Reset() {
-	*x = PingMessage{}
-	mi := &file_rest_proto_msgTypes[9]
+func (x *TokenRevocation) Reset() {
+	*x = TokenRevocation{}
+	mi := &file_rest_proto_msgTypes[19]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *PingMessage) String() string {
+func (x *TokenRevocation) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PingMessage) ProtoMessage() {}
+func (*TokenRevocation) ProtoMessage() {}
 
-func (x *PingMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_rest_proto_msgTypes[9]
+func (x *TokenRevocation) ProtoReflect() protoreflect.Message {
+	mi := &file_rest_proto_msgTypes[19]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -611,21 +1384,21 @@ func (x *PingMessage) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PingMessage.ProtoReflect.Descriptor instead.
-func (*PingMessage) Descriptor() ([]byte, []int) {
-	return file_rest_proto_rawDescGZIP(), []int{9}
+// Deprecated: Use TokenRevocation.ProtoReflect.Descriptor instead.
+func (*TokenRevocation) Descriptor() ([]byte, []int) {
+	return file_rest_proto_rawDescGZIP(), []int{19}
 }
 
-func (x *PingMessage) GetSentAt() *timestamppb.Timestamp {
+func (x *TokenRevocation) GetUserIds() []int32 {
 	if x != nil {
-		return x.SentAt
+		return x.UserIds
 	}
 	return nil
 }
 
-func (x *PingMessage) GetRepliedAt() *timestamppb.Timestamp {
+func (x *TokenRevocation) GetTokens() []string {
 	if x != nil {
-		return x.RepliedAt
+		return x.Tokens
 	}
 	return nil
 }
@@ -661,71 +1434,170 @@ var file_rest_proto_rawDesc = string([]byte{
 	0x65, 0x73, 0x74, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x52, 0x09, 0x65, 0x6e, 0x64,
 	0x70, 0x6f, 0x69, 0x6e, 0x74, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f,
 	0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
-	0x22, 0x6a, 0x0a, 0x0c, 0x52, 0x65, 0x73, 0x74, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e, 0x74,
-	0x12, 0x12, 0x0a, 0x04, 0x50, 0x61, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
-	0x50, 0x61, 0x74, 0x68, 0x12, 0x16, 0x0a, 0x06, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x12, 0x2e, 0x0a, 0x12,
-	0x53, 0x6b, 0x69, 0x70, 0x41, 0x75, 0x74, 0x68, 0x4d, 0x69, 0x64, 0x64, 0x6c, 0x65, 0x77, 0x61,
-	0x72, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x12, 0x53, 0x6b, 0x69, 0x70, 0x41, 0x75,
-	0x74, 0x68, 0x4d, 0x69, 0x64, 0x64, 0x6c, 0x65, 0x77, 0x61, 0x72, 0x65, 0x22, 0xbd, 0x01, 0x0a,
-	0x0e, 0x52, 0x65, 0x73, 0x74, 0x41, 0x70, 0x69, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
-	0x10, 0x0a, 0x03, 0x55, 0x72, 0x69, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x55, 0x72,
-	0x69, 0x12, 0x16, 0x0a, 0x06, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x06, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x12, 0x2a, 0x0a, 0x07, 0x48, 0x65, 0x61,
-	0x64, 0x65, 0x72, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x72, 0x65, 0x73,
-	0x74, 0x2e, 0x52, 0x65, 0x73, 0x74, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x52, 0x07, 0x48, 0x65,
-	0x61, 0x64, 0x65, 0x72, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x42, 0x6f, 0x64, 0x79, 0x18, 0x04, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x04, 0x42, 0x6f, 0x64, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x53, 0x6f, 0x75,
-	0x72, 0x63, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x53, 0x6f, 0x75, 0x72, 0x63,
-	0x65, 0x12, 0x29, 0x0a, 0x04, 0x46, 0x6f, 0x72, 0x6d, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32,
-	0x15, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e, 0x52, 0x65, 0x73, 0x74, 0x41, 0x70, 0x69, 0x46, 0x6f,
-	0x72, 0x6d, 0x44, 0x61, 0x74, 0x61, 0x52, 0x04, 0x46, 0x6f, 0x72, 0x6d, 0x22, 0x39, 0x0a, 0x0f,
-	0x52, 0x65, 0x73, 0x74, 0x41, 0x70, 0x69, 0x46, 0x6f, 0x72, 0x6d, 0x44, 0x61, 0x74, 0x61, 0x12,
-	0x10, 0x0a, 0x03, 0x4b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4b, 0x65,
-	0x79, 0x12, 0x14, 0x0a, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09,
-	0x52, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x97, 0x01, 0x0a, 0x0f, 0x52, 0x65, 0x73, 0x74,
-	0x41, 0x70, 0x69, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x43,
-	0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x43, 0x6f, 0x64, 0x65, 0x12,
-	0x14, 0x0a, 0x05, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
-	0x45, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x1a, 0x0a, 0x08, 0x48, 0x74, 0x74, 0x70, 0x43, 0x6f, 0x64,
-	0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x48, 0x74, 0x74, 0x70, 0x43, 0x6f, 0x64,
-	0x65, 0x12, 0x2a, 0x0a, 0x07, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x18, 0x04, 0x20, 0x03,
-	0x28, 0x0b, 0x32, 0x10, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e, 0x52, 0x65, 0x73, 0x74, 0x48, 0x65,
-	0x61, 0x64, 0x65, 0x72, 0x52, 0x07, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x12, 0x12, 0x0a,
-	0x04, 0x42, 0x6f, 0x64, 0x79, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x42, 0x6f, 0x64,
-	0x79, 0x22, 0x34, 0x0a, 0x0a, 0x52, 0x65, 0x73, 0x74, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12,
-	0x10, 0x0a, 0x03, 0x4b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4b, 0x65,
-	0x79, 0x12, 0x14, 0x0a, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x7b, 0x0a, 0x0b, 0x50, 0x69, 0x6e, 0x67, 0x4d,
-	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x32, 0x0a, 0x06, 0x53, 0x65, 0x6e, 0x74, 0x41, 0x74,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e,
-	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61,
-	0x6d, 0x70, 0x52, 0x06, 0x53, 0x65, 0x6e, 0x74, 0x41, 0x74, 0x12, 0x38, 0x0a, 0x09, 0x52, 0x65,
-	0x70, 0x6c, 0x69, 0x65, 0x64, 0x41, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e,
-	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
-	0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x52, 0x65, 0x70, 0x6c, 0x69,
-	0x65, 0x64, 0x41, 0x74, 0x32, 0x9c, 0x02, 0x0a, 0x10, 0x52, 0x65, 0x73, 0x74, 0x49, 0x6e, 0x74,
-	0x65, 0x72, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x57, 0x0a, 0x10, 0x41, 0x75, 0x74,
-	0x68, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x20, 0x2e,
-	0x72, 0x65, 0x73, 0x74, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x65, 0x6e, 0x74, 0x69, 0x63, 0x61, 0x74,
-	0x65, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
-	0x21, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x65, 0x6e, 0x74, 0x69, 0x63,
-	0x61, 0x74, 0x65, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
-	0x73, 0x65, 0x12, 0x42, 0x0a, 0x0f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x52, 0x65, 0x73,
-	0x74, 0x44, 0x61, 0x74, 0x61, 0x12, 0x15, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e, 0x52, 0x65, 0x73,
-	0x74, 0x44, 0x61, 0x74, 0x61, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x18, 0x2e, 0x72,
-	0x65, 0x73, 0x74, 0x2e, 0x52, 0x65, 0x73, 0x74, 0x44, 0x61, 0x74, 0x61, 0x44, 0x65, 0x66, 0x69,
-	0x6e, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x3d, 0x0a, 0x0e, 0x4e, 0x65, 0x77, 0x52, 0x65, 0x73,
-	0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x14, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e,
-	0x52, 0x65, 0x73, 0x74, 0x41, 0x70, 0x69, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x15,
-	0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e, 0x52, 0x65, 0x73, 0x74, 0x41, 0x70, 0x69, 0x52, 0x65, 0x73,
-	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2c, 0x0a, 0x04, 0x50, 0x69, 0x6e, 0x67, 0x12, 0x11, 0x2e,
-	0x72, 0x65, 0x73, 0x74, 0x2e, 0x50, 0x69, 0x6e, 0x67, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
-	0x1a, 0x11, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e, 0x50, 0x69, 0x6e, 0x67, 0x4d, 0x65, 0x73, 0x73,
-	0x61, 0x67, 0x65, 0x42, 0x28, 0x5a, 0x26, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f,
-	0x6d, 0x2f, 0x73, 0x61, 0x76, 0x61, 0x67, 0x65, 0x6b, 0x69, 0x6e, 0x67, 0x2d, 0x69, 0x6f, 0x2f,
-	0x6f, 0x67, 0x62, 0x72, 0x65, 0x73, 0x74, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x06, 0x70,
-	0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x22, 0xc5, 0x02, 0x0a, 0x0c, 0x52, 0x65, 0x73, 0x74, 0x45, 0x6e, 0x64, 0x70, 0x6f, 0x69, 0x6e,
+	0x74, 0x12, 0x12, 0x0a, 0x04, 0x50, 0x61, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x04, 0x50, 0x61, 0x74, 0x68, 0x12, 0x16, 0x0a, 0x06, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x12, 0x2e, 0x0a,
+	0x12, 0x53, 0x6b, 0x69, 0x70, 0x41, 0x75, 0x74, 0x68, 0x4d, 0x69, 0x64, 0x64, 0x6c, 0x65, 0x77,
+	0x61, 0x72, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x12, 0x53, 0x6b, 0x69, 0x70, 0x41,
+	0x75, 0x74, 0x68, 0x4d, 0x69, 0x64, 0x64, 0x6c, 0x65, 0x77, 0x61, 0x72, 0x65, 0x12, 0x16, 0x0a,
+	0x06, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x53,
+	0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x1c, 0x0a, 0x09, 0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74,
+	0x4d, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x09, 0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75,
+	0x74, 0x4d, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x49, 0x64, 0x65, 0x6d, 0x70, 0x6f, 0x74, 0x65, 0x6e,
+	0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0a, 0x49, 0x64, 0x65, 0x6d, 0x70, 0x6f, 0x74,
+	0x65, 0x6e, 0x74, 0x12, 0x31, 0x0a, 0x09, 0x52, 0x61, 0x74, 0x65, 0x4c, 0x69, 0x6d, 0x69, 0x74,
+	0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e, 0x52, 0x65,
+	0x73, 0x74, 0x52, 0x61, 0x74, 0x65, 0x4c, 0x69, 0x6d, 0x69, 0x74, 0x52, 0x09, 0x52, 0x61, 0x74,
+	0x65, 0x4c, 0x69, 0x6d, 0x69, 0x74, 0x12, 0x22, 0x0a, 0x0c, 0x4d, 0x61, 0x78, 0x42, 0x6f, 0x64,
+	0x79, 0x42, 0x79, 0x74, 0x65, 0x73, 0x18, 0x08, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0c, 0x4d, 0x61,
+	0x78, 0x42, 0x6f, 0x64, 0x79, 0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x52, 0x6f,
+	0x6c, 0x65, 0x73, 0x18, 0x09, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x52, 0x6f, 0x6c, 0x65, 0x73,
+	0x12, 0x16, 0x0a, 0x06, 0x53, 0x63, 0x6f, 0x70, 0x65, 0x73, 0x18, 0x0a, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x06, 0x53, 0x63, 0x6f, 0x70, 0x65, 0x73, 0x22, 0x6f, 0x0a, 0x0d, 0x52, 0x65, 0x73, 0x74,
+	0x52, 0x61, 0x74, 0x65, 0x4c, 0x69, 0x6d, 0x69, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x50, 0x65, 0x72, 0x69, 0x6f, 0x64, 0x4d,
+	0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x50, 0x65, 0x72, 0x69, 0x6f, 0x64, 0x4d,
+	0x73, 0x12, 0x14, 0x0a, 0x05, 0x42, 0x75, 0x72, 0x73, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x05, 0x42, 0x75, 0x72, 0x73, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x4b, 0x65, 0x79, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4b, 0x65, 0x79, 0x22, 0xbb, 0x03, 0x0a, 0x0e, 0x52, 0x65,
+	0x73, 0x74, 0x41, 0x70, 0x69, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x10, 0x0a, 0x03,
+	0x55, 0x72, 0x69, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x55, 0x72, 0x69, 0x12, 0x16,
+	0x0a, 0x06, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
+	0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x12, 0x2a, 0x0a, 0x07, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72,
+	0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e, 0x52,
+	0x65, 0x73, 0x74, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x52, 0x07, 0x48, 0x65, 0x61, 0x64, 0x65,
+	0x72, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x42, 0x6f, 0x64, 0x79, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x42, 0x6f, 0x64, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x53, 0x6f, 0x75, 0x72, 0x63, 0x65,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x53, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x12, 0x29,
+	0x0a, 0x04, 0x46, 0x6f, 0x72, 0x6d, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x72,
+	0x65, 0x73, 0x74, 0x2e, 0x52, 0x65, 0x73, 0x74, 0x41, 0x70, 0x69, 0x46, 0x6f, 0x72, 0x6d, 0x44,
+	0x61, 0x74, 0x61, 0x52, 0x04, 0x46, 0x6f, 0x72, 0x6d, 0x12, 0x18, 0x0a, 0x07, 0x52, 0x61, 0x77,
+	0x42, 0x6f, 0x64, 0x79, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x52, 0x61, 0x77, 0x42,
+	0x6f, 0x64, 0x79, 0x12, 0x20, 0x0a, 0x0b, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x54, 0x79,
+	0x70, 0x65, 0x18, 0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e,
+	0x74, 0x54, 0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x50, 0x61, 0x74, 0x68, 0x18, 0x09, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x50, 0x61, 0x74, 0x68, 0x12, 0x1a, 0x0a, 0x08, 0x52, 0x61, 0x77,
+	0x51, 0x75, 0x65, 0x72, 0x79, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x52, 0x61, 0x77,
+	0x51, 0x75, 0x65, 0x72, 0x79, 0x12, 0x2b, 0x0a, 0x05, 0x51, 0x75, 0x65, 0x72, 0x79, 0x18, 0x0b,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e, 0x52, 0x65, 0x73, 0x74,
+	0x41, 0x70, 0x69, 0x46, 0x6f, 0x72, 0x6d, 0x44, 0x61, 0x74, 0x61, 0x52, 0x05, 0x51, 0x75, 0x65,
+	0x72, 0x79, 0x12, 0x33, 0x0a, 0x0a, 0x50, 0x61, 0x74, 0x68, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73,
+	0x18, 0x0c, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e, 0x52, 0x65,
+	0x73, 0x74, 0x50, 0x61, 0x74, 0x68, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x52, 0x0a, 0x50, 0x61, 0x74,
+	0x68, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x12, 0x2e, 0x0a, 0x08, 0x49, 0x64, 0x65, 0x6e, 0x74,
+	0x69, 0x74, 0x79, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x72, 0x65, 0x73, 0x74,
+	0x2e, 0x52, 0x65, 0x73, 0x74, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x52, 0x08, 0x49,
+	0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x22, 0x87, 0x01, 0x0a, 0x0c, 0x52, 0x65, 0x73, 0x74,
+	0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x55, 0x73, 0x65, 0x72,
+	0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x55, 0x73, 0x65, 0x72, 0x49, 0x64,
+	0x12, 0x27, 0x0a, 0x06, 0x43, 0x6c, 0x61, 0x69, 0x6d, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x0f, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e, 0x52, 0x65, 0x73, 0x74, 0x43, 0x6c, 0x61, 0x69,
+	0x6d, 0x52, 0x06, 0x43, 0x6c, 0x61, 0x69, 0x6d, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x41, 0x75, 0x74,
+	0x68, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x41,
+	0x75, 0x74, 0x68, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x41, 0x70, 0x69,
+	0x4b, 0x65, 0x79, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x41, 0x70, 0x69, 0x4b, 0x65,
+	0x79, 0x22, 0x35, 0x0a, 0x09, 0x52, 0x65, 0x73, 0x74, 0x43, 0x6c, 0x61, 0x69, 0x6d, 0x12, 0x10,
+	0x0a, 0x03, 0x4b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4b, 0x65, 0x79,
+	0x12, 0x16, 0x0a, 0x06, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x06, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x22, 0x39, 0x0a, 0x0f, 0x52, 0x65, 0x73, 0x74,
+	0x41, 0x70, 0x69, 0x46, 0x6f, 0x72, 0x6d, 0x44, 0x61, 0x74, 0x61, 0x12, 0x10, 0x0a, 0x03, 0x4b,
+	0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4b, 0x65, 0x79, 0x12, 0x14, 0x0a,
+	0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x56, 0x61,
+	0x6c, 0x75, 0x65, 0x22, 0x37, 0x0a, 0x0d, 0x52, 0x65, 0x73, 0x74, 0x50, 0x61, 0x74, 0x68, 0x50,
+	0x61, 0x72, 0x61, 0x6d, 0x12, 0x10, 0x0a, 0x03, 0x4b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x03, 0x4b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x22, 0xd3, 0x01, 0x0a,
+	0x0f, 0x52, 0x65, 0x73, 0x74, 0x41, 0x70, 0x69, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x12, 0x0a, 0x04, 0x43, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04,
+	0x43, 0x6f, 0x64, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x1a, 0x0a, 0x08, 0x48, 0x74,
+	0x74, 0x70, 0x43, 0x6f, 0x64, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x48, 0x74,
+	0x74, 0x70, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x2a, 0x0a, 0x07, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72,
+	0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e, 0x52,
+	0x65, 0x73, 0x74, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x52, 0x07, 0x48, 0x65, 0x61, 0x64, 0x65,
+	0x72, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x42, 0x6f, 0x64, 0x79, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x42, 0x6f, 0x64, 0x79, 0x12, 0x18, 0x0a, 0x07, 0x52, 0x61, 0x77, 0x42, 0x6f, 0x64,
+	0x79, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x52, 0x61, 0x77, 0x42, 0x6f, 0x64, 0x79,
+	0x12, 0x20, 0x0a, 0x0b, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x18,
+	0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x54, 0x79,
+	0x70, 0x65, 0x22, 0x62, 0x0a, 0x11, 0x52, 0x65, 0x73, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x2a, 0x0a, 0x04, 0x48, 0x65, 0x61, 0x64, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e, 0x52, 0x65, 0x73,
+	0x74, 0x41, 0x70, 0x69, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52, 0x04, 0x48,
+	0x65, 0x61, 0x64, 0x12, 0x16, 0x0a, 0x05, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0c, 0x48, 0x00, 0x52, 0x05, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x42, 0x09, 0x0a, 0x07, 0x50,
+	0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x22, 0x64, 0x0a, 0x12, 0x52, 0x65, 0x73, 0x74, 0x53, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2b, 0x0a, 0x04,
+	0x48, 0x65, 0x61, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x72, 0x65, 0x73,
+	0x74, 0x2e, 0x52, 0x65, 0x73, 0x74, 0x41, 0x70, 0x69, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x48, 0x00, 0x52, 0x04, 0x48, 0x65, 0x61, 0x64, 0x12, 0x16, 0x0a, 0x05, 0x43, 0x68, 0x75,
+	0x6e, 0x6b, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x48, 0x00, 0x52, 0x05, 0x43, 0x68, 0x75, 0x6e,
+	0x6b, 0x42, 0x09, 0x0a, 0x07, 0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x22, 0x4c, 0x0a, 0x0a,
+	0x52, 0x65, 0x73, 0x74, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x10, 0x0a, 0x03, 0x4b, 0x65,
+	0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x4b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05,
+	0x56, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x56, 0x61, 0x6c,
+	0x75, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x18, 0x03, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x06, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x73, 0x22, 0x7b, 0x0a, 0x0b, 0x50, 0x69,
+	0x6e, 0x67, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x32, 0x0a, 0x06, 0x53, 0x65, 0x6e,
+	0x74, 0x41, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
+	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65,
+	0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x06, 0x53, 0x65, 0x6e, 0x74, 0x41, 0x74, 0x12, 0x38, 0x0a,
+	0x09, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x65, 0x64, 0x41, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62,
+	0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x52, 0x65,
+	0x70, 0x6c, 0x69, 0x65, 0x64, 0x41, 0x74, 0x22, 0x16, 0x0a, 0x14, 0x47, 0x61, 0x74, 0x65, 0x77,
+	0x61, 0x79, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22,
+	0x90, 0x01, 0x0a, 0x0c, 0x47, 0x61, 0x74, 0x65, 0x77, 0x61, 0x79, 0x45, 0x76, 0x65, 0x6e, 0x74,
+	0x12, 0x32, 0x0a, 0x0a, 0x43, 0x61, 0x63, 0x68, 0x65, 0x50, 0x75, 0x72, 0x67, 0x65, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e, 0x43, 0x61, 0x63, 0x68,
+	0x65, 0x50, 0x75, 0x72, 0x67, 0x65, 0x48, 0x00, 0x52, 0x0a, 0x43, 0x61, 0x63, 0x68, 0x65, 0x50,
+	0x75, 0x72, 0x67, 0x65, 0x12, 0x41, 0x0a, 0x0f, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x52, 0x65, 0x76,
+	0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e,
+	0x72, 0x65, 0x73, 0x74, 0x2e, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x52, 0x65, 0x76, 0x6f, 0x63, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52, 0x0f, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x52, 0x65, 0x76,
+	0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x42, 0x09, 0x0a, 0x07, 0x50, 0x61, 0x79, 0x6c, 0x6f,
+	0x61, 0x64, 0x22, 0x50, 0x0a, 0x0a, 0x43, 0x61, 0x63, 0x68, 0x65, 0x50, 0x75, 0x72, 0x67, 0x65,
+	0x12, 0x14, 0x0a, 0x05, 0x50, 0x61, 0x74, 0x68, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52,
+	0x05, 0x50, 0x61, 0x74, 0x68, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x50, 0x72, 0x65, 0x66, 0x69, 0x78,
+	0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x08, 0x50, 0x72, 0x65, 0x66, 0x69, 0x78,
+	0x65, 0x73, 0x12, 0x10, 0x0a, 0x03, 0x41, 0x6c, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x03, 0x41, 0x6c, 0x6c, 0x22, 0x43, 0x0a, 0x0f, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x52, 0x65, 0x76,
+	0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x55, 0x73, 0x65, 0x72, 0x49,
+	0x64, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x05, 0x52, 0x07, 0x55, 0x73, 0x65, 0x72, 0x49, 0x64,
+	0x73, 0x12, 0x16, 0x0a, 0x06, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x06, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x73, 0x32, 0xae, 0x03, 0x0a, 0x10, 0x52, 0x65,
+	0x73, 0x74, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x57,
+	0x0a, 0x10, 0x41, 0x75, 0x74, 0x68, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x53, 0x65, 0x72, 0x76, 0x69,
+	0x63, 0x65, 0x12, 0x20, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x65, 0x6e,
+	0x74, 0x69, 0x63, 0x61, 0x74, 0x65, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x21, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e, 0x41, 0x75, 0x74, 0x68,
+	0x65, 0x6e, 0x74, 0x69, 0x63, 0x61, 0x74, 0x65, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x42, 0x0a, 0x0f, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x52, 0x65, 0x73, 0x74, 0x44, 0x61, 0x74, 0x61, 0x12, 0x15, 0x2e, 0x72, 0x65, 0x73,
+	0x74, 0x2e, 0x52, 0x65, 0x73, 0x74, 0x44, 0x61, 0x74, 0x61, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x18, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e, 0x52, 0x65, 0x73, 0x74, 0x44, 0x61, 0x74,
+	0x61, 0x44, 0x65, 0x66, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x3d, 0x0a, 0x0e, 0x4e,
+	0x65, 0x77, 0x52, 0x65, 0x73, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x14, 0x2e,
+	0x72, 0x65, 0x73, 0x74, 0x2e, 0x52, 0x65, 0x73, 0x74, 0x41, 0x70, 0x69, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x15, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e, 0x52, 0x65, 0x73, 0x74, 0x41,
+	0x70, 0x69, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x4d, 0x0a, 0x14, 0x4e, 0x65,
+	0x77, 0x52, 0x65, 0x73, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x17, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e, 0x52, 0x65, 0x73, 0x74, 0x53, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x18, 0x2e, 0x72, 0x65,
+	0x73, 0x74, 0x2e, 0x52, 0x65, 0x73, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x28, 0x01, 0x30, 0x01, 0x12, 0x2c, 0x0a, 0x04, 0x50, 0x69, 0x6e,
+	0x67, 0x12, 0x11, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e, 0x50, 0x69, 0x6e, 0x67, 0x4d, 0x65, 0x73,
+	0x73, 0x61, 0x67, 0x65, 0x1a, 0x11, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e, 0x50, 0x69, 0x6e, 0x67,
+	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x41, 0x0a, 0x0d, 0x47, 0x61, 0x74, 0x65, 0x77,
+	0x61, 0x79, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x1a, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e,
+	0x47, 0x61, 0x74, 0x65, 0x77, 0x61, 0x79, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x73, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x12, 0x2e, 0x72, 0x65, 0x73, 0x74, 0x2e, 0x47, 0x61, 0x74, 0x65,
+	0x77, 0x61, 0x79, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x30, 0x01, 0x42, 0x28, 0x5a, 0x26, 0x67, 0x69,
+	0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x73, 0x61, 0x76, 0x61, 0x67, 0x65, 0x6b,
+	0x69, 0x6e, 0x67, 0x2d, 0x69, 0x6f, 0x2f, 0x6f, 0x67, 0x62, 0x72, 0x65, 0x73, 0x74, 0x2f, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 })
 
 var (
@@ -740,40 +1612,63 @@ func file_rest_proto_rawDescGZIP() []byte {
 	return file_rest_proto_rawDescData
 }
 
-var file_rest_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_rest_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
 var file_rest_proto_goTypes = []any{
 	(*AuthenticateServiceRequest)(nil),  // 0: rest.AuthenticateServiceRequest
 	(*AuthenticateServiceResponse)(nil), // 1: rest.AuthenticateServiceResponse
 	(*RestDataRequest)(nil),             // 2: rest.RestDataRequest
 	(*RestDataDefinition)(nil),          // 3: rest.RestDataDefinition
 	(*RestEndpoint)(nil),                // 4: rest.RestEndpoint
-	(*RestApiRequest)(nil),              // 5: rest.RestApiRequest
-	(*RestApiFormData)(nil),             // 6: rest.RestApiFormData
-	(*RestApiResponse)(nil),             // 7: rest.RestApiResponse
-	(*RestHeader)(nil),                  // 8: rest.RestHeader
-	(*PingMessage)(nil),                 // 9: rest.PingMessage
-	(*timestamppb.Timestamp)(nil),       // 10: google.protobuf.Timestamp
+	(*RestRateLimit)(nil),               // 5: rest.RestRateLimit
+	(*RestApiRequest)(nil),              // 6: rest.RestApiRequest
+	(*RestIdentity)(nil),                // 7: rest.RestIdentity
+	(*RestClaim)(nil),                   // 8: rest.RestClaim
+	(*RestApiFormData)(nil),             // 9: rest.RestApiFormData
+	(*RestPathParam)(nil),               // 10: rest.RestPathParam
+	(*RestApiResponse)(nil),             // 11: rest.RestApiResponse
+	(*RestStreamRequest)(nil),           // 12: rest.RestStreamRequest
+	(*RestStreamResponse)(nil),          // 13: rest.RestStreamResponse
+	(*RestHeader)(nil),                  // 14: rest.RestHeader
+	(*PingMessage)(nil),                 // 15: rest.PingMessage
+	(*GatewayEventsRequest)(nil),        // 16: rest.GatewayEventsRequest
+	(*GatewayEvent)(nil),                // 17: rest.GatewayEvent
+	(*CachePurge)(nil),                  // 18: rest.CachePurge
+	(*TokenRevocation)(nil),             // 19: rest.TokenRevocation
+	(*timestamppb.Timestamp)(nil),       // 20: google.protobuf.Timestamp
 }
 var file_rest_proto_depIdxs = []int32{
 	4,  // 0: rest.RestDataDefinition.endpoints:type_name -> rest.RestEndpoint
-	8,  // 1: rest.RestApiRequest.Headers:type_name -> rest.RestHeader
-	6,  // 2: rest.RestApiRequest.Form:type_name -> rest.RestApiFormData
-	8,  // 3: rest.RestApiResponse.Headers:type_name -> rest.RestHeader
-	10, // 4: rest.PingMessage.SentAt:type_name -> google.protobuf.Timestamp
-	10, // 5: rest.PingMessage.RepliedAt:type_name -> google.protobuf.Timestamp
-	0,  // 6: rest.RestInterService.AuthInterService:input_type -> rest.AuthenticateServiceRequest
-	2,  // 7: rest.RestInterService.RequestRestData:input_type -> rest.RestDataRequest
-	5,  // 8: rest.RestInterService.NewRestRequest:input_type -> rest.RestApiRequest
-	9,  // 9: rest.RestInterService.Ping:input_type -> rest.PingMessage
-	1,  // 10: rest.RestInterService.AuthInterService:output_type -> rest.AuthenticateServiceResponse
-	3,  // 11: rest.RestInterService.RequestRestData:output_type -> rest.RestDataDefinition
-	7,  // 12: rest.RestInterService.NewRestRequest:output_type -> rest.RestApiResponse
-	9,  // 13: rest.RestInterService.Ping:output_type -> rest.PingMessage
-	10, // [10:14] is the sub-list for method output_type
-	6,  // [6:10] is the sub-list for method input_type
-	6,  // [6:6] is the sub-list for extension type_name
-	6,  // [6:6] is the sub-list for extension extendee
-	0,  // [0:6] is the sub-list for field type_name
+	5,  // 1: rest.RestEndpoint.RateLimit:type_name -> rest.RestRateLimit
+	14, // 2: rest.RestApiRequest.Headers:type_name -> rest.RestHeader
+	9,  // 3: rest.RestApiRequest.Form:type_name -> rest.RestApiFormData
+	9,  // 4: rest.RestApiRequest.Query:type_name -> rest.RestApiFormData
+	10, // 5: rest.RestApiRequest.PathParams:type_name -> rest.RestPathParam
+	7,  // 6: rest.RestApiRequest.Identity:type_name -> rest.RestIdentity
+	8,  // 7: rest.RestIdentity.Claims:type_name -> rest.RestClaim
+	14, // 8: rest.RestApiResponse.Headers:type_name -> rest.RestHeader
+	6,  // 9: rest.RestStreamRequest.Head:type_name -> rest.RestApiRequest
+	11, // 10: rest.RestStreamResponse.Head:type_name -> rest.RestApiResponse
+	20, // 11: rest.PingMessage.SentAt:type_name -> google.protobuf.Timestamp
+	20, // 12: rest.PingMessage.RepliedAt:type_name -> google.protobuf.Timestamp
+	18, // 13: rest.GatewayEvent.CachePurge:type_name -> rest.CachePurge
+	19, // 14: rest.GatewayEvent.TokenRevocation:type_name -> rest.TokenRevocation
+	0,  // 15: rest.RestInterService.AuthInterService:input_type -> rest.AuthenticateServiceRequest
+	2,  // 16: rest.RestInterService.RequestRestData:input_type -> rest.RestDataRequest
+	6,  // 17: rest.RestInterService.NewRestRequest:input_type -> rest.RestApiRequest
+	12, // 18: rest.RestInterService.NewRestStreamRequest:input_type -> rest.RestStreamRequest
+	15, // 19: rest.RestInterService.Ping:input_type -> rest.PingMessage
+	16, // 20: rest.RestInterService.GatewayEvents:input_type -> rest.GatewayEventsRequest
+	1,  // 21: rest.RestInterService.AuthInterService:output_type -> rest.AuthenticateServiceResponse
+	3,  // 22: rest.RestInterService.RequestRestData:output_type -> rest.RestDataDefinition
+	11, // 23: rest.RestInterService.NewRestRequest:output_type -> rest.RestApiResponse
+	13, // 24: rest.RestInterService.NewRestStreamRequest:output_type -> rest.RestStreamResponse
+	15, // 25: rest.RestInterService.Ping:output_type -> rest.PingMessage
+	17, // 26: rest.RestInterService.GatewayEvents:output_type -> rest.GatewayEvent
+	21, // [21:27] is the sub-list for method output_type
+	15, // [15:21] is the sub-list for method input_type
+	15, // [15:15] is the sub-list for extension type_name
+	15, // [15:15] is the sub-list for extension extendee
+	0,  // [0:15] is the sub-list for field type_name
 }
 
 func init() { file_rest_proto_init() }
@@ -781,13 +1676,25 @@ func file_rest_proto_init() {
 	if File_rest_proto != nil {
 		return
 	}
+	file_rest_proto_msgTypes[12].OneofWrappers = []any{
+		(*RestStreamRequest_Head)(nil),
+		(*RestStreamRequest_Chunk)(nil),
+	}
+	file_rest_proto_msgTypes[13].OneofWrappers = []any{
+		(*RestStreamResponse_Head)(nil),
+		(*RestStreamResponse_Chunk)(nil),
+	}
+	file_rest_proto_msgTypes[17].OneofWrappers = []any{
+		(*GatewayEvent_CachePurge)(nil),
+		(*GatewayEvent_TokenRevocation)(nil),
+	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_rest_proto_rawDesc), len(file_rest_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   10,
+			NumMessages:   20,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
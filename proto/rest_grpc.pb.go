@@ -19,10 +19,12 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	RestInterService_AuthInterService_FullMethodName = "/rest.RestInterService/AuthInterService"
-	RestInterService_RequestRestData_FullMethodName  = "/rest.RestInterService/RequestRestData"
-	RestInterService_NewRestRequest_FullMethodName   = "/rest.RestInterService/NewRestRequest"
-	RestInterService_Ping_FullMethodName             = "/rest.RestInterService/Ping"
+	RestInterService_AuthInterService_FullMethodName     = "/rest.RestInterService/AuthInterService"
+	RestInterService_RequestRestData_FullMethodName      = "/rest.RestInterService/RequestRestData"
+	RestInterService_NewRestRequest_FullMethodName       = "/rest.RestInterService/NewRestRequest"
+	RestInterService_NewRestStreamRequest_FullMethodName = "/rest.RestInterService/NewRestStreamRequest"
+	RestInterService_Ping_FullMethodName                 = "/rest.RestInterService/Ping"
+	RestInterService_GatewayEvents_FullMethodName        = "/rest.RestInterService/GatewayEvents"
 )
 
 // RestInterServiceClient is the client API for RestInterService service.
@@ -32,7 +34,13 @@ type RestInterServiceClient interface {
 	AuthInterService(ctx context.Context, in *AuthenticateServiceRequest, opts ...grpc.CallOption) (*AuthenticateServiceResponse, error)
 	RequestRestData(ctx context.Context, in *RestDataRequest, opts ...grpc.CallOption) (*RestDataDefinition, error)
 	NewRestRequest(ctx context.Context, in *RestApiRequest, opts ...grpc.CallOption) (*RestApiResponse, error)
+	// NewRestStreamRequest proxies a request whose body is transferred in chunks.
+	// The first message in each direction carries the request/response head, the rest carry body chunks
+	NewRestStreamRequest(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[RestStreamRequest, RestStreamResponse], error)
 	Ping(ctx context.Context, in *PingMessage, opts ...grpc.CallOption) (*PingMessage, error)
+	// GatewayEvents is opened by the gateway once the service is ready. Service sends events that change
+	// gateway state, e.g. purges cached responses
+	GatewayEvents(ctx context.Context, in *GatewayEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GatewayEvent], error)
 }
 
 type restInterServiceClient struct {
@@ -73,6 +81,19 @@ func (c *restInterServiceClient) NewRestRequest(ctx context.Context, in *RestApi
 	return out, nil
 }
 
+func (c *restInterServiceClient) NewRestStreamRequest(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[RestStreamRequest, RestStreamResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &RestInterService_ServiceDesc.Streams[0], RestInterService_NewRestStreamRequest_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RestStreamRequest, RestStreamResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RestInterService_NewRestStreamRequestClient = grpc.BidiStreamingClient[RestStreamRequest, RestStreamResponse]
+
 func (c *restInterServiceClient) Ping(ctx context.Context, in *PingMessage, opts ...grpc.CallOption) (*PingMessage, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(PingMessage)
@@ -83,6 +104,25 @@ func (c *restInterServiceClient) Ping(ctx context.Context, in *PingMessage, opts
 	return out, nil
 }
 
+func (c *restInterServiceClient) GatewayEvents(ctx context.Context, in *GatewayEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GatewayEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &RestInterService_ServiceDesc.Streams[1], RestInterService_GatewayEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GatewayEventsRequest, GatewayEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RestInterService_GatewayEventsClient = grpc.ServerStreamingClient[GatewayEvent]
+
 // RestInterServiceServer is the server API for RestInterService service.
 // All implementations must embed UnimplementedRestInterServiceServer
 // for forward compatibility.
@@ -90,7 +130,13 @@ type RestInterServiceServer interface {
 	AuthInterService(context.Context, *AuthenticateServiceRequest) (*AuthenticateServiceResponse, error)
 	RequestRestData(context.Context, *RestDataRequest) (*RestDataDefinition, error)
 	NewRestRequest(context.Context, *RestApiRequest) (*RestApiResponse, error)
+	// NewRestStreamRequest proxies a request whose body is transferred in chunks.
+	// The first message in each direction carries the request/response head, the rest carry body chunks
+	NewRestStreamRequest(grpc.BidiStreamingServer[RestStreamRequest, RestStreamResponse]) error
 	Ping(context.Context, *PingMessage) (*PingMessage, error)
+	// GatewayEvents is opened by the gateway once the service is ready. Service sends events that change
+	// gateway state, e.g. purges cached responses
+	GatewayEvents(*GatewayEventsRequest, grpc.ServerStreamingServer[GatewayEvent]) error
 	mustEmbedUnimplementedRestInterServiceServer()
 }
 
@@ -110,9 +156,15 @@ func (UnimplementedRestInterServiceServer) RequestRestData(context.Context, *Res
 func (UnimplementedRestInterServiceServer) NewRestRequest(context.Context, *RestApiRequest) (*RestApiResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method NewRestRequest not implemented")
 }
+func (UnimplementedRestInterServiceServer) NewRestStreamRequest(grpc.BidiStreamingServer[RestStreamRequest, RestStreamResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method NewRestStreamRequest not implemented")
+}
 func (UnimplementedRestInterServiceServer) Ping(context.Context, *PingMessage) (*PingMessage, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Ping not implemented")
 }
+func (UnimplementedRestInterServiceServer) GatewayEvents(*GatewayEventsRequest, grpc.ServerStreamingServer[GatewayEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method GatewayEvents not implemented")
+}
 func (UnimplementedRestInterServiceServer) mustEmbedUnimplementedRestInterServiceServer() {}
 func (UnimplementedRestInterServiceServer) testEmbeddedByValue()                          {}
 
@@ -188,6 +240,13 @@ func _RestInterService_NewRestRequest_Handler(srv interface{}, ctx context.Conte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _RestInterService_NewRestStreamRequest_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RestInterServiceServer).NewRestStreamRequest(&grpc.GenericServerStream[RestStreamRequest, RestStreamResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RestInterService_NewRestStreamRequestServer = grpc.BidiStreamingServer[RestStreamRequest, RestStreamResponse]
+
 func _RestInterService_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(PingMessage)
 	if err := dec(in); err != nil {
@@ -206,6 +265,17 @@ func _RestInterService_Ping_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _RestInterService_GatewayEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GatewayEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RestInterServiceServer).GatewayEvents(m, &grpc.GenericServerStream[GatewayEventsRequest, GatewayEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RestInterService_GatewayEventsServer = grpc.ServerStreamingServer[GatewayEvent]
+
 // RestInterService_ServiceDesc is the grpc.ServiceDesc for RestInterService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -230,6 +300,18 @@ var RestInterService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _RestInterService_Ping_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "NewRestStreamRequest",
+			Handler:       _RestInterService_NewRestStreamRequest_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "GatewayEvents",
+			Handler:       _RestInterService_GatewayEvents_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "rest.proto",
 }
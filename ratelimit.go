@@ -0,0 +1,344 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"github.com/savageking-io/ogbrest/kafka"
+	log "github.com/sirupsen/logrus"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultRateLimitPeriod  = time.Second
+	DefaultRateLimitMaxKeys = 100000
+)
+
+const (
+	RateLimitKeyUser   = "user"    // Authenticated user, anonymous clients are told apart by IP
+	RateLimitKeyIP     = "ip"      // Client IP
+	RateLimitKeyUserIP = "user_ip" // Both, so every device of the user has its own limit
+)
+
+// RateLimit is a token bucket that holds Burst tokens at most and is refilled with Requests tokens per Period
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+	Key      string
+}
+
+// newRateLimit fills defaults of the limit. Returns nil when requests are not limited
+func newRateLimit(requests int, period time.Duration, burst int, key string, defaultKey string) (*RateLimit, error) {
+	if requests <= 0 {
+		return nil, nil
+	}
+	if period <= 0 {
+		period = DefaultRateLimitPeriod
+	}
+	if burst <= 0 {
+		burst = requests
+	}
+	if key == "" {
+		key = defaultKey
+	}
+	switch key {
+	case RateLimitKeyUser, RateLimitKeyIP, RateLimitKeyUserIP:
+	default:
+		return nil, fmt.Errorf("unknown rate limit key %s", key)
+	}
+	return &RateLimit{Requests: requests, Period: period, Burst: burst, Key: key}, nil
+}
+
+// rate returns tokens added per second
+func (l *RateLimit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// policy describes the limit for RateLimit-Policy header
+func (l *RateLimit) policy() string {
+	return fmt.Sprintf("%d;w=%d;burst=%d", l.Requests, int64(math.Ceil(l.Period.Seconds())), l.Burst)
+}
+
+type tokenBucket struct {
+	key       string
+	tokens    float64
+	updatedAt time.Time
+	limit     RateLimit // Bucket is reset when the limit changes
+}
+
+// refill adds tokens for the time passed since the last update
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updatedAt).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.rate())
+		b.updatedAt = now
+	}
+}
+
+// result describes the bucket after the request was decided
+func (b *tokenBucket) result(limit *RateLimit, allowed bool) RateLimitResult {
+	result := RateLimitResult{Limit: limit, Allowed: allowed, Remaining: int(b.tokens)}
+	if !allowed {
+		result.RetryAfter = time.Duration((1 - b.tokens) / limit.rate() * float64(time.Second))
+	}
+	result.Reset = time.Duration((float64(limit.Burst) - b.tokens) / limit.rate() * float64(time.Second))
+	return result
+}
+
+// bucketRef is a bucket the request takes a token from
+type bucketRef struct {
+	key   string
+	limit *RateLimit
+}
+
+// RateLimitResult is the decision about a single request
+type RateLimitResult struct {
+	Limit      *RateLimit // Limit that decided. nil when request is not limited
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration // Time until the bucket is full again
+	RetryAfter time.Duration // Time until the next request is allowed. Set for rejected requests
+}
+
+// RateLimiter limits requests of every client with token buckets. Gateway limit applies to all the
+// routes together, while limits of routes come from configuration or from services
+type RateLimiter struct {
+	mutex        sync.Mutex
+	global       *RateLimit
+	routes       map[string]*RateLimit // Limits from configuration by METHOD pattern
+	preAuth      *RateLimit            // Limit of every IP before authentication. nil when not set
+	defaultKey   string
+	maxKeys      int
+	forwardedFor bool
+	buckets      map[string]*list.Element
+	lru          *list.List // Front is the most recently used bucket
+}
+
+// NewRateLimiter creates limiter of the configuration. Client IP is taken from X-Forwarded-For when forwardedFor is set
+func NewRateLimiter(config RateLimitConfig, forwardedFor bool) (*RateLimiter, error) {
+	l := &RateLimiter{
+		routes:       make(map[string]*RateLimit),
+		defaultKey:   config.Key,
+		maxKeys:      config.MaxKeys,
+		forwardedFor: forwardedFor,
+		buckets:      make(map[string]*list.Element),
+		lru:          list.New(),
+	}
+	if l.defaultKey == "" {
+		l.defaultKey = RateLimitKeyUser
+	}
+	if l.maxKeys <= 0 {
+		l.maxKeys = DefaultRateLimitMaxKeys
+	}
+	global, err := newRateLimit(config.Requests, config.Period, config.Burst, config.Key, l.defaultKey)
+	if err != nil {
+		return nil, err
+	}
+	l.global = global
+	l.preAuth, err = newRateLimit(config.PreAuth.Requests, config.PreAuth.Period, config.PreAuth.Burst, RateLimitKeyIP, RateLimitKeyIP)
+	if err != nil {
+		return nil, err
+	}
+	for _, route := range config.Routes {
+		limit, err := newRateLimit(route.Requests, route.Period, route.Burst, route.Key, l.defaultKey)
+		if err != nil {
+			return nil, fmt.Errorf("route %s %s: %w", route.Method, route.Pattern, err)
+		}
+		if limit != nil {
+			l.routes[routeKey(strings.ToUpper(route.Method), route.Pattern)] = limit
+		}
+	}
+	return l, nil
+}
+
+// RouteLimit returns limit of the route. Configuration overrides limit provided by the service.
+// Called once when the route is registered, Allow uses the limit stored in the route
+func (l *RateLimiter) RouteLimit(route *RouteEntry) *RateLimit {
+	if l == nil || route == nil {
+		return nil
+	}
+	if limit, ok := l.routes[routeKey(route.Method, route.Pattern)]; ok {
+		return limit
+	}
+	endpointLimit := route.Endpoint.GetRateLimit()
+	if endpointLimit == nil {
+		return nil
+	}
+	limit, err := newRateLimit(int(endpointLimit.Requests), time.Duration(endpointLimit.PeriodMs)*time.Millisecond, int(endpointLimit.Burst), endpointLimit.Key, l.defaultKey)
+	if err != nil {
+		log.Errorf("Ignoring rate limit of %s %s: %s", route.Method, route.Pattern, err.Error())
+		return nil
+	}
+	return limit
+}
+
+// Allow takes a token for the request from the bucket of the route and from the gateway one. Tokens are
+// taken only when both buckets have one, so a request rejected by one limit doesn't use up the other.
+// Limit of the API key the request was authenticated with replaces the gateway limit.
+// Result describes the limit that rejected the request or the one closest to rejecting it
+func (l *RateLimiter) Allow(req *http.Request, route *RouteEntry) RateLimitResult {
+	var refs []bucketRef
+	if route != nil && route.RateLimit != nil {
+		limit := route.RateLimit
+		refs = append(refs, bucketRef{key: fmt.Sprintf("%s|%s", routeKey(route.Method, route.Pattern), l.clientKey(req, limit)), limit: limit})
+	}
+	gatewayLimit := l.global
+	if identity := identityFromContext(req.Context()); identity != nil && identity.apiKey != nil && identity.apiKey.rateLimit != nil {
+		gatewayLimit = identity.apiKey.rateLimit
+	}
+	if gatewayLimit != nil {
+		refs = append(refs, bucketRef{key: "*|" + l.clientKey(req, gatewayLimit), limit: gatewayLimit})
+	}
+	return l.take(time.Now(), refs...)
+}
+
+// AllowPreAuth takes a token for the request from the bucket of its IP before the client is authenticated.
+// Buckets are separate from the gateway ones, so the gateway limit keyed by ip isn't taken twice
+func (l *RateLimiter) AllowPreAuth(req *http.Request) RateLimitResult {
+	if l.preAuth == nil {
+		return RateLimitResult{Allowed: true}
+	}
+	return l.take(time.Now(), bucketRef{key: "auth|ip:" + clientIP(req, l.forwardedFor), limit: l.preAuth})
+}
+
+// take takes a token from every bucket, or from none of them when one is empty.
+// Result of the first bucket wins ties
+func (l *RateLimiter) take(now time.Time, refs ...bucketRef) RateLimitResult {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	buckets := make([]*tokenBucket, len(refs))
+	for i, ref := range refs {
+		buckets[i] = l.bucket(ref.key, ref.limit, now)
+		if buckets[i].tokens < 1 {
+			return buckets[i].result(ref.limit, false)
+		}
+	}
+	result := RateLimitResult{Allowed: true}
+	for i, bucket := range buckets {
+		bucket.tokens--
+		if taken := bucket.result(refs[i].limit, true); result.Limit == nil || taken.Remaining < result.Remaining {
+			result = taken
+		}
+	}
+	return result
+}
+
+// bucket returns the refilled bucket of the key. New buckets are full, and the least recently used one
+// is removed when maxKeys clients are tracked. Must be called with mutex locked
+func (l *RateLimiter) bucket(key string, limit *RateLimit, now time.Time) *tokenBucket {
+	if element, ok := l.buckets[key]; ok {
+		l.lru.MoveToFront(element)
+		bucket := element.Value.(*tokenBucket)
+		if bucket.limit != *limit {
+			*bucket = tokenBucket{key: key, tokens: float64(limit.Burst), updatedAt: now, limit: *limit}
+		}
+		bucket.refill(now)
+		return bucket
+	}
+	for len(l.buckets) >= l.maxKeys {
+		oldest := l.lru.Remove(l.lru.Back()).(*tokenBucket)
+		delete(l.buckets, oldest.key)
+		log.Debugf("Rate limiter tracks %d clients, forgot %s", l.maxKeys, oldest.key)
+	}
+	bucket := &tokenBucket{key: key, tokens: float64(limit.Burst), updatedAt: now, limit: *limit}
+	l.buckets[key] = l.lru.PushFront(bucket)
+	return bucket
+}
+
+// clientKey tells clients apart according to the limit
+func (l *RateLimiter) clientKey(req *http.Request, limit *RateLimit) string {
+	ip := "ip:" + clientIP(req, l.forwardedFor)
+	identity := identityFromContext(req.Context())
+	if identity == nil || limit.Key == RateLimitKeyIP {
+		return ip
+	}
+	user := identity.subject()
+	if limit.Key == RateLimitKeyUserIP {
+		return user + "|" + ip
+	}
+	return user
+}
+
+func routeKey(method, pattern string) string {
+	return fmt.Sprintf("%s %s", method, pattern)
+}
+
+// PreAuthRateLimitMiddleware rejects clients that exceeded the limit of their IP with 429. Runs before
+// JWTMiddleware, so requests with invalid tokens or API keys are limited as well
+func (r *REST) PreAuthRateLimitMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if r.limiter == nil {
+				next.ServeHTTP(w, req)
+				return
+			}
+			result := r.limiter.AllowPreAuth(req)
+			if result.Allowed {
+				next.ServeHTTP(w, req)
+				return
+			}
+			writeRateLimitHeaders(w, result)
+			r.rejectRateLimited(w, req, routeFromContext(req.Context()), result)
+		})
+	}
+}
+
+// RateLimitMiddleware rejects requests of clients that exceeded their limits with 429.
+// Must run after JWTMiddleware to tell authenticated users apart
+func (r *REST) RateLimitMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if r.limiter == nil {
+				next.ServeHTTP(w, req)
+				return
+			}
+			route := routeFromContext(req.Context())
+			result := r.limiter.Allow(req, route)
+			if result.Limit == nil {
+				next.ServeHTTP(w, req)
+				return
+			}
+			writeRateLimitHeaders(w, result)
+			if result.Allowed {
+				next.ServeHTTP(w, req)
+				return
+			}
+			r.rejectRateLimited(w, req, route, result)
+		})
+	}
+}
+
+// rejectRateLimited answers 429 and publishes the rejected request
+func (r *REST) rejectRateLimited(w http.ResponseWriter, req *http.Request, route *RouteEntry, result RateLimitResult) {
+	event := kafka.RateLimitSchema{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Source: req.RemoteAddr,
+		Key:    result.Limit.Key,
+		Policy: result.Limit.policy(),
+	}
+	if route != nil {
+		event.Route = route.Pattern
+	}
+	if identity := identityFromContext(req.Context()); identity != nil {
+		event.UserId = identity.UserId
+	}
+	log.Warnf("Rate limit %s of %s exceeded by %s %s from %s", event.Policy, event.Key, req.Method, req.URL.Path, req.RemoteAddr)
+	r.kafka.LogRateLimited(event)
+
+	w.Header().Set("Retry-After", retryAfterSeconds(result.RetryAfter))
+	writeErrorResponse(w, http.StatusTooManyRequests, "too many requests")
+}
+
+// writeRateLimitHeaders describes the limit with RateLimit-* headers
+func writeRateLimitHeaders(w http.ResponseWriter, result RateLimitResult) {
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit.Burst))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.FormatInt(int64(math.Ceil(result.Reset.Seconds())), 10))
+	w.Header().Set("RateLimit-Policy", result.Limit.policy())
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"github.com/savageking-io/ogbrest/kafka"
+	"github.com/savageking-io/ogbrest/proto"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	type request struct {
+		userId int32 // Anonymous when 0
+		ip     string
+		want   bool
+	}
+	route := &RouteEntry{Method: "GET", Pattern: "/news/{id}", Endpoint: &proto.RestEndpoint{
+		RateLimit: &proto.RestRateLimit{Requests: 1, PeriodMs: 60000},
+	}}
+	tests := []struct {
+		name     string
+		config   RateLimitConfig
+		route    *RouteEntry
+		requests []request
+	}{
+		{"Not limited", RateLimitConfig{}, nil, []request{{1, "10.0.0.1", true}, {1, "10.0.0.1", true}}},
+		{"Burst", RateLimitConfig{Requests: 1, Period: time.Minute, Burst: 2}, nil,
+			[]request{{1, "10.0.0.1", true}, {1, "10.0.0.1", true}, {1, "10.0.0.1", false}}},
+		{"User key", RateLimitConfig{Requests: 1, Period: time.Minute}, nil,
+			[]request{{1, "10.0.0.1", true}, {1, "10.0.0.2", false}, {2, "10.0.0.1", true}}},
+		{"Anonymous clients limited by ip", RateLimitConfig{Requests: 1, Period: time.Minute}, nil,
+			[]request{{0, "10.0.0.1", true}, {0, "10.0.0.1", false}, {0, "10.0.0.2", true}}},
+		{"IP key", RateLimitConfig{Requests: 1, Period: time.Minute, Key: RateLimitKeyIP}, nil,
+			[]request{{1, "10.0.0.1", true}, {2, "10.0.0.1", false}}},
+		{"User and IP key", RateLimitConfig{Requests: 1, Period: time.Minute, Key: RateLimitKeyUserIP}, nil,
+			[]request{{1, "10.0.0.1", true}, {1, "10.0.0.2", true}, {1, "10.0.0.1", false}}},
+		{"Endpoint limit", RateLimitConfig{}, route,
+			[]request{{1, "10.0.0.1", true}, {1, "10.0.0.1", false}}},
+		{"Configuration overrides endpoint", RateLimitConfig{Routes: []RouteRateLimitConfig{{Method: "get", Pattern: "/news/{id}", Requests: 2, Period: time.Minute}}}, route,
+			[]request{{1, "10.0.0.1", true}, {1, "10.0.0.1", true}, {1, "10.0.0.1", false}}},
+		{"Gateway limit applies to limited routes", RateLimitConfig{Requests: 1, Period: time.Minute, Routes: []RouteRateLimitConfig{{Method: "GET", Pattern: "/news/{id}", Requests: 5}}}, route,
+			[]request{{1, "10.0.0.1", true}, {1, "10.0.0.1", false}}},
+		{"Route token kept when gateway rejects", RateLimitConfig{Requests: 1, Period: time.Minute, Key: RateLimitKeyIP, Routes: []RouteRateLimitConfig{{Method: "GET", Pattern: "/news/{id}", Requests: 1, Period: time.Minute, Key: RateLimitKeyUser}}}, route,
+			[]request{{1, "10.0.0.1", true}, {2, "10.0.0.1", false}, {2, "10.0.0.2", true}}},
+		{"Least recently used client forgotten", RateLimitConfig{Requests: 1, Period: time.Minute, MaxKeys: 2}, nil,
+			[]request{{1, "10.0.0.1", true}, {2, "10.0.0.1", true}, {1, "10.0.0.1", false}, {3, "10.0.0.1", true}, {1, "10.0.0.1", false}, {3, "10.0.0.1", false}, {2, "10.0.0.1", true}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := NewRateLimiter(tt.config, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			route := tt.route
+			if route != nil {
+				registered := *route
+				registered.RateLimit = l.RouteLimit(route)
+				route = &registered
+			}
+			for i, r := range tt.requests {
+				req := httptest.NewRequest("GET", "/news/1", nil)
+				req.RemoteAddr = r.ip + ":1234"
+				if r.userId != 0 {
+					req = req.WithContext(withIdentity(req.Context(), &Identity{UserId: r.userId}))
+				}
+				if got := l.Allow(req, route).Allowed; got != r.want {
+					t.Errorf("request %d: Allow() got = %v, want %v", i, got, r.want)
+				}
+			}
+		})
+	}
+
+	if _, err := NewRateLimiter(RateLimitConfig{Requests: 1, Key: "session"}, false); err == nil {
+		t.Errorf("NewRateLimiter() accepted unknown key")
+	}
+}
+
+func TestREST_RateLimitMiddleware(t *testing.T) {
+	limiter, err := NewRateLimiter(RateLimitConfig{Requests: 1, Period: 10 * time.Second}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &REST{limiter: limiter, kafka: new(kafka.Publisher)}
+	r.routes = NewRouteTable(r.RateLimitMiddleware())
+	_ = r.routes.ReplaceServiceRoutes("test", []*RouteEntry{{Method: "GET", Pattern: "/test", Handler: routeHandler(http.StatusOK)}})
+
+	w := httptest.NewRecorder()
+	r.routes.ServeHTTP(w, httptest.NewRequest("GET", "/test", nil))
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Remaining") != "0" || w.Header().Get("RateLimit-Limit") != "1" {
+		t.Errorf("first request got = %d, headers %v", w.Code, w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	r.routes.ServeHTTP(w, httptest.NewRequest("GET", "/test", nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("second request got = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("Retry-After"); got != "10" {
+		t.Errorf("Retry-After got = %s, want 10", got)
+	}
+	if got := w.Header().Get("RateLimit-Policy"); got != "1;w=10;burst=1" {
+		t.Errorf("RateLimit-Policy got = %s", got)
+	}
+}
+
+func TestREST_PreAuthRateLimitMiddleware(t *testing.T) {
+	type request struct {
+		ip    string
+		token string
+		want  int
+	}
+	tests := []struct {
+		name     string
+		config   RateLimitConfig
+		requests []request
+	}{
+		{"Invalid tokens", RateLimitConfig{PreAuth: PreAuthRateLimitConfig{Requests: 2, Period: time.Minute}}, []request{
+			{"10.0.0.1", "wrong", http.StatusUnauthorized},
+			{"10.0.0.1", "wrong", http.StatusUnauthorized},
+			{"10.0.0.1", "wrong", http.StatusTooManyRequests},
+			{"10.0.0.1", "valid", http.StatusTooManyRequests},
+			{"10.0.0.2", "wrong", http.StatusUnauthorized},
+		}},
+		{"Gateway limit by ip is taken once", RateLimitConfig{Requests: 2, Period: time.Minute, Key: RateLimitKeyIP, PreAuth: PreAuthRateLimitConfig{Requests: 3, Period: time.Minute}}, []request{
+			{"10.0.0.1", "valid", http.StatusOK},
+			{"10.0.0.1", "valid", http.StatusOK},
+			{"10.0.0.1", "valid", http.StatusTooManyRequests},
+		}},
+		{"Disabled", RateLimitConfig{}, []request{
+			{"10.0.0.1", "wrong", http.StatusUnauthorized},
+			{"10.0.0.1", "wrong", http.StatusUnauthorized},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter, err := NewRateLimiter(tt.config, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := &REST{limiter: limiter, kafka: new(kafka.Publisher), remote: &countingValidator{users: map[string]int32{"valid": 1}}}
+			r.routes = NewRouteTable(r.PreAuthRateLimitMiddleware(), r.JWTMiddleware(), r.RateLimitMiddleware())
+			_ = r.routes.ReplaceServiceRoutes("test", []*RouteEntry{{Method: "GET", Pattern: "/test", Handler: routeHandler(http.StatusOK)}})
+			for i, step := range tt.requests {
+				req := httptest.NewRequest("GET", "/test", nil)
+				req.RemoteAddr = step.ip + ":1234"
+				req.Header.Set("Authorization", "Bearer "+step.token)
+				w := httptest.NewRecorder()
+				r.routes.ServeHTTP(w, req)
+				if w.Code != step.want {
+					t.Errorf("request %d: got = %d, want %d", i, w.Code, step.want)
+				}
+			}
+		})
+	}
+}
@@ -3,20 +3,36 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
+	"github.com/gorilla/websocket"
 	"github.com/savageking-io/ogbrest/kafka"
 	"github.com/savageking-io/ogbrest/proto"
 	user_client "github.com/savageking-io/ogbuser/client"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"io"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 )
 
+// StatusClientClosedRequest is a non-standard code used to log requests cancelled by the client
+const StatusClientClosedRequest = 499
+
+// DefaultShutdownTimeout is used when configuration doesn't limit draining of in-flight requests
+const DefaultShutdownTimeout = 15 * time.Second
+
 type Route struct {
 	Method string
 	Root   string
@@ -27,13 +43,27 @@ type REST struct {
 	Hostname                string
 	Port                    uint16
 	AllowedOrigins          []string
-	mux                     *chi.Mux
-	RoutesExcludedFromAuth  []string
+	routes                  *RouteTable
 	UserService             *user_client.Client
 	kafka                   *kafka.Publisher
 	WebSocketClients        map[uint32]*WebSocketClient // WebSocket clients that passed authentication and have ID
 	PendingWebSocketClients []*WebSocketClient          // WebSocket clients that didn't pass authentication
 	wscMutex                sync.Mutex
+	webSocketsClosed        bool                   // Set on shutdown, new WebSocket clients are refused
+	serviceStatus           func() []ServiceStatus // Provides state of services for /status
+	server                  *http.Server
+	serverMutex             sync.Mutex
+	cache                   *ResponseCache
+	limiter                 *RateLimiter
+	shedder                 *LoadShedder
+	maxBodyBytes            int64 // Request body limit of routes that don't provide their own
+	maintenance             *Maintenance
+	remote                  TokenValidator // Validates tokens with the user service
+	tokens                  *TokenCache    // Results of remote validation. nil when disabled
+	verifier                *TokenVerifier // Verifies tokens locally. nil in remote mode
+	authFallback            bool           // Tokens with unknown signing keys are validated remotely
+	authorizer              *Authorizer
+	apiKeys                 *APIKeys // nil when no keys are configured
 }
 
 func (r *REST) Init(inConfig *RestConfig, kafkaConfig kafka.Config, user *user_client.Client) error {
@@ -49,25 +79,84 @@ func (r *REST) Init(inConfig *RestConfig, kafkaConfig kafka.Config, user *user_c
 	r.WebSocketClients = make(map[uint32]*WebSocketClient)
 
 	r.UserService = user
+	r.remote = user
+	if tokens := NewTokenCache(inConfig.Auth.Cache, user); tokens != nil {
+		r.tokens = tokens
+		r.remote = tokens
+	}
 
 	r.Hostname = inConfig.Hostname
 	r.Port = inConfig.Port
 	r.AllowedOrigins = inConfig.AllowedOrigins
+	r.maxBodyBytes = inConfig.MaxBodyBytes
+	if r.maxBodyBytes == 0 {
+		r.maxBodyBytes = DefaultMaxBodyBytes
+	}
 
-	r.mux = chi.NewMux()
-	r.mux.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   r.AllowedOrigins,
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
-		MaxAge:           300,
-	}))
-	// Apply JWT middleware globally; it will skip paths present in RoutesExcludedFromAuth
-	r.mux.Use(r.JWTMiddleware())
-
-	// Default exclusions
-	r.AddToAuthIgnoreList("/status")
+	cache, err := NewResponseCache(inConfig.Cache)
+	if err != nil {
+		return fmt.Errorf("failed to initialize response cache: %w", err)
+	}
+	r.cache = cache
+
+	limiter, err := NewRateLimiter(inConfig.RateLimit, inConfig.ForwardedFor)
+	if err != nil {
+		return fmt.Errorf("failed to initialize rate limiter: %w", err)
+	}
+	r.limiter = limiter
+	r.shedder = NewLoadShedder(inConfig.LoadShedding)
+
+	maintenance, err := NewMaintenance(inConfig.Maintenance, inConfig.ForwardedFor)
+	if err != nil {
+		return fmt.Errorf("failed to initialize maintenance: %w", err)
+	}
+	r.maintenance = maintenance
+
+	r.authorizer = NewAuthorizer(inConfig.Auth)
+	apiKeys, err := NewAPIKeys(inConfig.Auth)
+	if err != nil {
+		return fmt.Errorf("failed to initialize API keys: %w", err)
+	}
+	r.apiKeys = apiKeys
+	switch inConfig.Auth.Mode {
+	case "", AuthModeRemote:
+		log.Infof("Tokens are validated by the user service")
+	case AuthModeLocal:
+		verifier, err := NewTokenVerifier(inConfig.Auth)
+		if err != nil {
+			return fmt.Errorf("failed to initialize token verifier: %w", err)
+		}
+		verifier.Start()
+		r.verifier = verifier
+		r.authFallback = inConfig.Auth.Fallback
+		log.Infof("Tokens are verified locally, fallback to the user service: %v", r.authFallback)
+	default:
+		return fmt.Errorf("unknown auth mode %s", inConfig.Auth.Mode)
+	}
+
+	allowedHeaders := []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"}
+	if header := r.apiKeys.Header(); header != "" {
+		allowedHeaders = append(allowedHeaders, header)
+	}
+	r.routes = NewRouteTable(
+		cors.Handler(cors.Options{
+			AllowedOrigins:   r.AllowedOrigins,
+			AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			AllowedHeaders:   allowedHeaders,
+			ExposedHeaders:   []string{"Link", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy"},
+			AllowCredentials: true,
+			MaxAge:           300,
+		}),
+		// Bodies are limited before anything reads them
+		r.BodyLimitMiddleware(),
+		// Clients are limited by IP before authentication, so invalid credentials can't be tried endlessly
+		r.PreAuthRateLimitMiddleware(),
+		// Applied to every route according to its auth policy
+		r.JWTMiddleware(),
+		r.MaintenanceMiddleware(),
+		// Limits are applied after authentication, so users can be told apart
+		r.RateLimitMiddleware(),
+	)
 
 	r.kafka = new(kafka.Publisher)
 	if err := r.kafka.Init(kafkaConfig); err != nil {
@@ -79,18 +168,106 @@ func (r *REST) Init(inConfig *RestConfig, kafkaConfig kafka.Config, user *user_c
 
 func (r *REST) Start() error {
 	log.Traceln("REST::Start")
-	r.mux.Get("/", func(w http.ResponseWriter, req *http.Request) {
-		// For default empty route return 404
-		w.WriteHeader(http.StatusNotFound)
+	err := r.replaceRoutes(GatewayRouteOwner, []*RouteEntry{
+		{Method: http.MethodGet, Pattern: "/", Handler: func(w http.ResponseWriter, req *http.Request) {
+			// For default empty route return 404
+			w.WriteHeader(http.StatusNotFound)
+		}},
+		{Method: http.MethodGet, Pattern: "/status", Auth: AuthPolicyNone, Handler: r.HandleStatusRequest},
+		{Method: http.MethodGet, Pattern: "/ws", Handler: r.HandleWebSocket},
 	})
-	r.mux.Get("/status", r.HandleStatusRequest)
-	r.mux.Get("/ws", r.HandleWebSocket)
+	if err != nil {
+		return err
+	}
 
-	return http.ListenAndServe(fmt.Sprintf("%s:%d", r.Hostname, r.Port), r.mux)
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", r.Hostname, r.Port))
+	if err != nil {
+		return err
+	}
+	return r.Serve(listener)
+}
+
+// Serve handles requests on the listener until Shutdown is called
+func (r *REST) Serve(listener net.Listener) error {
+	log.Traceln("REST::Serve")
+	r.serverMutex.Lock()
+	if r.server != nil {
+		r.serverMutex.Unlock()
+		return fmt.Errorf("REST is already serving")
+	}
+	r.server = &http.Server{Handler: r.routes}
+	server := r.server
+	r.serverMutex.Unlock()
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops accepting connections, closes WebSocket sessions and waits for in-flight requests
+// until the context is done. Requests still running after that are cut off
+func (r *REST) Shutdown(ctx context.Context) error {
+	log.Traceln("REST::Shutdown")
+	// Upgrades are refused first, so no WebSocket connects after the others were closed
+	r.wscMutex.Lock()
+	r.webSocketsClosed = true
+	r.wscMutex.Unlock()
+	r.CloseWebSockets(websocket.CloseGoingAway, "server is shutting down")
+	r.verifier.Stop()
+
+	r.serverMutex.Lock()
+	server := r.server
+	r.serverMutex.Unlock()
+	if server == nil {
+		return nil
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		log.Warnf("In-flight requests were not drained in time: %s", err.Error())
+		_ = server.Close()
+		return err
+	}
+	return nil
+}
+
+// CloseWebSockets sends close frame to every WebSocket client and disconnects it
+func (r *REST) CloseWebSockets(code int, reason string) {
+	log.Traceln("REST::CloseWebSockets")
+	r.wscMutex.Lock()
+	clients := make([]*WebSocketClient, 0, len(r.PendingWebSocketClients)+len(r.WebSocketClients))
+	clients = append(clients, r.PendingWebSocketClients...)
+	for _, client := range r.WebSocketClients {
+		clients = append(clients, client)
+	}
+	r.PendingWebSocketClients = nil
+	r.WebSocketClients = make(map[uint32]*WebSocketClient)
+	r.wscMutex.Unlock()
+
+	if len(clients) > 0 {
+		log.Infof("Closing %d WebSocket connections", len(clients))
+	}
+	var wg sync.WaitGroup
+	for _, client := range clients {
+		wg.Add(1)
+		go func(client *WebSocketClient) {
+			defer wg.Done()
+			if err := client.Close(code, reason); err != nil {
+				log.Debugf("Failed to close WebSocket connection: %s", err.Error())
+			}
+		}(client)
+	}
+	wg.Wait()
 }
 
 func (r *REST) HandleWebSocket(w http.ResponseWriter, req *http.Request) {
 	log.Traceln("REST::HandleWebSocket")
+	r.wscMutex.Lock()
+	closed := r.webSocketsClosed
+	r.wscMutex.Unlock()
+	if closed {
+		writeErrorResponse(w, http.StatusServiceUnavailable, "server is shutting down")
+		return
+	}
 
 	newClient, err := NewWebSocketClient(w, req)
 	if err != nil {
@@ -104,43 +281,40 @@ func (r *REST) HandleWebSocket(w http.ResponseWriter, req *http.Request) {
 	}
 
 	r.wscMutex.Lock()
+	if r.webSocketsClosed {
+		// Shutdown started while the connection was upgraded
+		r.wscMutex.Unlock()
+		if err := newClient.Close(websocket.CloseGoingAway, "server is shutting down"); err != nil {
+			log.Debugf("Failed to close WebSocket connection: %s", err.Error())
+		}
+		return
+	}
 	r.PendingWebSocketClients = append(r.PendingWebSocketClients, newClient)
 	r.wscMutex.Unlock()
 	go newClient.Run()
 }
 
-func (r *REST) AddToAuthIgnoreList(uri string) {
-	r.RoutesExcludedFromAuth = append(r.RoutesExcludedFromAuth, uri)
-}
-
 func (r *REST) JWTMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-			// @TODO: Fix this
 			log.Tracef("[JWTMiddleware] Request: %s %s", req.Method, req.URL.Path)
-			requestPath := req.URL.Path
-			requestKey := fmt.Sprintf("%s:%s", req.Method, requestPath)
-			for _, ex := range r.RoutesExcludedFromAuth {
-				log.Warnf("[JWTMiddleware] Checking path %s against %s", ex, requestPath)
-				// Exact or prefix path match (e.g., "/status" or "/api/public")
-				if requestPath == ex || strings.HasPrefix(requestPath, ex) {
-					next.ServeHTTP(w, req)
+			// Policy of the matched route decides. Requests that match no route are authenticated too,
+			// so they can't probe which routes exist
+			route := routeFromContext(req.Context())
+			if route != nil && route.Auth == AuthPolicyNone {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			if header := r.apiKeys.Header(); header != "" && req.Header.Get(header) != "" {
+				identity, err := r.apiKeys.Authenticate(req.Header.Get(header))
+				if err != nil {
+					log.Warnf("[JWTMiddleware] Rejected API key for %s %s from %s", req.Method, req.URL.Path, req.RemoteAddr)
+					http.Error(w, "Invalid or expired API key", http.StatusUnauthorized)
 					return
 				}
-				// Support entries like METHOD:PATH coming from services, where PATH may be without root
-				// Match exact METHOD:FULL_PATH or suffix METHOD:ENDPOINT_PATH
-				if strings.Contains(ex, ":") {
-					if requestKey == ex || strings.HasSuffix(requestKey, ex) {
-						next.ServeHTTP(w, req)
-						return
-					}
-				} else {
-					// Also allow suffix match on path (ignore unknown root prefix)
-					if strings.HasSuffix(requestPath, ex) {
-						next.ServeHTTP(w, req)
-						return
-					}
-				}
+				r.serveAuthenticated(w, req, route, identity, next)
+				return
 			}
 
 			authHeader := req.Header.Get("Authorization")
@@ -155,35 +329,75 @@ func (r *REST) JWTMiddleware() func(http.Handler) http.Handler {
 				return
 			}
 
-			if r.UserService == nil {
-				http.Error(w, "User service is not initialized", http.StatusServiceUnavailable)
-				return
-			}
-
-			isValid, userId, err := r.UserService.ValidateToken(context.Background(), tokenString)
+			identity, err := r.authenticate(req.Context(), tokenString)
 			if err != nil {
+				if errors.Is(err, ErrUserServiceUnavailable) {
+					http.Error(w, "User service is not initialized", http.StatusServiceUnavailable)
+					return
+				}
+				if errors.Is(err, ErrInvalidToken) {
+					http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+					return
+				}
 				log.Errorf("Failed to validate token: %s", err.Error())
 				http.Error(w, "Failed to validate token", http.StatusUnauthorized)
 				return
 			}
 
-			if !isValid {
-				http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
-				return
-			}
-
-			log.Tracef("[JWTMiddleware] Request handled")
-			ctx := context.WithValue(req.Context(), "user_id", userId)
-			next.ServeHTTP(w, req.WithContext(ctx))
+			r.serveAuthenticated(w, req, route, identity, next)
 		})
 	}
 }
 
+// serveAuthenticated passes request of the caller on if it's authorized to call the route
+func (r *REST) serveAuthenticated(w http.ResponseWriter, req *http.Request, route *RouteEntry, identity *Identity, next http.Handler) {
+	if !r.authorizer.Authorize(identity, route) {
+		writeForbidden(w, req, route)
+		return
+	}
+
+	log.Tracef("[JWTMiddleware] Request handled")
+	ctx := withIdentity(req.Context(), identity)
+	next.ServeHTTP(w, req.WithContext(ctx))
+}
+
+// authenticate returns the caller the token was issued to. In local mode tokens are verified with signing keys
+// and only tokens with unknown keys are validated by the user service, if fallback is enabled
+func (r *REST) authenticate(ctx context.Context, token string) (*Identity, error) {
+	if r.verifier != nil {
+		identity, err := r.verifier.Verify(token)
+		if err == nil {
+			return identity, nil
+		}
+		if !errors.Is(err, ErrNoSigningKey) || !r.authFallback {
+			log.Debugf("[JWTMiddleware] Token rejected: %s", err.Error())
+			return nil, ErrInvalidToken
+		}
+		log.Debugf("[JWTMiddleware] Signing key of token is not known, validating it with the user service")
+	}
+
+	if r.remote == nil {
+		return nil, ErrUserServiceUnavailable
+	}
+	isValid, userId, err := r.remote.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if !isValid {
+		return nil, ErrInvalidToken
+	}
+	// Token is authentic once the user service validated it, so its claims can be trusted
+	return &Identity{UserId: userId, Claims: unverifiedClaims(token), AuthMethod: AuthMethodJWT}, nil
+}
+
 func (r *REST) HandleStatusRequest(w http.ResponseWriter, req *http.Request) {
 	log.Traceln("REST::HandleStatusRequest")
 	data := make(map[string]interface{})
 	data["code"] = 0
 	data["date"] = time.Now().String()
+	if r.serviceStatus != nil {
+		data["breakers"] = breakerSummary(r.serviceStatus())
+	}
 
 	response, _ := json.Marshal(data)
 	_, err := w.Write(response)
@@ -193,22 +407,196 @@ func (r *REST) HandleStatusRequest(w http.ResponseWriter, req *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-func (r *REST) RegisterNewRoute(root, method, uri string, client *Client) error {
-	log.Traceln("REST::RegisterNewRoute")
-	fullUri := fmt.Sprintf("%s%s", sanitizeRoot(root), sanitizeUri(uri))
+// SetServiceStatusProvider sets the source of service state reported by /status
+func (r *REST) SetServiceStatusProvider(provider func() []ServiceStatus) {
+	r.serviceStatus = provider
+}
+
+// breakerSummary counts instances of every service by breaker state. Instance addresses are
+// not included as /status is public
+func breakerSummary(services []ServiceStatus) map[string]map[BreakerState]int {
+	result := make(map[string]map[BreakerState]int)
+	for _, service := range services {
+		states := make(map[BreakerState]int)
+		for _, instance := range service.Instances {
+			states[instance.Breaker]++
+		}
+		result[service.Label] = states
+	}
+	return result
+}
+
+// RouteStatus describes a route of the routing table
+type RouteStatus struct {
+	Method       string   `json:"method"`
+	Pattern      string   `json:"pattern"`
+	Owner        string   `json:"owner"`
+	Auth         string   `json:"auth"` // jwt or none
+	Stream       bool     `json:"stream,omitempty"`
+	TimeoutMs    int32    `json:"timeout_ms,omitempty"`
+	RateLimit    string   `json:"rate_limit,omitempty"` // Limit of the route in RateLimit-Policy format
+	MaxBodyBytes int64    `json:"max_body_bytes"`       // Request body limit, 0 when body is not limited
+	Maintenance  bool     `json:"maintenance,omitempty"`
+	Roles        []string `json:"roles,omitempty"`  // Token must carry one of the roles
+	Scopes       []string `json:"scopes,omitempty"` // Token must carry all the scopes
+}
+
+// RouteStatus returns all the routes with their owners and auth policy
+func (r *REST) RouteStatus() []RouteStatus {
+	routes := r.routes.Routes()
+	result := make([]RouteStatus, 0, len(routes))
+	for _, route := range routes {
+		status := RouteStatus{
+			Method:  route.Method,
+			Pattern: route.Pattern,
+			Owner:   route.Owner,
+			Auth:    route.Auth,
+		}
+		if route.Endpoint != nil {
+			status.Stream = route.Endpoint.Stream
+			status.TimeoutMs = route.Endpoint.TimeoutMs
+			status.Roles = route.Endpoint.Roles
+			status.Scopes = route.Endpoint.Scopes
+		}
+		status.MaxBodyBytes = r.BodyLimit(route)
+		status.Maintenance = r.maintenance.Find(route) != nil
+		if route.RateLimit != nil {
+			status.RateLimit = route.RateLimit.policy()
+		}
+		result = append(result, status)
+	}
+	return result
+}
 
-	r.mux.MethodFunc(method, fullUri, func(w http.ResponseWriter, req *http.Request) {
+// WebSocketStatus returns pending and authenticated WebSocket clients
+func (r *REST) WebSocketStatus() (pending []WebSocketStatus, authenticated []WebSocketStatus) {
+	r.wscMutex.Lock()
+	defer r.wscMutex.Unlock()
+	pending = make([]WebSocketStatus, 0, len(r.PendingWebSocketClients))
+	for _, client := range r.PendingWebSocketClients {
+		pending = append(pending, client.Status())
+	}
+	authenticated = make([]WebSocketStatus, 0, len(r.WebSocketClients))
+	for id, client := range r.WebSocketClients {
+		status := client.Status()
+		status.Id = id
+		authenticated = append(authenticated, status)
+	}
+	sort.Slice(authenticated, func(i, j int) bool {
+		return authenticated[i].Id < authenticated[j].Id
+	})
+	return pending, authenticated
+}
+
+// UpdateServiceRoutes replaces all the routes of the service with endpoints it provided
+func (r *REST) UpdateServiceRoutes(root string, endpoints []*proto.RestEndpoint, backend Backend) error {
+	log.Traceln("REST::UpdateServiceRoutes")
+	if backend == nil {
+		return fmt.Errorf("no backend provided")
+	}
+	routes := make([]*RouteEntry, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if endpoint == nil {
+			return fmt.Errorf("no endpoint provided")
+		}
+		routes = append(routes, &RouteEntry{
+			Method:   endpoint.Method,
+			Pattern:  fmt.Sprintf("%s%s", sanitizeRoot(root), sanitizeUri(endpoint.Path)),
+			Endpoint: endpoint,
+			Handler:  r.proxyHandler(endpoint, backend),
+		})
+	}
+	return r.replaceRoutes(backend.ServiceLabel(), routes)
+}
+
+// replaceRoutes resolves rate limits of the routes, so requests don't parse them, and replaces routes of the owner
+func (r *REST) replaceRoutes(owner string, routes []*RouteEntry) error {
+	for _, route := range routes {
+		route.RateLimit = r.limiter.RouteLimit(route)
+	}
+	return r.routes.ReplaceServiceRoutes(owner, routes)
+}
+
+// RemoveServiceRoutes removes all the routes of the service
+func (r *REST) RemoveServiceRoutes(label string) {
+	log.Traceln("REST::RemoveServiceRoutes")
+	r.routes.RemoveService(label)
+}
+
+// HandleGatewayEvent applies event sent by the service. Root is the root of service routes
+func (r *REST) HandleGatewayEvent(label string, root string, event *proto.GatewayEvent) {
+	log.Traceln("REST::HandleGatewayEvent")
+	if purge := event.GetCachePurge(); purge != nil {
+		paths := make([]string, len(purge.Paths))
+		for i, path := range purge.Paths {
+			paths[i] = fmt.Sprintf("%s%s", sanitizeRoot(root), sanitizeUri(path))
+		}
+		prefixes := make([]string, len(purge.Prefixes))
+		for i, prefix := range purge.Prefixes {
+			prefixes[i] = fmt.Sprintf("%s%s", sanitizeRoot(root), prefix)
+		}
+		removed := r.cache.Purge(label, paths, prefixes, purge.All)
+		log.Infof("Service [%s] purged %d cached responses", label, removed)
+	}
+	if revocation := event.GetTokenRevocation(); revocation != nil {
+		removed := r.tokens.RevokeUsers(revocation.UserIds...) + r.tokens.RevokeTokens(revocation.Tokens...)
+		log.Infof("Service [%s] revoked tokens of %d users and %d tokens, %d cached results removed",
+			label, len(revocation.UserIds), len(revocation.Tokens), removed)
+	}
+}
+
+// proxyHandler creates handler that forwards requests of the endpoint to the service
+func (r *REST) proxyHandler(endpoint *proto.RestEndpoint, backend Backend) http.HandlerFunc {
+	uri := endpoint.Path
+	return func(w http.ResponseWriter, req *http.Request) {
 		r.kafka.LogRequest(req)
-		request := r.httpRequestToProto(req)
-		if request == nil {
-			log.Errorf("Failed to convert HTTP request to proto")
+		release, err := r.shedder.Acquire()
+		if err != nil {
+			log.Warnf("Shedding %s %s of [%s]: %s", req.Method, req.URL.Path, backend.ServiceLabel(), err.Error())
+			w.Header().Set("Retry-After", retryAfterSeconds(OverloadRetryAfter))
+			writeErrorResponse(w, http.StatusServiceUnavailable, "gateway is overloaded")
+			return
+		}
+		defer release()
+
+		if endpoint.Stream {
+			r.handleStreamRequest(w, req, endpoint, backend)
+			return
+		}
+
+		cacheable := req.Method == http.MethodGet && r.cache != nil
+		directives := parseCacheControl(req.Header.Get("Cache-Control"))
+		_, noCache := directives["no-cache"]
+		_, noStore := directives["no-store"]
+		if cacheable && !noCache && !noStore {
+			if response, storedAt, ok := r.cache.Lookup(backend.ServiceLabel(), req); ok {
+				w.Header().Set("Age", strconv.FormatInt(int64(time.Since(storedAt).Seconds()), 10))
+				w.Header().Set("X-Cache", "HIT")
+				writeProxyResponse(w, req, response)
+				return
+			}
+		}
+
+		request, err := r.httpRequestToProto(req)
+		if err != nil {
+			if limit, ok := isBodyTooLarge(err); ok {
+				writeBodyTooLarge(w, req, limit)
+				return
+			}
+			log.Errorf("Failed to convert HTTP request to proto: %s", err.Error())
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 		request.Uri = uri
 
-		response, err := client.HandleRestRequest(request)
+		ctx, cancel := backend.RequestContext(req.Context(), endpoint)
+		defer cancel()
+
+		response, err := backend.HandleRestRequest(ctx, endpoint, request)
 		if err != nil {
+			if handleBackendError(w, req, backend, err) {
+				return
+			}
 			log.Errorf("Failed to handle REST request: %s", err.Error())
 
 			if response != nil {
@@ -224,12 +612,7 @@ func (r *REST) RegisterNewRoute(root, method, uri string, client *Client) error
 
 			if response != nil && response.Code != 0 {
 				// We have an internal error code provided. Return it to the client as json
-				ret := make(map[string]interface{})
-				ret["code"] = response.Code
-				ret["error"] = response.Error
-				ret["date"] = time.Now().String()
-				responseBody, _ := json.Marshal(ret)
-				_, _ = w.Write(responseBody)
+				writeErrorBody(w, response.Code, response.Error)
 			}
 
 			return
@@ -242,46 +625,286 @@ func (r *REST) RegisterNewRoute(root, method, uri string, client *Client) error
 			return
 		}
 
-		for _, header := range response.Headers {
-			log.Tracef("Writing header %s: %s", header.Key, header.Value)
-			w.Header().Set(header.Key, header.Value)
+		if req.Method == http.MethodGet && (response.HttpCode == 0 || response.HttpCode == http.StatusOK) {
+			withETag(response)
+			if cacheable {
+				w.Header().Set("X-Cache", "MISS")
+				if !noStore {
+					r.cache.Store(backend.ServiceLabel(), req, response)
+				}
+			}
 		}
+		writeProxyResponse(w, req, response)
+	}
+}
+
+// writeProxyResponse writes response of the service. Response may be shared with the cache and is not modified.
+// Conditional GET requests with a matching ETag are answered with 304
+func writeProxyResponse(w http.ResponseWriter, req *http.Request, response *proto.RestApiResponse) {
+	writeResponseHeaders(w, response)
+
+	success := response.HttpCode == 0 || response.HttpCode == http.StatusOK
+	if req.Method == http.MethodGet && success && etagMatches(req.Header.Get("If-None-Match"), responseHeader(response, "ETag")) {
+		w.Header().Del("Content-Type")
+		w.Header().Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if response.HttpCode != 0 {
+		w.WriteHeader(int(response.HttpCode))
+	} else {
+		log.Warnf("No HTTP code provided for response. Using 200. Check service implementation")
+	}
+
+	if len(response.RawBody) > 0 {
+		log.Tracef("Writing raw response body of %d bytes", len(response.RawBody))
+		_, _ = w.Write(response.RawBody)
+		return
+	}
+
+	body := response.Body
+	if body == "" && response.Error != "" {
+		body = "{'error': '" + response.Error + "'}"
+	}
+
+	log.Tracef("Writing response body: %s", body)
+	_, _ = w.Write([]byte(body))
+}
+
+// handleStreamRequest proxies request to the service over NewRestStreamRequest. Request body is forwarded
+// while it's being received and response body is written to the client as soon as chunks arrive
+func (r *REST) handleStreamRequest(w http.ResponseWriter, req *http.Request, endpoint *proto.RestEndpoint, backend Backend) {
+	log.Traceln("REST::handleStreamRequest")
+	request := r.httpRequestHeadToProto(req)
+	request.Uri = endpoint.Path
 
-		if response.HttpCode != 0 {
-			w.WriteHeader(int(response.HttpCode))
-		} else {
-			log.Warnf("No HTTP code provided for response. Using 200. Check service implementation")
+	ctx, cancel := backend.RequestContext(req.Context(), endpoint)
+	defer cancel()
+
+	stream, err := backend.NewRestStream(ctx, request)
+	if err != nil {
+		if handleBackendError(w, req, backend, err) {
+			return
 		}
+		log.Errorf("Failed to open REST stream: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 
-		if response.Body == "" && response.Error != "" {
-			response.Body = "{'error': '" + response.Error + "'}"
+	controller := http.NewResponseController(w)
+	// Response may start before the request body is fully uploaded
+	_ = controller.EnableFullDuplex()
+
+	bodyErr := make(chan error, 1)
+	go func() {
+		err := sendStreamBody(stream, req.Body)
+		if err != nil && ctx.Err() == nil {
+			log.Errorf("Failed to stream request body: %s", err.Error())
+			cancel()
 		}
+		bodyErr <- err
+	}()
+
+	// Request body can't be read once the handler returns, so the upload is stopped and waited for
+	var uploadErr error
+	var uploadOnce sync.Once
+	finishUpload := func() error {
+		uploadOnce.Do(func() {
+			select {
+			case uploadErr = <-bodyErr:
+			default:
+				cancel()
+				// Unblocks reading of the body, which doesn't watch ctx
+				_ = controller.SetReadDeadline(time.Now())
+				uploadErr = <-bodyErr
+			}
+		})
+		return uploadErr
+	}
+	defer finishUpload()
 
-		log.Tracef("Writing response body: %s", response.Body)
-		_, _ = w.Write([]byte(response.Body))
+	head, err := receiveStreamHead(stream)
+	if err != nil {
+		if limit, ok := isBodyTooLarge(finishUpload()); ok {
+			writeBodyTooLarge(w, req, limit)
+			return
+		}
+		if handleBackendError(w, req, backend, err) {
+			return
+		}
+		log.Errorf("Failed to handle REST stream request: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
 		return
-	})
+	}
 
-	return nil
+	writeResponseHeaders(w, head)
+	if head.HttpCode != 0 {
+		w.WriteHeader(int(head.HttpCode))
+	} else {
+		log.Warnf("No HTTP code provided for stream response. Using 200. Check service implementation")
+	}
+
+	if err := copyStreamBody(stream, w); err != nil {
+		log.Errorf("Failed to handle REST stream request: %s", err.Error())
+	}
 }
 
-func (r *REST) httpRequestToProto(req *http.Request) *proto.RestApiRequest {
-	log.Tracef("REST::httpRequestToProto")
+// handleBackendError handles calls to the service that ended because of cancellation, deadline or
+// because the service can't take requests at all. Returns false for other errors the caller should handle
+func handleBackendError(w http.ResponseWriter, req *http.Request, backend Backend, err error) bool {
+	if req.Context().Err() != nil {
+		// Client is gone - nobody will read the response
+		log.Infof("[%d] Client closed request %s %s before service [%s] responded", StatusClientClosedRequest, req.Method, req.URL.Path, backend.ServiceLabel())
+		return true
+	}
+	if errors.Is(err, context.DeadlineExceeded) || status.Code(err) == codes.DeadlineExceeded {
+		log.Warnf("Service [%s] did not respond to %s %s in time", backend.ServiceLabel(), req.Method, req.URL.Path)
+		writeErrorResponse(w, http.StatusGatewayTimeout, "service did not respond in time")
+		return true
+	}
+	var breakerErr *BreakerOpenError
+	if errors.As(err, &breakerErr) {
+		log.Warnf("Service [%s] rejected %s %s: %s", backend.ServiceLabel(), req.Method, req.URL.Path, breakerErr.Error())
+		w.Header().Set("Retry-After", retryAfterSeconds(breakerErr.RetryAfter))
+		writeErrorResponse(w, http.StatusServiceUnavailable, "service temporarily unavailable")
+		return true
+	}
+	if errors.Is(err, ErrBulkheadFull) {
+		log.Warnf("Service [%s] has too many requests in flight, rejecting %s %s", backend.ServiceLabel(), req.Method, req.URL.Path)
+		w.Header().Set("Retry-After", retryAfterSeconds(OverloadRetryAfter))
+		writeErrorResponse(w, http.StatusServiceUnavailable, "service is overloaded")
+		return true
+	}
+	if errors.Is(err, ErrNoInstances) {
+		log.Errorf("Service [%s] has no instances to handle %s %s", backend.ServiceLabel(), req.Method, req.URL.Path)
+		writeErrorResponse(w, http.StatusServiceUnavailable, "service unavailable")
+		return true
+	}
+	if errors.Is(err, ErrNoHealthyInstances) || errors.Is(err, ErrClientNotConnected) {
+		log.Warnf("Service [%s] is unhealthy, rejecting %s %s", backend.ServiceLabel(), req.Method, req.URL.Path)
+		writeErrorResponse(w, http.StatusServiceUnavailable, "service unavailable")
+		return true
+	}
+	return false
+}
+
+// retryAfterSeconds formats duration for Retry-After header, rounding up to whole seconds
+func retryAfterSeconds(d time.Duration) string {
+	seconds := int64(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.FormatInt(seconds, 10)
+}
+
+// writeErrorResponse responds with a json error generated by the gateway itself
+func writeErrorResponse(w http.ResponseWriter, httpCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpCode)
+	writeErrorBody(w, int32(httpCode), message)
+}
+
+func writeErrorBody(w http.ResponseWriter, code int32, message string) {
+	ret := make(map[string]interface{})
+	ret["code"] = code
+	ret["error"] = message
+	ret["date"] = time.Now().String()
+	responseBody, _ := json.Marshal(ret)
+	_, _ = w.Write(responseBody)
+}
+
+// writeResponseHeaders copies headers provided by the service into the HTTP response.
+// Headers set by the service replace ones set by the gateway, but all values provided by the service are kept,
+// so multiple Set-Cookie headers reach the client
+func writeResponseHeaders(w http.ResponseWriter, response *proto.RestApiResponse) {
+	replaced := make(map[string]bool)
+	for _, header := range response.Headers {
+		values := header.Values
+		if len(values) == 0 {
+			values = []string{header.Value}
+		}
+		key := http.CanonicalHeaderKey(header.Key)
+		if !replaced[key] {
+			w.Header().Del(key)
+			replaced[key] = true
+		}
+		for _, value := range values {
+			log.Tracef("Writing header %s: %s", key, value)
+			w.Header().Add(key, value)
+		}
+	}
+	if response.ContentType != "" && w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", response.ContentType)
+	}
+}
+
+// httpRequestHeadToProto converts everything but the body of HTTP request
+func (r *REST) httpRequestHeadToProto(req *http.Request) *proto.RestApiRequest {
+	log.Tracef("REST::httpRequestHeadToProto")
+	identity := identityFromContext(req.Context())
+	// API keys stay in the gateway, services get the identity of the key instead
+	apiKeyHeader := http.CanonicalHeaderKey(r.apiKeys.Header())
 	var headers []*proto.RestHeader
 	for k, v := range req.Header {
+		if k == apiKeyHeader || (k == "Authorization" && identity != nil && identity.AuthMethod == AuthMethodAPIKey) {
+			continue
+		}
 		headers = append(headers, &proto.RestHeader{
-			Key:   k,
-			Value: v[0],
+			Key:    k,
+			Value:  v[0],
+			Values: v,
+		})
+	}
+
+	var query []*proto.RestApiFormData
+	for key, values := range req.URL.Query() {
+		query = append(query, &proto.RestApiFormData{
+			Key:   key,
+			Value: values,
 		})
 	}
 
+	var pathParams []*proto.RestPathParam
+	if rctx := chi.RouteContext(req.Context()); rctx != nil {
+		for i, key := range rctx.URLParams.Keys {
+			// Params are still escaped when chi routed by the escaped path
+			value, err := url.PathUnescape(rctx.URLParams.Values[i])
+			if err != nil {
+				value = rctx.URLParams.Values[i]
+			}
+			pathParams = append(pathParams, &proto.RestPathParam{
+				Key:   key,
+				Value: value,
+			})
+		}
+	}
+
+	return &proto.RestApiRequest{
+		Method:      req.Method,
+		Headers:     headers,
+		Source:      req.RemoteAddr,
+		ContentType: req.Header.Get("Content-Type"),
+		Path:        req.URL.Path,
+		RawQuery:    req.URL.RawQuery,
+		Query:       query,
+		PathParams:  pathParams,
+		Identity:    identity.toProto(),
+	}
+}
+
+func (r *REST) httpRequestToProto(req *http.Request) (*proto.RestApiRequest, error) {
+	log.Tracef("REST::httpRequestToProto")
+	request := r.httpRequestHeadToProto(req)
+
 	body, err := io.ReadAll(req.Body)
 	if err != nil {
-		log.Errorf("Failed to read request body: %s", err.Error())
-		return nil
+		return nil, fmt.Errorf("failed to read request body: %w", err)
 	}
+	// proto string fields must be valid UTF-8, so binary payloads are only available in RawBody.
+	// Body is still filled for text payloads to keep services that read it working
 	var bodyString string
-	if len(body) > 0 {
+	if len(body) > 0 && utf8.Valid(body) {
 		bodyString = string(body)
 	}
 
@@ -289,8 +912,7 @@ func (r *REST) httpRequestToProto(req *http.Request) *proto.RestApiRequest {
 	if req.Method == "POST" {
 		err = req.ParseForm()
 		if err != nil {
-			log.Errorf("Failed to parse request body: %s", err.Error())
-			return nil
+			return nil, fmt.Errorf("failed to parse request body: %w", err)
 		}
 		for key, values := range req.Form {
 			form := &proto.RestApiFormData{
@@ -303,11 +925,8 @@ func (r *REST) httpRequestToProto(req *http.Request) *proto.RestApiRequest {
 		}
 	}
 
-	return &proto.RestApiRequest{
-		Method:  req.Method,
-		Headers: headers,
-		Body:    bodyString,
-		Source:  req.RemoteAddr,
-		Form:    formData,
-	}
+	request.Body = bodyString
+	request.RawBody = body
+	request.Form = formData
+	return request, nil
 }
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/savageking-io/ogbrest/kafka"
+	"github.com/savageking-io/ogbrest/proto"
+	protobuf "google.golang.org/protobuf/proto"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestREST_Shutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	r := &REST{routes: NewRouteTable(), WebSocketClients: make(map[uint32]*WebSocketClient)}
+	_ = r.routes.ReplaceServiceRoutes(GatewayRouteOwner, []*RouteEntry{
+		{Method: "GET", Pattern: "/ws", Handler: r.HandleWebSocket},
+		{Method: "GET", Pattern: "/slow", Handler: func(w http.ResponseWriter, req *http.Request) {
+			close(started)
+			<-release
+			w.WriteHeader(http.StatusAccepted)
+		}},
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	served := make(chan error, 1)
+	go func() {
+		served <- r.Serve(listener)
+	}()
+
+	ws, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://%s/ws", address), nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer ws.Close()
+	for pending, _ := r.WebSocketStatus(); len(pending) == 0; pending, _ = r.WebSocketStatus() {
+		time.Sleep(time.Millisecond)
+	}
+
+	inFlight := make(chan int, 1)
+	go func() {
+		response, err := http.Get(fmt.Sprintf("http://%s/slow", address))
+		if err != nil {
+			inFlight <- 0
+			return
+		}
+		_ = response.Body.Close()
+		inFlight <- response.StatusCode
+	}()
+	<-started
+
+	shutdownDone := make(chan error, 1)
+	go func() {
+		shutdownDone <- r.Shutdown(context.Background())
+	}()
+
+	// WebSocket clients are told that the server is going away
+	_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = ws.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway {
+		t.Errorf("ReadMessage() error = %v, want close %d", err, websocket.CloseGoingAway)
+	}
+
+	// New connections are refused while in-flight request is drained
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", address)
+		if err != nil {
+			break
+		}
+		_ = conn.Close()
+		if time.Now().After(deadline) {
+			t.Fatalf("listener is still accepting connections")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	if code := <-inFlight; code != http.StatusAccepted {
+		t.Errorf("in-flight request got = %d, want %d", code, http.StatusAccepted)
+	}
+	if err := <-shutdownDone; err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Serve() error = %v", err)
+	}
+}
+
+func TestREST_HandleWebSocket_Shutdown(t *testing.T) {
+	r := &REST{WebSocketClients: make(map[uint32]*WebSocketClient)}
+	server := httptest.NewServer(http.HandlerFunc(r.HandleWebSocket))
+	defer server.Close()
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	ws, response, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err == nil {
+		_ = ws.Close()
+		t.Fatalf("Dial() upgraded after shutdown")
+	}
+	if response == nil || response.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Dial() response got = %v, want %d", response, http.StatusServiceUnavailable)
+	}
+	if pending, _ := r.WebSocketStatus(); len(pending) != 0 {
+		t.Errorf("%d WebSocket clients registered after shutdown", len(pending))
+	}
+}
+
+func TestREST_ShutdownDeadline(t *testing.T) {
+	started := make(chan struct{})
+	r := &REST{routes: NewRouteTable()}
+	_ = r.routes.ReplaceServiceRoutes(GatewayRouteOwner, []*RouteEntry{
+		{Method: "GET", Pattern: "/stuck", Handler: func(w http.ResponseWriter, req *http.Request) {
+			close(started)
+			<-req.Context().Done()
+		}},
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = r.Serve(listener) }()
+	go func() {
+		if response, err := http.Get(fmt.Sprintf("http://%s/stuck", listener.Addr().String())); err == nil {
+			_ = response.Body.Close()
+		}
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := r.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestREST_JWTMiddleware_AuthPolicy(t *testing.T) {
+	r := &REST{}
+	r.routes = NewRouteTable(r.JWTMiddleware())
+	_ = r.routes.ReplaceServiceRoutes(GatewayRouteOwner, []*RouteEntry{
+		{Method: "GET", Pattern: "/status", Auth: AuthPolicyNone, Handler: routeHandler(http.StatusOK)},
+	})
+	_ = r.routes.ReplaceServiceRoutes("user", []*RouteEntry{
+		{Method: "GET", Pattern: "/statusanything", Handler: routeHandler(http.StatusOK)},
+		{Method: "POST", Pattern: "/user/login", Endpoint: &proto.RestEndpoint{SkipAuthMiddleware: true}, Handler: routeHandler(http.StatusOK)},
+		{Method: "GET", Pattern: "/user/login", Handler: routeHandler(http.StatusOK)},
+		{Method: "GET", Pattern: "/user/{id}", Endpoint: &proto.RestEndpoint{SkipAuthMiddleware: true, Roles: []string{"admin"}}, Handler: routeHandler(http.StatusOK)},
+	})
+	_ = r.routes.ReplaceServiceRoutes("admin", []*RouteEntry{
+		{Method: "POST", Pattern: "/admin/login", Handler: routeHandler(http.StatusOK)},
+	})
+	_ = r.routes.ReplaceServiceRoutes("svc", []*RouteEntry{
+		{Method: "GET", Pattern: "/svc/{id}", Handler: routeHandler(http.StatusOK)},
+		{Method: "GET", Pattern: "/svc/a/b", Auth: AuthPolicyNone, Handler: routeHandler(http.StatusAccepted)},
+	})
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{"GET", "/status", http.StatusOK},
+		{"GET", "/statusanything", http.StatusUnauthorized},
+		{"GET", "/status/", http.StatusUnauthorized},
+		{"POST", "/user/login", http.StatusOK},
+		{"GET", "/user/login", http.StatusUnauthorized},
+		{"POST", "/admin/login", http.StatusUnauthorized},
+		{"GET", "/user/1", http.StatusUnauthorized},
+		{"GET", "/unknown", http.StatusUnauthorized},
+		{"GET", "/svc/a/b", http.StatusAccepted},
+		// Encoded slash is routed to /svc/{id} by chi, so policy of /svc/a/b must not apply
+		{"GET", "/svc/a%2Fb", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.routes.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.want {
+			t.Errorf("%s %s got = %d, want %d", tt.method, tt.path, w.Code, tt.want)
+		}
+	}
+
+	want := map[string]string{"/status": AuthPolicyNone, "/statusanything": AuthPolicyRequired, "/user/{id}": AuthPolicyRequired}
+	for _, status := range r.RouteStatus() {
+		if policy, ok := want[status.Pattern]; ok && status.Auth != policy {
+			t.Errorf("RouteStatus() auth of %s got = %s, want %s", status.Pattern, status.Auth, policy)
+		}
+	}
+}
+
+func TestREST_httpRequestToProto_Body(t *testing.T) {
+	binary := []byte{0x89, 'P', 'N', 'G', 0xff, 0xfe, 0x00}
+	tests := []struct {
+		name     string
+		body     []byte
+		wantBody string
+	}{
+		{"Binary", binary, ""},
+		{"Text", []byte(`{"name":"ogb"}`), `{"name":"ogb"}`},
+		{"Empty", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/upload", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/octet-stream")
+			request, err := (&REST{}).httpRequestToProto(req)
+			if err != nil {
+				t.Fatalf("httpRequestToProto() error = %v", err)
+			}
+			if !bytes.Equal(request.RawBody, tt.body) {
+				t.Errorf("httpRequestToProto() RawBody got = %v, want %v", request.RawBody, tt.body)
+			}
+			if request.Body != tt.wantBody {
+				t.Errorf("httpRequestToProto() Body got = %q, want %q", request.Body, tt.wantBody)
+			}
+			if _, err := protobuf.Marshal(request); err != nil {
+				t.Errorf("request can't be sent to the service: %v", err)
+			}
+		})
+	}
+}
+
+func TestWriteProxyResponse_Body(t *testing.T) {
+	binary := []byte{0x89, 'P', 'N', 'G', 0xff, 0xfe, 0x00}
+	tests := []struct {
+		name     string
+		response *proto.RestApiResponse
+		want     []byte
+	}{
+		{"Raw body", &proto.RestApiResponse{HttpCode: 200, ContentType: "image/png", RawBody: binary}, binary},
+		{"Raw body over body", &proto.RestApiResponse{HttpCode: 200, Body: "text", RawBody: binary}, binary},
+		{"Legacy body", &proto.RestApiResponse{HttpCode: 200, Body: "text"}, []byte("text")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeProxyResponse(w, httptest.NewRequest("GET", "/image", nil), tt.response)
+			if !bytes.Equal(w.Body.Bytes(), tt.want) {
+				t.Errorf("writeProxyResponse() body got = %v, want %v", w.Body.Bytes(), tt.want)
+			}
+		})
+	}
+}
+
+func TestREST_httpRequestHeadToProto(t *testing.T) {
+	r := &REST{routes: NewRouteTable()}
+	var request *proto.RestApiRequest
+	handler := func(w http.ResponseWriter, req *http.Request) {
+		request = r.httpRequestHeadToProto(req)
+	}
+	_ = r.routes.ReplaceServiceRoutes("user", []*RouteEntry{
+		{Method: "GET", Pattern: "/user/{id}", Handler: handler},
+		{Method: "GET", Pattern: "/user/{id}/items/{item}", Handler: handler},
+	})
+	tests := []struct {
+		name       string
+		target     string
+		wantPath   string
+		wantQuery  string
+		wantA      []string
+		wantParams map[string]string
+	}{
+		{"Repeated query values", "/user/42?a=1&a=2", "/user/42", "a=1&a=2", []string{"1", "2"}, map[string]string{"id": "42"}},
+		{"Escaped path", "/user/john%20doe", "/user/john doe", "", nil, map[string]string{"id": "john doe"}},
+		{"Escaped slash", "/user/a%2Fb", "/user/a/b", "", nil, map[string]string{"id": "a/b"}},
+		{"Escaped slash and space", "/user/a%2Fb%20c/items/sword", "/user/a/b c/items/sword", "", nil, map[string]string{"id": "a/b c", "item": "sword"}},
+		{"Several params", "/user/42/items/sword?a=3", "/user/42/items/sword", "a=3", []string{"3"}, map[string]string{"id": "42", "item": "sword"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request = nil
+			r.routes.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tt.target, nil))
+			if request == nil {
+				t.Fatalf("%s didn't reach the handler", tt.target)
+			}
+			if request.Path != tt.wantPath || request.RawQuery != tt.wantQuery {
+				t.Errorf("httpRequestHeadToProto() got path = %s, query = %s, want %s, %s", request.Path, request.RawQuery, tt.wantPath, tt.wantQuery)
+			}
+			var a []string
+			for _, query := range request.Query {
+				if query.Key == "a" {
+					a = query.Value
+				}
+			}
+			if fmt.Sprint(a) != fmt.Sprint(tt.wantA) {
+				t.Errorf("httpRequestHeadToProto() query a got = %v, want %v", a, tt.wantA)
+			}
+			params := make(map[string]string)
+			for _, param := range request.PathParams {
+				params[param.Key] = param.Value
+			}
+			if fmt.Sprint(params) != fmt.Sprint(tt.wantParams) {
+				t.Errorf("httpRequestHeadToProto() path params got = %v, want %v", params, tt.wantParams)
+			}
+		})
+	}
+}
+
+func TestWriteResponseHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		preset   http.Header // Written by the gateway before the response
+		headers  []*proto.RestHeader
+		key      string
+		want     []string
+		wantType string
+	}{
+		{"Repeated values", nil, []*proto.RestHeader{{Key: "Set-Cookie", Value: "a=1", Values: []string{"a=1", "b=2"}}}, "Set-Cookie", []string{"a=1", "b=2"}, ""},
+		{"Repeated legacy headers", nil, []*proto.RestHeader{{Key: "Vary", Value: "Accept"}, {Key: "vary", Value: "Accept-Encoding"}}, "Vary", []string{"Accept", "Accept-Encoding"}, ""},
+		{"Legacy value", nil, []*proto.RestHeader{{Key: "X-Request-Id", Value: "42"}}, "X-Request-Id", []string{"42"}, ""},
+		{"Replaces gateway header", http.Header{"Vary": {"Origin"}}, []*proto.RestHeader{{Key: "Vary", Values: []string{"Accept", "Cookie"}}}, "Vary", []string{"Accept", "Cookie"}, ""},
+		{"Content type field", nil, []*proto.RestHeader{{Key: "Vary", Value: "Accept"}}, "Vary", []string{"Accept"}, "image/png"},
+		{"Content type header wins", nil, []*proto.RestHeader{{Key: "Content-Type", Value: "text/plain"}}, "Content-Type", []string{"text/plain"}, "text/plain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			for key, values := range tt.preset {
+				w.Header()[key] = values
+			}
+			writeResponseHeaders(w, &proto.RestApiResponse{Headers: tt.headers, ContentType: "image/png"})
+			if got := w.Header().Values(tt.key); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("writeResponseHeaders() %s got = %v, want %v", tt.key, got, tt.want)
+			}
+			if tt.wantType != "" && w.Header().Get("Content-Type") != tt.wantType {
+				t.Errorf("writeResponseHeaders() Content-Type got = %s, want %s", w.Header().Get("Content-Type"), tt.wantType)
+			}
+		})
+	}
+}
+
+func TestREST_httpRequestHeadToProto_Headers(t *testing.T) {
+	req := httptest.NewRequest("GET", "/user/42", nil)
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Accept", "text/plain")
+	request := (&REST{}).httpRequestHeadToProto(req)
+	for _, header := range request.Headers {
+		if header.Key != "Accept" {
+			continue
+		}
+		if header.Value != "application/json" || fmt.Sprint(header.Values) != "[application/json text/plain]" {
+			t.Errorf("httpRequestHeadToProto() Accept got = %s, %v", header.Value, header.Values)
+		}
+		return
+	}
+	t.Errorf("httpRequestHeadToProto() didn't forward Accept")
+}
+
+// blockingBackend never responds before the request context is done
+type blockingBackend struct {
+	testBackend
+	timeout time.Duration
+	started chan struct{}
+}
+
+func (b *blockingBackend) RequestContext(parent context.Context, endpoint *proto.RestEndpoint) (context.Context, context.CancelFunc) {
+	return requestContext(parent, endpoint, b.timeout)
+}
+
+func (b *blockingBackend) HandleRestRequest(ctx context.Context, endpoint *proto.RestEndpoint, request *proto.RestApiRequest) (*proto.RestApiResponse, error) {
+	close(b.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestREST_proxyHandlerTimeout(t *testing.T) {
+	tests := []struct {
+		name           string
+		serviceTimeout time.Duration
+		endpoint       *proto.RestEndpoint
+		cancel         bool // Client closes the request while the service is handling it
+		wantCode       int
+	}{
+		{"Service timeout", 20 * time.Millisecond, &proto.RestEndpoint{Method: "GET", Path: "/"}, false, http.StatusGatewayTimeout},
+		{"Endpoint timeout", time.Minute, &proto.RestEndpoint{Method: "GET", Path: "/", TimeoutMs: 20}, false, http.StatusGatewayTimeout},
+		{"Client closed request", time.Minute, &proto.RestEndpoint{Method: "GET", Path: "/"}, true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &REST{kafka: new(kafka.Publisher)}
+			backend := &blockingBackend{timeout: tt.serviceTimeout, started: make(chan struct{})}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				go func() {
+					<-backend.started
+					cancel()
+				}()
+			}
+
+			w := httptest.NewRecorder()
+			done := make(chan struct{})
+			go func() {
+				r.proxyHandler(tt.endpoint, backend)(w, httptest.NewRequest("GET", "/test", nil).WithContext(ctx))
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("proxyHandler() didn't return")
+			}
+			if w.Code != tt.wantCode {
+				t.Errorf("proxyHandler() code got = %d, want %d", w.Code, tt.wantCode)
+			}
+			if tt.cancel && w.Body.Len() != 0 {
+				t.Errorf("proxyHandler() wrote %s to closed request", w.Body.String())
+			}
+		})
+	}
+}
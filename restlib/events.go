@@ -0,0 +1,92 @@
+package restlib
+
+import (
+	"fmt"
+	restproto "github.com/savageking-io/ogbrest/proto"
+	log "github.com/sirupsen/logrus"
+)
+
+// eventsBufferSize is the number of events queued for each ogbrest instance. Events that don't fit are dropped
+const eventsBufferSize = 64
+
+// GatewayEvents is called by every connected ogbrest instance to receive events of the service
+func (s *RestInterServiceServer) GatewayEvents(in *restproto.GatewayEventsRequest, stream restproto.RestInterService_GatewayEventsServer) error {
+	log.Traceln("RestLib::GatewayEvents")
+	if !s.isAuthenticated {
+		return fmt.Errorf("not authenticated")
+	}
+
+	events := make(chan *restproto.GatewayEvent, eventsBufferSize)
+	s.eventsMutex.Lock()
+	if s.subscribers == nil {
+		s.subscribers = make(map[chan *restproto.GatewayEvent]bool)
+	}
+	s.subscribers[events] = true
+	s.eventsMutex.Unlock()
+	defer func() {
+		s.eventsMutex.Lock()
+		delete(s.subscribers, events)
+		s.eventsMutex.Unlock()
+	}()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event := <-events:
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// PurgeCache removes responses cached by ogbrest for the given paths, regardless of query string and user.
+// Paths are relative to the service root, e.g. /news/42
+func (s *RestInterServiceServer) PurgeCache(paths ...string) {
+	s.publishEvent(&restproto.GatewayEvent{Payload: &restproto.GatewayEvent_CachePurge{
+		CachePurge: &restproto.CachePurge{Paths: paths},
+	}})
+}
+
+// PurgeCachePrefix removes responses cached by ogbrest for all paths starting with any of the prefixes
+func (s *RestInterServiceServer) PurgeCachePrefix(prefixes ...string) {
+	s.publishEvent(&restproto.GatewayEvent{Payload: &restproto.GatewayEvent_CachePurge{
+		CachePurge: &restproto.CachePurge{Prefixes: prefixes},
+	}})
+}
+
+// PurgeAllCache removes all responses of the service cached by ogbrest
+func (s *RestInterServiceServer) PurgeAllCache() {
+	s.publishEvent(&restproto.GatewayEvent{Payload: &restproto.GatewayEvent_CachePurge{
+		CachePurge: &restproto.CachePurge{All: true},
+	}})
+}
+
+// RevokeUserTokens removes results of token validation cached by ogbrest for the users, e.g. when they
+// log out or are banned. Intended for the user service
+func (s *RestInterServiceServer) RevokeUserTokens(userIds ...int32) {
+	s.publishEvent(&restproto.GatewayEvent{Payload: &restproto.GatewayEvent_TokenRevocation{
+		TokenRevocation: &restproto.TokenRevocation{UserIds: userIds},
+	}})
+}
+
+// RevokeTokens removes results of validation cached by ogbrest for the given tokens
+func (s *RestInterServiceServer) RevokeTokens(tokens ...string) {
+	s.publishEvent(&restproto.GatewayEvent{Payload: &restproto.GatewayEvent_TokenRevocation{
+		TokenRevocation: &restproto.TokenRevocation{Tokens: tokens},
+	}})
+}
+
+// publishEvent sends the event to every connected ogbrest instance
+func (s *RestInterServiceServer) publishEvent(event *restproto.GatewayEvent) {
+	s.eventsMutex.Lock()
+	defer s.eventsMutex.Unlock()
+	for events := range s.subscribers {
+		select {
+		case events <- event:
+		default:
+			log.Warnf("Gateway events queue is full, event dropped")
+		}
+	}
+}
@@ -3,9 +3,10 @@ module github.com/savageking-io/ogbrest/restlib
 go 1.23.4
 
 require (
-	github.com/savageking-io/ogbrest/proto v0.8.0
+	github.com/savageking-io/ogbrest/proto v0.9.0
 	github.com/sirupsen/logrus v1.9.3
 	google.golang.org/grpc v1.73.0
+	google.golang.org/protobuf v1.36.6
 )
 
 require (
@@ -13,5 +14,4 @@ require (
 	golang.org/x/sys v0.31.0 // indirect
 	golang.org/x/text v0.23.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250324211829-b45e905df463 // indirect
-	google.golang.org/protobuf v1.36.6 // indirect
 )
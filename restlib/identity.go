@@ -0,0 +1,48 @@
+package restlib
+
+import restproto "github.com/savageking-io/ogbrest/proto"
+
+// Identity of the caller authenticated by ogbrest. Requests can reach the service only through an
+// authenticated ogbrest connection, so services can trust it without validating the token again
+type Identity struct {
+	UserId     int32
+	Claims     map[string][]string // Claims of the player token, or owner, roles and scopes of the API key
+	AuthMethod string              // AuthMethodJWT for players, AuthMethodAPIKey for servers and tools
+	APIKey     string              // Name of the API key. Empty for players
+}
+
+const (
+	AuthMethodJWT    = "jwt"
+	AuthMethodAPIKey = "api_key"
+)
+
+// GetIdentity returns identity of the caller. ok is false when request was not authenticated,
+// e.g. for endpoints with SkipAuthMiddleware
+func GetIdentity(in *restproto.RestApiRequest) (*Identity, bool) {
+	if in == nil || in.Identity == nil {
+		return nil, false
+	}
+	identity := &Identity{
+		UserId:     in.Identity.UserId,
+		Claims:     make(map[string][]string),
+		AuthMethod: in.Identity.AuthMethod,
+		APIKey:     in.Identity.ApiKey,
+	}
+	for _, claim := range in.Identity.Claims {
+		identity.Claims[claim.Key] = claim.Values
+	}
+	return identity, true
+}
+
+// Claim returns first value of the claim or empty string if it's not present
+func (i *Identity) Claim(key string) string {
+	if values := i.Claims[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// IsAPIKey returns true when the request came from a server or a tool authenticated with API key
+func (i *Identity) IsAPIKey() bool {
+	return i.AuthMethod == AuthMethodAPIKey
+}
@@ -0,0 +1,39 @@
+package restlib
+
+import (
+	restproto "github.com/savageking-io/ogbrest/proto"
+	"testing"
+)
+
+func TestGetIdentity(t *testing.T) {
+	tests := []struct {
+		name       string
+		in         *restproto.RestApiRequest
+		wantOk     bool
+		wantUserId int32
+		wantRole   string
+		wantAPIKey bool
+	}{
+		{"Nil request", nil, false, 0, "", false},
+		{"Not authenticated", &restproto.RestApiRequest{}, false, 0, "", false},
+		{"Player", &restproto.RestApiRequest{Identity: &restproto.RestIdentity{UserId: 7, AuthMethod: AuthMethodJWT, Claims: []*restproto.RestClaim{{Key: "roles", Values: []string{"player", "moderator"}}}}}, true, 7, "player", false},
+		{"API key", &restproto.RestApiRequest{Identity: &restproto.RestIdentity{AuthMethod: AuthMethodAPIKey, ApiKey: "matchmaking"}}, true, 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, ok := GetIdentity(tt.in)
+			if ok != tt.wantOk {
+				t.Fatalf("GetIdentity() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if !ok {
+				return
+			}
+			if identity.UserId != tt.wantUserId || identity.Claim("roles") != tt.wantRole || identity.IsAPIKey() != tt.wantAPIKey {
+				t.Errorf("GetIdentity() got = %+v", identity)
+			}
+			if tt.wantAPIKey && identity.APIKey != "matchmaking" {
+				t.Errorf("GetIdentity() APIKey got = %s, want matchmaking", identity.APIKey)
+			}
+		})
+	}
+}
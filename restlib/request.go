@@ -0,0 +1,111 @@
+package restlib
+
+import (
+	restproto "github.com/savageking-io/ogbrest/proto"
+	"strings"
+)
+
+// RequestBody returns request body as bytes. Unlike in.Body it's filled for binary payloads as well
+func RequestBody(in *restproto.RestApiRequest) []byte {
+	if in == nil {
+		return nil
+	}
+	if len(in.RawBody) > 0 {
+		return in.RawBody
+	}
+	return []byte(in.Body)
+}
+
+// NewBinaryResponse creates response with a body of any content type, e.g. protobuf, msgpack or images
+func NewBinaryResponse(httpCode int32, contentType string, body []byte) *restproto.RestApiResponse {
+	return &restproto.RestApiResponse{
+		HttpCode:    httpCode,
+		ContentType: contentType,
+		RawBody:     body,
+	}
+}
+
+// PathParam returns value of {key} placeholder from the endpoint path, e.g. id for /user/{id}
+func PathParam(in *restproto.RestApiRequest, key string) string {
+	if in == nil {
+		return ""
+	}
+	for _, param := range in.PathParams {
+		if param.Key == key {
+			return param.Value
+		}
+	}
+	return ""
+}
+
+// QueryValues returns all values of query string parameter
+func QueryValues(in *restproto.RestApiRequest, key string) []string {
+	if in == nil {
+		return nil
+	}
+	for _, param := range in.Query {
+		if param.Key == key {
+			return param.Value
+		}
+	}
+	return nil
+}
+
+// QueryParam returns first value of query string parameter
+func QueryParam(in *restproto.RestApiRequest, key string) string {
+	values := QueryValues(in, key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// HeaderValues returns all values of the request header. Header name is case-insensitive
+func HeaderValues(in *restproto.RestApiRequest, key string) []string {
+	if in == nil {
+		return nil
+	}
+	var result []string
+	for _, header := range in.Headers {
+		if !strings.EqualFold(header.Key, key) {
+			continue
+		}
+		if len(header.Values) > 0 {
+			result = append(result, header.Values...)
+		} else {
+			result = append(result, header.Value)
+		}
+	}
+	return result
+}
+
+// Header returns first value of the request header
+func Header(in *restproto.RestApiRequest, key string) string {
+	values := HeaderValues(in, key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// AddHeader adds value to the response header, keeping values added before.
+// Use it for headers that can be repeated, like Set-Cookie or Vary
+func AddHeader(out *restproto.RestApiResponse, key, value string) {
+	if out == nil {
+		return
+	}
+	for _, header := range out.Headers {
+		if strings.EqualFold(header.Key, key) {
+			if len(header.Values) == 0 && header.Value != "" {
+				header.Values = []string{header.Value}
+			}
+			header.Values = append(header.Values, value)
+			return
+		}
+	}
+	out.Headers = append(out.Headers, &restproto.RestHeader{
+		Key:    key,
+		Value:  value,
+		Values: []string{value},
+	})
+}
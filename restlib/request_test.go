@@ -0,0 +1,151 @@
+package restlib
+
+import (
+	"bytes"
+	"context"
+	restproto "github.com/savageking-io/ogbrest/proto"
+	"slices"
+	"testing"
+)
+
+func TestRequestBody(t *testing.T) {
+	binary := []byte{0x89, 'P', 'N', 'G', 0xff, 0xfe, 0x00}
+	tests := []struct {
+		name string
+		in   *restproto.RestApiRequest
+		want []byte
+	}{
+		{"Raw body", &restproto.RestApiRequest{RawBody: binary}, binary},
+		{"Legacy body", &restproto.RestApiRequest{Body: "text"}, []byte("text")},
+		{"Nil request", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RequestBody(tt.in); !bytes.Equal(got, tt.want) {
+				t.Errorf("RequestBody() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestInterServiceServer_NewRestRequest_Body(t *testing.T) {
+	binary := []byte{0x89, 'P', 'N', 'G', 0xff, 0xfe, 0x00}
+	tests := []struct {
+		name string
+		in   *restproto.RestApiRequest
+		want []byte
+	}{
+		{"Raw body", &restproto.RestApiRequest{Method: "POST", Uri: "/upload", RawBody: binary}, binary},
+		{"Legacy ogbrest sends only body", &restproto.RestApiRequest{Method: "POST", Uri: "/upload", Body: "text"}, []byte("text")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewRestInterServiceServer(RestInterServiceConfig{Token: "token"})
+			if err := s.Init(); err != nil {
+				t.Fatal(err)
+			}
+			s.isAuthenticated = true
+			var got []byte
+			_ = s.RegisterHandler("/upload", "POST", func(ctx context.Context, in *restproto.RestApiRequest) (*restproto.RestApiResponse, error) {
+				got = in.RawBody
+				return NewBinaryResponse(200, "image/png", in.RawBody), nil
+			}, false)
+			response, err := s.NewRestRequest(context.Background(), tt.in)
+			if err != nil {
+				t.Fatalf("NewRestRequest() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.want) || !bytes.Equal(response.RawBody, tt.want) {
+				t.Errorf("handler got RawBody = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPathParam_QueryParam(t *testing.T) {
+	// Request of GET /user/42?a=1&a=2 to endpoint /user/{id}, as ogbrest sends it
+	in := &restproto.RestApiRequest{
+		Method:     "GET",
+		Uri:        "/user/{id}",
+		Path:       "/user/42",
+		RawQuery:   "a=1&a=2",
+		Query:      []*restproto.RestApiFormData{{Key: "a", Value: []string{"1", "2"}}},
+		PathParams: []*restproto.RestPathParam{{Key: "id", Value: "42"}},
+	}
+	tests := []struct {
+		name       string
+		in         *restproto.RestApiRequest
+		key        string
+		wantPath   string
+		wantQuery  string
+		wantValues []string
+	}{
+		{"Path param", in, "id", "42", "", nil},
+		{"Repeated query values", in, "a", "", "1", []string{"1", "2"}},
+		{"Missing", in, "b", "", "", nil},
+		{"Nil request", nil, "id", "", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PathParam(tt.in, tt.key); got != tt.wantPath {
+				t.Errorf("PathParam() got = %s, want %s", got, tt.wantPath)
+			}
+			if got := QueryParam(tt.in, tt.key); got != tt.wantQuery {
+				t.Errorf("QueryParam() got = %s, want %s", got, tt.wantQuery)
+			}
+			if got := QueryValues(tt.in, tt.key); !slices.Equal(got, tt.wantValues) {
+				t.Errorf("QueryValues() got = %v, want %v", got, tt.wantValues)
+			}
+		})
+	}
+}
+
+func TestHeaderValues(t *testing.T) {
+	in := &restproto.RestApiRequest{Headers: []*restproto.RestHeader{
+		{Key: "Accept", Value: "application/json", Values: []string{"application/json", "text/plain"}},
+		{Key: "X-Legacy", Value: "1"},
+		{Key: "x-legacy", Value: "2"},
+	}}
+	tests := []struct {
+		name string
+		in   *restproto.RestApiRequest
+		key  string
+		want []string
+	}{
+		{"Repeated values", in, "accept", []string{"application/json", "text/plain"}},
+		{"Legacy headers with value only", in, "X-Legacy", []string{"1", "2"}},
+		{"Missing", in, "Cookie", nil},
+		{"Nil request", nil, "Accept", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HeaderValues(tt.in, tt.key); !slices.Equal(got, tt.want) {
+				t.Errorf("HeaderValues() got = %v, want %v", got, tt.want)
+			}
+			want := ""
+			if len(tt.want) > 0 {
+				want = tt.want[0]
+			}
+			if got := Header(tt.in, tt.key); got != want {
+				t.Errorf("Header() got = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestAddHeader(t *testing.T) {
+	out := &restproto.RestApiResponse{Headers: []*restproto.RestHeader{{Key: "Vary", Value: "Accept"}}}
+	AddHeader(out, "Set-Cookie", "a=1")
+	AddHeader(out, "set-cookie", "b=2")
+	AddHeader(out, "Vary", "Cookie")
+	AddHeader(nil, "Vary", "Cookie")
+
+	want := map[string][]string{"Set-Cookie": {"a=1", "b=2"}, "Vary": {"Accept", "Cookie"}}
+	if len(out.Headers) != len(want) {
+		t.Fatalf("AddHeader() got %d headers, want %d", len(out.Headers), len(want))
+	}
+	for _, header := range out.Headers {
+		if !slices.Equal(header.Values, want[header.Key]) || header.Value != want[header.Key][0] {
+			t.Errorf("AddHeader() %s got = %s, %v, want %v", header.Key, header.Value, header.Values, want[header.Key])
+		}
+	}
+}
@@ -10,7 +10,10 @@ import (
 	restproto "github.com/savageking-io/ogbrest/proto"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"net"
+	"sync"
+	"time"
 )
 
 // RestRequestHandler is a callback function called for appropriate REST requests
@@ -27,9 +30,24 @@ type RestInterServiceConfig struct {
 
 // RestInterServiceEndpoint defines REST API endpoint
 type RestInterServiceEndpoint struct {
-	Path               string `yaml:"path"`                 // Path will be appended to RestInterServiceConfig.Root
-	Method             string `yaml:"method"`               // Method can be GET, POST, DELETE, PUT, UPDATE or any other valid method
-	SkipAuthMiddleware bool   `yaml:"skip_auth_middleware"` // SkipAuthMiddleware will not check user's Auth token for this endpoint
+	Path               string        `yaml:"path"`                 // Path will be appended to RestInterServiceConfig.Root
+	Method             string        `yaml:"method"`               // Method can be GET, POST, DELETE, PUT, UPDATE or any other valid method
+	SkipAuthMiddleware bool          `yaml:"skip_auth_middleware"` // SkipAuthMiddleware will not check user's Auth token for this endpoint
+	Stream             bool          `yaml:"stream"`               // Stream endpoints receive and send body in chunks. Set automatically for handlers registered with RegisterStreamHandler
+	Timeout            time.Duration `yaml:"timeout"`              // Timeout overrides ogbrest request timeout for this endpoint
+	Idempotent         bool          `yaml:"idempotent"`           // Idempotent endpoints may be retried by ogbrest even for POST requests
+	RateLimit          *RateLimit    `yaml:"rate_limit"`           // RateLimit limits requests of every client to this endpoint. ogbrest configuration may override it
+	MaxBodyBytes       int64         `yaml:"max_body_bytes"`       // MaxBodyBytes overrides ogbrest request body limit for this endpoint. Unlimited when negative
+	Roles              []string      `yaml:"roles"`                // Roles lets through users with at least one of the roles. Others get 403
+	Scopes             []string      `yaml:"scopes"`               // Scopes lets through tokens with all the scopes. Others get 403
+}
+
+// RateLimit allows Requests per Period to every client, up to Burst at once
+type RateLimit struct {
+	Requests int           `yaml:"requests"`
+	Period   time.Duration `yaml:"period"` // Default is 1s
+	Burst    int           `yaml:"burst"`  // Defaults to Requests
+	Key      string        `yaml:"key"`    // What clients are told apart by: user, ip or user_ip. Defaults to ogbrest configuration
 }
 
 // RestInterServiceServer
@@ -38,7 +56,11 @@ type RestInterServiceServer struct {
 	config          RestInterServiceConfig
 	isAuthenticated bool
 	handlers        map[string]RestRequestHandler
+	streamHandlers  map[string]RestStreamHandler
+	bodyLimits      map[string]int64 // Request body limits declared with SetHandlerBodyLimit
 	RequestChan     chan *restproto.RestApiRequest
+	subscribers     map[chan *restproto.GatewayEvent]bool // Gateway events of connected ogbrest instances
+	eventsMutex     sync.Mutex
 }
 
 // NewRestInterServiceServer will create new RestInterServiceServer with the provided confiration
@@ -62,6 +84,8 @@ func (s *RestInterServiceServer) Init() error {
 	log.Traceln("RestLib::Init")
 	s.RequestChan = make(chan *restproto.RestApiRequest, 100)
 	s.handlers = make(map[string]RestRequestHandler)
+	s.streamHandlers = make(map[string]RestStreamHandler)
+	s.bodyLimits = make(map[string]int64)
 	return nil
 }
 
@@ -87,7 +111,7 @@ func (s *RestInterServiceServer) RegisterHandler(uri, method string, handler Res
 		return fmt.Errorf("handlers are not initialized")
 	}
 	requestDefinition := fmt.Sprintf("%s:%s", method, uri)
-	if _, ok := s.handlers[requestDefinition]; ok {
+	if s.IsHandlerRegistered(uri, method) {
 		return fmt.Errorf("handler for %s already registered", requestDefinition)
 	}
 	s.handlers[requestDefinition] = handler
@@ -97,35 +121,72 @@ func (s *RestInterServiceServer) RegisterHandler(uri, method string, handler Res
 	return nil
 }
 
+// RegisterStreamHandler will add new URL to the rest service. Requests to this URL are proxied by ogbrest
+// as a stream, so the handler can process large request and response bodies without buffering them
+func (s *RestInterServiceServer) RegisterStreamHandler(uri, method string, handler RestStreamHandler) error {
+	log.Traceln("RestLib::RegisterStreamHandler")
+	if s.streamHandlers == nil {
+		return fmt.Errorf("handlers are not initialized")
+	}
+	requestDefinition := fmt.Sprintf("%s:%s", method, uri)
+	if s.IsHandlerRegistered(uri, method) {
+		return fmt.Errorf("handler for %s already registered", requestDefinition)
+	}
+	s.streamHandlers[requestDefinition] = handler
+	return nil
+}
+
+// SetHandlerBodyLimit declares request body limit of the registered handler. It overrides max_body_bytes
+// of the endpoint configuration. Negative limit lets ogbrest accept bodies of any size
+func (s *RestInterServiceServer) SetHandlerBodyLimit(uri, method string, limit int64) error {
+	log.Traceln("RestLib::SetHandlerBodyLimit")
+	if !s.IsHandlerRegistered(uri, method) {
+		return fmt.Errorf("handler for %s:%s is not registered", method, uri)
+	}
+	s.bodyLimits[fmt.Sprintf("%s:%s", method, uri)] = limit
+	return nil
+}
+
 func (s *RestInterServiceServer) IsHandlerRegistered(uri, method string) bool {
 	requestDefinition := fmt.Sprintf("%s:%s", method, uri)
-	_, ok := s.handlers[requestDefinition]
+	if _, ok := s.handlers[requestDefinition]; ok {
+		return true
+	}
+	_, ok := s.streamHandlers[requestDefinition]
 	return ok
 }
 
 func (s *RestInterServiceServer) UnregisterHandler(uri, method string) error {
 	log.Traceln("RestLib::UnregisterHandler")
 	requestDefinition := fmt.Sprintf("%s:%s", method, uri)
-	if _, ok := s.handlers[requestDefinition]; !ok {
+	if !s.IsHandlerRegistered(uri, method) {
 		return fmt.Errorf("handler for %s is not registered", requestDefinition)
 	}
 	delete(s.handlers, requestDefinition)
+	delete(s.streamHandlers, requestDefinition)
+	delete(s.bodyLimits, requestDefinition)
 	return nil
 }
 
 func (s *RestInterServiceServer) UnregisterAllHandlers() error {
 	log.Traceln("RestLib::UnregisterAllHandlers")
 	s.handlers = make(map[string]RestRequestHandler)
+	s.streamHandlers = make(map[string]RestStreamHandler)
+	s.bodyLimits = make(map[string]int64)
 	return nil
 }
 
 func (s *RestInterServiceServer) GetRegisteredHandlerKeys() []string {
-	keys := make([]string, len(s.handlers))
+	keys := make([]string, len(s.handlers)+len(s.streamHandlers))
 	i := 0
 	for k := range s.handlers {
 		keys[i] = k
 		i++
 	}
+	for k := range s.streamHandlers {
+		keys[i] = k
+		i++
+	}
 	return keys
 }
 
@@ -161,10 +222,29 @@ func (s *RestInterServiceServer) RequestRestData(ctx context.Context, in *restpr
 
 	endpoints := make([]*restproto.RestEndpoint, len(s.config.Endpoints))
 	for i, endpoint := range s.config.Endpoints {
+		requestDefinition := fmt.Sprintf("%s:%s", endpoint.Method, endpoint.Path)
+		_, isStream := s.streamHandlers[requestDefinition]
 		endpoints[i] = &restproto.RestEndpoint{
 			Path:               endpoint.Path,
 			Method:             endpoint.Method,
 			SkipAuthMiddleware: endpoint.SkipAuthMiddleware,
+			Stream:             endpoint.Stream || isStream,
+			TimeoutMs:          int32(endpoint.Timeout.Milliseconds()),
+			Idempotent:         endpoint.Idempotent,
+			MaxBodyBytes:       endpoint.MaxBodyBytes,
+			Roles:              endpoint.Roles,
+			Scopes:             endpoint.Scopes,
+		}
+		if limit, ok := s.bodyLimits[requestDefinition]; ok {
+			endpoints[i].MaxBodyBytes = limit
+		}
+		if endpoint.RateLimit != nil {
+			endpoints[i].RateLimit = &restproto.RestRateLimit{
+				Requests: int32(endpoint.RateLimit.Requests),
+				PeriodMs: int32(endpoint.RateLimit.Period.Milliseconds()),
+				Burst:    int32(endpoint.RateLimit.Burst),
+				Key:      endpoint.RateLimit.Key,
+			}
 		}
 	}
 
@@ -192,5 +272,53 @@ func (s *RestInterServiceServer) NewRestRequest(ctx context.Context, in *restpro
 			Code: 404,
 		}, fmt.Errorf("handler for %s is not registered", requestDefinition)
 	}
+	// Older ogbrest versions send body only as a string
+	if len(in.RawBody) == 0 && in.Body != "" {
+		in.RawBody = []byte(in.Body)
+	}
 	return handler(ctx, in)
 }
+
+func (s *RestInterServiceServer) NewRestStreamRequest(stream restproto.RestInterService_NewRestStreamRequestServer) error {
+	log.Traceln("RestLib::NewRestStreamRequest")
+	if s.config.Token == "" {
+		return fmt.Errorf("token is not set")
+	}
+	if !s.isAuthenticated {
+		return fmt.Errorf("not authenticated")
+	}
+
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	in := first.GetHead()
+	if in == nil {
+		return fmt.Errorf("stream must start with request head")
+	}
+
+	requestDefinition := fmt.Sprintf("%s:%s", in.Method, in.Uri)
+	handler, ok := s.streamHandlers[requestDefinition]
+	if !ok {
+		return fmt.Errorf("stream handler for %s is not registered", requestDefinition)
+	}
+
+	w := &RestStreamWriter{stream: stream}
+	if err := handler(stream.Context(), in, &restStreamReader{stream: stream}, w); err != nil {
+		return err
+	}
+	if !w.headSent {
+		// Handler didn't write anything - ogbrest still needs the head to complete the request
+		return w.WriteHead(&restproto.RestApiResponse{HttpCode: 200})
+	}
+	return nil
+}
+
+// Ping is called by ogbrest to check health of the service
+func (s *RestInterServiceServer) Ping(ctx context.Context, in *restproto.PingMessage) (*restproto.PingMessage, error) {
+	log.Traceln("RestLib::Ping")
+	return &restproto.PingMessage{
+		SentAt:    in.SentAt,
+		RepliedAt: timestamppb.Now(),
+	}, nil
+}
@@ -0,0 +1,81 @@
+package restlib
+
+import (
+	"context"
+	"fmt"
+	restproto "github.com/savageking-io/ogbrest/proto"
+	"io"
+)
+
+// StreamChunkSize is the maximum size of a body chunk sent back to ogbrest
+const StreamChunkSize = 32 * 1024
+
+// RestStreamHandler is a callback function called for REST requests on stream endpoints.
+// Request body is read from body as it's being uploaded. Response is sent through w
+type RestStreamHandler func(ctx context.Context, in *restproto.RestApiRequest, body io.Reader, w *RestStreamWriter) error
+
+// RestStreamWriter sends response of a stream endpoint back to ogbrest.
+// Response head must be sent before any body, otherwise 200 with no headers will be sent on the first Write
+type RestStreamWriter struct {
+	stream   restproto.RestInterService_NewRestStreamRequestServer
+	headSent bool
+}
+
+// WriteHead sends response head: http code and headers. It can be called only once
+func (w *RestStreamWriter) WriteHead(head *restproto.RestApiResponse) error {
+	if w.headSent {
+		return fmt.Errorf("response head already sent")
+	}
+	if head == nil {
+		return fmt.Errorf("response head is nil")
+	}
+	w.headSent = true
+	return w.stream.Send(&restproto.RestStreamResponse{Payload: &restproto.RestStreamResponse_Head{Head: head}})
+}
+
+// Write sends p as one or more body chunks. Chunks are copied, as gRPC may still use a message after Send returns
+func (w *RestStreamWriter) Write(p []byte) (int, error) {
+	if !w.headSent {
+		if err := w.WriteHead(&restproto.RestApiResponse{HttpCode: 200}); err != nil {
+			return 0, err
+		}
+	}
+	written := 0
+	for written < len(p) {
+		end := written + StreamChunkSize
+		if end > len(p) {
+			end = len(p)
+		}
+		chunk := make([]byte, end-written)
+		copy(chunk, p[written:end])
+		if err := w.stream.Send(&restproto.RestStreamResponse{Payload: &restproto.RestStreamResponse_Chunk{Chunk: chunk}}); err != nil {
+			return written, err
+		}
+		written = end
+	}
+	return written, nil
+}
+
+// restStreamReader reads request body chunks from the stream. io.EOF is returned once ogbrest closes sending side
+type restStreamReader struct {
+	stream restproto.RestInterService_NewRestStreamRequestServer
+	buf    []byte
+	err    error
+}
+
+func (r *restStreamReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		msg, err := r.stream.Recv()
+		if err != nil {
+			r.err = err
+			continue
+		}
+		r.buf = msg.GetChunk()
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
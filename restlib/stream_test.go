@@ -0,0 +1,157 @@
+package restlib
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	restproto "github.com/savageking-io/ogbrest/proto"
+	"google.golang.org/grpc"
+	"io"
+	"testing"
+)
+
+// fakeServerStream is the service side of NewRestStreamRequest. Recv returns requests in order, then err or io.EOF
+type fakeServerStream struct {
+	grpc.ServerStream
+	requests []*restproto.RestStreamRequest
+	err      error
+	sent     []*restproto.RestStreamResponse
+}
+
+func (s *fakeServerStream) Context() context.Context { return context.Background() }
+
+func (s *fakeServerStream) Send(msg *restproto.RestStreamResponse) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func (s *fakeServerStream) Recv() (*restproto.RestStreamRequest, error) {
+	if len(s.requests) == 0 {
+		if s.err != nil {
+			return nil, s.err
+		}
+		return nil, io.EOF
+	}
+	msg := s.requests[0]
+	s.requests = s.requests[1:]
+	return msg, nil
+}
+
+func requestHead(method, uri string) *restproto.RestStreamRequest {
+	return &restproto.RestStreamRequest{Payload: &restproto.RestStreamRequest_Head{Head: &restproto.RestApiRequest{Method: method, Uri: uri}}}
+}
+
+func requestChunk(chunk string) *restproto.RestStreamRequest {
+	return &restproto.RestStreamRequest{Payload: &restproto.RestStreamRequest_Chunk{Chunk: []byte(chunk)}}
+}
+
+func newStreamServer(t *testing.T, handler RestStreamHandler) *RestInterServiceServer {
+	s := NewRestInterServiceServer(RestInterServiceConfig{Token: "token"})
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	s.isAuthenticated = true
+	if err := s.RegisterStreamHandler("/upload", "POST", handler); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestRestStreamWriter_Write(t *testing.T) {
+	stream := &fakeServerStream{}
+	w := &RestStreamWriter{stream: stream}
+	body := bytes.Repeat([]byte("b"), 2*StreamChunkSize+1)
+	if n, err := w.Write(body); err != nil || n != len(body) {
+		t.Fatalf("Write() got = %d, %v, want %d", n, err, len(body))
+	}
+	if len(stream.sent) != 4 || stream.sent[0].GetHead().GetHttpCode() != 200 {
+		t.Fatalf("Write() sent %d messages, want default head and 3 chunks", len(stream.sent))
+	}
+	var got []byte
+	for _, msg := range stream.sent[1:] {
+		got = append(got, msg.GetChunk()...)
+	}
+	if !bytes.Equal(got, body) || len(stream.sent[3].GetChunk()) != 1 {
+		t.Errorf("Write() chunks don't add up to the body")
+	}
+	if err := w.WriteHead(&restproto.RestApiResponse{HttpCode: 201}); err == nil {
+		t.Errorf("WriteHead() after body error is nil")
+	}
+}
+
+func TestRestStreamReader_Read(t *testing.T) {
+	failure := errors.New("ogbrest disconnected")
+	tests := []struct {
+		name    string
+		stream  *fakeServerStream
+		want    string
+		wantErr error
+	}{
+		{"Multiple chunks", &fakeServerStream{requests: []*restproto.RestStreamRequest{requestChunk("first,"), requestChunk(""), requestChunk("second")}}, "first,second", nil},
+		{"Error mid body", &fakeServerStream{requests: []*restproto.RestStreamRequest{requestChunk("first,")}, err: failure}, "first,", failure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := io.ReadAll(&restStreamReader{stream: tt.stream})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Read() error = %v, want %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Read() got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestInterServiceServer_NewRestStreamRequest(t *testing.T) {
+	echo := func(ctx context.Context, in *restproto.RestApiRequest, body io.Reader, w *RestStreamWriter) error {
+		if err := w.WriteHead(&restproto.RestApiResponse{HttpCode: 201}); err != nil {
+			return err
+		}
+		_, err := io.Copy(w, body)
+		return err
+	}
+	failure := errors.New("ogbrest disconnected")
+	tests := []struct {
+		name     string
+		handler  RestStreamHandler
+		requests []*restproto.RestStreamRequest
+		err      error
+		wantCode int32
+		wantBody string
+		wantErr  bool
+	}{
+		{"Echo", echo, []*restproto.RestStreamRequest{requestHead("POST", "/upload"), requestChunk("first,"), requestChunk("second")}, nil, 201, "first,second", false},
+		{"Handler without response", func(context.Context, *restproto.RestApiRequest, io.Reader, *RestStreamWriter) error { return nil }, []*restproto.RestStreamRequest{requestHead("POST", "/upload")}, nil, 200, "", false},
+		{"Closed before head", echo, nil, nil, 0, "", true},
+		{"Chunk before head", echo, []*restproto.RestStreamRequest{requestChunk("first")}, nil, 0, "", true},
+		{"Unknown handler", echo, []*restproto.RestStreamRequest{requestHead("GET", "/upload")}, nil, 0, "", true},
+		{"Error mid body", echo, []*restproto.RestStreamRequest{requestHead("POST", "/upload"), requestChunk("first,")}, failure, 201, "first,", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStreamServer(t, tt.handler)
+			stream := &fakeServerStream{requests: tt.requests, err: tt.err}
+			err := s.NewRestStreamRequest(stream)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewRestStreamRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantCode == 0 {
+				if len(stream.sent) != 0 {
+					t.Errorf("NewRestStreamRequest() sent %d messages, want none", len(stream.sent))
+				}
+				return
+			}
+			if len(stream.sent) == 0 || stream.sent[0].GetHead().GetHttpCode() != tt.wantCode {
+				t.Fatalf("NewRestStreamRequest() head got = %v, want code %d", stream.sent, tt.wantCode)
+			}
+			var body []byte
+			for _, msg := range stream.sent[1:] {
+				body = append(body, msg.GetChunk()...)
+			}
+			if string(body) != tt.wantBody {
+				t.Errorf("NewRestStreamRequest() body got = %s, want %s", body, tt.wantBody)
+			}
+		})
+	}
+}
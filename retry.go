@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/savageking-io/ogbrest/proto"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultRetryMaxAttempts    = 3
+	DefaultRetryInitialBackoff = 50 * time.Millisecond
+	DefaultRetryMaxBackoff     = time.Second
+	DefaultRetryBudgetRatio    = 0.2
+	DefaultRetryBudgetMin      = 10
+	retryBudgetWindow          = 10 * time.Second
+)
+
+// DefaultRetryMethods are retried when configuration doesn't list methods
+var DefaultRetryMethods = []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete}
+
+// RetryPolicy decides whether a failed request to the service is sent again and how long to wait before that
+type RetryPolicy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	methods        map[string]bool
+	budget         *RetryBudget
+}
+
+func NewRetryPolicy(config RetryConfig) *RetryPolicy {
+	p := &RetryPolicy{
+		maxAttempts:    config.MaxAttempts,
+		initialBackoff: config.InitialBackoff,
+		maxBackoff:     config.MaxBackoff,
+		methods:        make(map[string]bool),
+	}
+	if p.maxAttempts <= 0 {
+		p.maxAttempts = DefaultRetryMaxAttempts
+	}
+	if p.initialBackoff <= 0 {
+		p.initialBackoff = DefaultRetryInitialBackoff
+	}
+	if p.maxBackoff <= 0 {
+		p.maxBackoff = DefaultRetryMaxBackoff
+	}
+	methods := config.Methods
+	if len(methods) == 0 {
+		methods = DefaultRetryMethods
+	}
+	for _, method := range methods {
+		p.methods[strings.ToUpper(method)] = true
+	}
+	ratio := config.BudgetRatio
+	if ratio <= 0 {
+		ratio = DefaultRetryBudgetRatio
+	}
+	minRetries := config.BudgetMinRetries
+	if minRetries <= 0 {
+		minRetries = DefaultRetryBudgetMin
+	}
+	p.budget = NewRetryBudget(ratio, minRetries, retryBudgetWindow)
+	return p
+}
+
+// MaxAttempts returns number of attempts allowed for the request, including the first one
+func (p *RetryPolicy) MaxAttempts(endpoint *proto.RestEndpoint, request *proto.RestApiRequest) int {
+	if p == nil || p.maxAttempts <= 1 {
+		return 1
+	}
+	if endpoint != nil && endpoint.Stream {
+		// Stream body is consumed by the first attempt
+		return 1
+	}
+	if (endpoint != nil && endpoint.Idempotent) || (request != nil && p.methods[strings.ToUpper(request.Method)]) {
+		return p.maxAttempts
+	}
+	return 1
+}
+
+// Backoff returns delay before the retry. Delay grows exponentially and is jittered, so retries
+// of many requests don't arrive at once
+func (p *RetryPolicy) Backoff(retry int) time.Duration {
+	return exponentialBackoff(p.initialBackoff, p.maxBackoff, retry)
+}
+
+// exponentialBackoff doubles initial delay for every attempt after the first one up to max and
+// picks a random delay between half of it and the full value
+func exponentialBackoff(initial, max time.Duration, attempt int) time.Duration {
+	backoff := initial
+	for i := 1; i < attempt && backoff < max; i++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		backoff = max
+	}
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+// isRetryable returns true for errors that mean the request didn't reach the service, so sending it again is safe
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrClientNotConnected) || errors.Is(err, ErrBulkheadFull) {
+		return true
+	}
+	return status.Code(err) == codes.Unavailable
+}
+
+// waitBackoff sleeps until the backoff passes. Returns false if context is done before that
+func waitBackoff(ctx context.Context, backoff time.Duration) bool {
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// RetryBudget limits retries to a share of requests, so during an outage retries can't multiply load
+// on the service. A minimum number of retries is always allowed for services with little traffic
+type RetryBudget struct {
+	mutex       sync.Mutex
+	ratio       float64
+	minRetries  int
+	window      time.Duration
+	windowStart time.Time
+	requests    int
+	retries     int
+}
+
+func NewRetryBudget(ratio float64, minRetries int, window time.Duration) *RetryBudget {
+	return &RetryBudget{
+		ratio:      ratio,
+		minRetries: minRetries,
+		window:     window,
+	}
+}
+
+// Request counts a request towards the budget
+func (b *RetryBudget) Request() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.rotate(time.Now())
+	b.requests++
+}
+
+// TryRetry spends budget on a retry. Returns false when budget is exhausted
+func (b *RetryBudget) TryRetry() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.rotate(time.Now())
+	allowed := int(float64(b.requests) * b.ratio)
+	if allowed < b.minRetries {
+		allowed = b.minRetries
+	}
+	if b.retries >= allowed {
+		return false
+	}
+	b.retries++
+	return true
+}
+
+func (b *RetryBudget) rotate(now time.Time) {
+	if now.Sub(b.windowStart) < b.window {
+		return
+	}
+	if b.retries > 0 {
+		log.Debugf("Retry budget window: %d retries for %d requests", b.retries, b.requests)
+	}
+	b.windowStart = now
+	b.requests = 0
+	b.retries = 0
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/savageking-io/ogbrest/proto"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_MaxAttempts(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   RetryConfig
+		endpoint *proto.RestEndpoint
+		method   string
+		want     int
+	}{
+		{"GET", RetryConfig{}, &proto.RestEndpoint{}, "GET", DefaultRetryMaxAttempts},
+		{"DELETE", RetryConfig{MaxAttempts: 2}, &proto.RestEndpoint{}, "DELETE", 2},
+		{"POST", RetryConfig{}, &proto.RestEndpoint{}, "POST", 1},
+		{"Idempotent POST", RetryConfig{}, &proto.RestEndpoint{Idempotent: true}, "POST", DefaultRetryMaxAttempts},
+		{"Stream", RetryConfig{}, &proto.RestEndpoint{Stream: true, Idempotent: true}, "GET", 1},
+		{"Configured methods", RetryConfig{Methods: []string{"post"}}, &proto.RestEndpoint{}, "POST", DefaultRetryMaxAttempts},
+		{"Configured methods exclude GET", RetryConfig{Methods: []string{"POST"}}, &proto.RestEndpoint{}, "GET", 1},
+		{"Disabled", RetryConfig{MaxAttempts: 1}, &proto.RestEndpoint{Idempotent: true}, "GET", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewRetryPolicy(tt.config)
+			if got := p.MaxAttempts(tt.endpoint, &proto.RestApiRequest{Method: tt.method}); got != tt.want {
+				t.Errorf("MaxAttempts() got = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := NewRetryPolicy(RetryConfig{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond})
+	tests := []struct {
+		retry int
+		min   time.Duration
+		max   time.Duration
+	}{
+		{1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, 100 * time.Millisecond, 200 * time.Millisecond},
+		{3, 150 * time.Millisecond, 300 * time.Millisecond},
+		{10, 150 * time.Millisecond, 300 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if got := p.Backoff(tt.retry); got < tt.min || got > tt.max {
+				t.Errorf("Backoff(%d) got = %s, want between %s and %s", tt.retry, got, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestRetryBudget_TryRetry(t *testing.T) {
+	b := NewRetryBudget(0.5, 1, time.Minute)
+	if !b.TryRetry() {
+		t.Errorf("TryRetry() minimum retry is not allowed")
+	}
+	if b.TryRetry() {
+		t.Errorf("TryRetry() allowed retry above minimum without requests")
+	}
+	for i := 0; i < 6; i++ {
+		b.Request()
+	}
+	// 6 requests allow 3 retries, one is already spent
+	for i := 0; i < 2; i++ {
+		if !b.TryRetry() {
+			t.Errorf("TryRetry() retry %d is not allowed", i+2)
+		}
+	}
+	if b.TryRetry() {
+		t.Errorf("TryRetry() allowed retry above budget")
+	}
+}
+
+func TestServicePool_HandleRestRequestRetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		wantRetries int
+	}{
+		{"GET is retried", "GET", 2},
+		{"POST is not retried", "POST", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPool(BalancerRoundRobin, 2)
+			p.retry = NewRetryPolicy(RetryConfig{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+			_, err := p.HandleRestRequest(context.Background(), &proto.RestEndpoint{}, &proto.RestApiRequest{Method: tt.method})
+			if !errors.Is(err, ErrClientNotConnected) {
+				t.Errorf("HandleRestRequest() error = %v, want %v", err, ErrClientNotConnected)
+			}
+			if p.retry.budget.retries != tt.wantRetries {
+				t.Errorf("retries got = %d, want %d", p.retry.budget.retries, tt.wantRetries)
+			}
+		})
+	}
+}
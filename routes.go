@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/savageking-io/ogbrest/proto"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// GatewayRouteOwner owns routes served by the gateway itself, like /status
+const GatewayRouteOwner = "ogbrest"
+
+const routeContextKey contextKey = "route"
+
+const (
+	AuthPolicyRequired = "jwt"  // Caller must present a valid token or API key
+	AuthPolicyNone     = "none" // Anyone may call the route
+)
+
+// RouteEntry is a single route of the routing table
+type RouteEntry struct {
+	Method    string
+	Pattern   string              // Full pattern including service root, e.g. /user/{id}
+	Owner     string              // Label of the service that registered the route
+	Endpoint  *proto.RestEndpoint // Endpoint definition provided by the service. nil for gateway routes
+	Auth      string              // Auth policy of the route. Taken from the endpoint when empty
+	RateLimit *RateLimit          // Resolved by the gateway when the route is registered. nil when not limited
+	Handler   http.HandlerFunc
+}
+
+// authPolicy returns policy of the route. Routes are public only when they or their endpoint say so,
+// while routes that require roles or scopes always require authentication
+func (e *RouteEntry) authPolicy() (string, error) {
+	switch e.Auth {
+	case "":
+		if e.Endpoint != nil && e.Endpoint.SkipAuthMiddleware && !requiresAuthorization(e) {
+			return AuthPolicyNone, nil
+		}
+		return AuthPolicyRequired, nil
+	case AuthPolicyRequired:
+		return AuthPolicyRequired, nil
+	case AuthPolicyNone:
+		if requiresAuthorization(e) {
+			return AuthPolicyRequired, nil
+		}
+		return AuthPolicyNone, nil
+	}
+	return "", fmt.Errorf("unknown auth policy %s of route %s %s", e.Auth, e.Method, e.Pattern)
+}
+
+// routeSnapshot is an immutable state of the routing table
+type routeSnapshot struct {
+	router  *chi.Mux
+	entries []*RouteEntry
+}
+
+// RouteTable holds all the routes served by REST, grouped by owning service.
+// chi can't remove or re-register routes, so every change builds a new router that atomically
+// replaces the previous one. Requests in flight finish on the router they started with
+type RouteTable struct {
+	mutex       sync.Mutex
+	middlewares []func(http.Handler) http.Handler
+	services    map[string][]*RouteEntry
+	snapshot    atomic.Pointer[routeSnapshot]
+}
+
+// NewRouteTable creates an empty table. Middlewares are applied to every route
+func NewRouteTable(middlewares ...func(http.Handler) http.Handler) *RouteTable {
+	t := &RouteTable{
+		middlewares: middlewares,
+		services:    make(map[string][]*RouteEntry),
+	}
+	router, _ := t.build(nil)
+	t.snapshot.Store(&routeSnapshot{router: router})
+	return t
+}
+
+// ReplaceServiceRoutes atomically replaces all the routes of the owner. When the new set can't be
+// applied, e.g. it conflicts with routes of another service, previous routes are kept
+func (t *RouteTable) ReplaceServiceRoutes(owner string, routes []*RouteEntry) error {
+	log.Traceln("RouteTable::ReplaceServiceRoutes")
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, route := range routes {
+		route.Owner = owner
+		policy, err := route.authPolicy()
+		if err != nil {
+			return err
+		}
+		route.Auth = policy
+		if conflict := t.findOwner(route.Method, route.Pattern); conflict != "" && conflict != owner {
+			return fmt.Errorf("route %s %s is already registered by [%s]", route.Method, route.Pattern, conflict)
+		}
+	}
+
+	previous, existed := t.services[owner]
+	t.services[owner] = routes
+	if err := t.rebuild(); err != nil {
+		if existed {
+			t.services[owner] = previous
+		} else {
+			delete(t.services, owner)
+		}
+		return err
+	}
+	log.Infof("Routing table updated: [%s] has %d routes", owner, len(routes))
+	return nil
+}
+
+// RemoveService removes all the routes of the owner
+func (t *RouteTable) RemoveService(owner string) {
+	log.Traceln("RouteTable::RemoveService")
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if _, ok := t.services[owner]; !ok {
+		return
+	}
+	delete(t.services, owner)
+	if err := t.rebuild(); err != nil {
+		// Remaining routes were applied before, so this is not expected
+		log.Errorf("Failed to rebuild routing table after removing [%s]: %s", owner, err.Error())
+		return
+	}
+	log.Infof("Routing table updated: routes of [%s] removed", owner)
+}
+
+// Routes returns all the routes sorted by pattern and method
+func (t *RouteTable) Routes() []*RouteEntry {
+	entries := t.snapshot.Load().entries
+	result := make([]*RouteEntry, len(entries))
+	copy(result, entries)
+	return result
+}
+
+// find returns the route that serves the request, or nil if there is none
+func (s *routeSnapshot) find(method, path string) *RouteEntry {
+	pattern := s.router.Find(chi.NewRouteContext(), method, path)
+	if pattern == "" {
+		return nil
+	}
+	for _, entry := range s.entries {
+		if entry.Method == method && entry.Pattern == pattern {
+			return entry
+		}
+	}
+	return nil
+}
+
+// ServeHTTP resolves the route once and stores it in the request context for middlewares. Route and router
+// come from the same snapshot, so middlewares and chi agree on the route even while routes are replaced
+func (t *RouteTable) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	snapshot := t.snapshot.Load()
+	route := snapshot.find(req.Method, routingPath(req))
+	snapshot.router.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), routeContextKey, route)))
+}
+
+// routingPath returns the path chi routes the request by. Escaped path is used when it differs from the
+// decoded one, so /svc/a%2Fb matches /svc/{id} rather than /svc/a/b
+func routingPath(req *http.Request) string {
+	if req.URL.RawPath != "" {
+		return req.URL.RawPath
+	}
+	if req.URL.Path == "" {
+		return "/"
+	}
+	return req.URL.Path
+}
+
+// routeFromContext returns route of the request resolved by RouteTable, or nil if request matches no route
+func routeFromContext(ctx context.Context) *RouteEntry {
+	route, _ := ctx.Value(routeContextKey).(*RouteEntry)
+	return route
+}
+
+func (t *RouteTable) findOwner(method, pattern string) string {
+	for owner, routes := range t.services {
+		for _, route := range routes {
+			if route.Method == method && route.Pattern == pattern {
+				return owner
+			}
+		}
+	}
+	return ""
+}
+
+// rebuild creates a new router from all the routes and swaps it in. Must be called with mutex locked
+func (t *RouteTable) rebuild() error {
+	var entries []*RouteEntry
+	for _, routes := range t.services {
+		entries = append(entries, routes...)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Pattern == entries[j].Pattern {
+			return entries[i].Method < entries[j].Method
+		}
+		return entries[i].Pattern < entries[j].Pattern
+	})
+
+	router, err := t.build(entries)
+	if err != nil {
+		return err
+	}
+	t.snapshot.Store(&routeSnapshot{router: router, entries: entries})
+	return nil
+}
+
+// build creates chi router. chi panics on invalid patterns, so panics are turned into errors
+func (t *RouteTable) build(entries []*RouteEntry) (router *chi.Mux, err error) {
+	var current *RouteEntry
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			router = nil
+			if current == nil {
+				err = fmt.Errorf("failed to create router: %v", recovered)
+				return
+			}
+			err = fmt.Errorf("invalid route %s %s of [%s]: %v", current.Method, current.Pattern, current.Owner, recovered)
+		}
+	}()
+
+	router = chi.NewMux()
+	router.Use(t.middlewares...)
+	for _, entry := range entries {
+		current = entry
+		router.MethodFunc(entry.Method, entry.Pattern, entry.Handler)
+	}
+	return router, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func routeHandler(code int) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(code)
+	}
+}
+
+func TestRouteTable_ReplaceServiceRoutes(t *testing.T) {
+	type request struct {
+		method string
+		path   string
+		code   int
+	}
+	tests := []struct {
+		name     string
+		existing map[string][]*RouteEntry
+		owner    string
+		routes   []*RouteEntry
+		wantErr  bool
+		requests []request
+	}{
+		{"New service", nil, "user", []*RouteEntry{{Method: "GET", Pattern: "/user/{id}", Handler: routeHandler(200)}}, false,
+			[]request{{"GET", "/user/1", 200}, {"GET", "/other", 404}}},
+		{"Replace keeps only new routes", map[string][]*RouteEntry{"user": {{Method: "GET", Pattern: "/user/old", Handler: routeHandler(200)}}}, "user",
+			[]*RouteEntry{{Method: "GET", Pattern: "/user/new", Handler: routeHandler(201)}}, false,
+			[]request{{"GET", "/user/new", 201}, {"GET", "/user/old", 404}}},
+		{"Replace same route twice", map[string][]*RouteEntry{"user": {{Method: "GET", Pattern: "/user", Handler: routeHandler(200)}}}, "user",
+			[]*RouteEntry{{Method: "GET", Pattern: "/user", Handler: routeHandler(202)}}, false,
+			[]request{{"GET", "/user", 202}}},
+		{"Conflict with another service", map[string][]*RouteEntry{"user": {{Method: "GET", Pattern: "/user", Handler: routeHandler(200)}}}, "test",
+			[]*RouteEntry{{Method: "GET", Pattern: "/user", Handler: routeHandler(201)}}, true,
+			[]request{{"GET", "/user", 200}}},
+		{"Invalid pattern keeps previous routes", map[string][]*RouteEntry{"user": {{Method: "GET", Pattern: "/user", Handler: routeHandler(200)}}}, "user",
+			[]*RouteEntry{{Method: "GET", Pattern: "/user/{id", Handler: routeHandler(201)}}, true,
+			[]request{{"GET", "/user", 200}}},
+		{"Unknown auth policy", nil, "user", []*RouteEntry{{Method: "GET", Pattern: "/user", Auth: "optional", Handler: routeHandler(200)}}, true,
+			[]request{{"GET", "/user", 404}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := NewRouteTable()
+			for owner, routes := range tt.existing {
+				if err := table.ReplaceServiceRoutes(owner, routes); err != nil {
+					t.Fatalf("ReplaceServiceRoutes() setup error = %v", err)
+				}
+			}
+			if err := table.ReplaceServiceRoutes(tt.owner, tt.routes); (err != nil) != tt.wantErr {
+				t.Errorf("ReplaceServiceRoutes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, r := range tt.requests {
+				w := httptest.NewRecorder()
+				table.ServeHTTP(w, httptest.NewRequest(r.method, r.path, nil))
+				if w.Code != r.code {
+					t.Errorf("%s %s got = %d, want %d", r.method, r.path, w.Code, r.code)
+				}
+			}
+		})
+	}
+}
+
+func TestRouteTable_RemoveService(t *testing.T) {
+	table := NewRouteTable()
+	_ = table.ReplaceServiceRoutes("user", []*RouteEntry{{Method: "GET", Pattern: "/user", Handler: routeHandler(200)}})
+	_ = table.ReplaceServiceRoutes("test", []*RouteEntry{{Method: "GET", Pattern: "/test", Handler: routeHandler(200)}})
+
+	table.RemoveService("user")
+
+	if got := len(table.Routes()); got != 1 {
+		t.Errorf("Routes() got = %d, want 1", got)
+	}
+	w := httptest.NewRecorder()
+	table.ServeHTTP(w, httptest.NewRequest("GET", "/user", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /user got = %d, want 404", w.Code)
+	}
+	// Removed service can register the same routes again
+	if err := table.ReplaceServiceRoutes("user", []*RouteEntry{{Method: "GET", Pattern: "/user", Handler: routeHandler(200)}}); err != nil {
+		t.Errorf("ReplaceServiceRoutes() error = %v", err)
+	}
+}
+
+func TestRouteTable_ServeHTTP_RouteContext(t *testing.T) {
+	var table *RouteTable
+	var seen []string
+	// Middleware records route of the request and replaces routes while the request is served
+	middleware := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if route := routeFromContext(req.Context()); route != nil {
+				seen = append(seen, route.Method+" "+route.Pattern)
+			} else {
+				seen = append(seen, "none")
+			}
+			_ = table.ReplaceServiceRoutes("user", nil)
+			next.ServeHTTP(w, req)
+		})
+	}
+	table = NewRouteTable(middleware)
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		wantSeen string
+		wantCode int
+	}{
+		{"Matched route", "GET", "/user/42", "GET /user/{id}", http.StatusOK},
+		{"Other method", "DELETE", "/user/42", "DELETE /user/{id}", http.StatusNoContent},
+		{"No route", "GET", "/unknown", "none", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = table.ReplaceServiceRoutes("user", []*RouteEntry{
+				{Method: "GET", Pattern: "/user/{id}", Handler: routeHandler(http.StatusOK)},
+				{Method: "DELETE", Pattern: "/user/{id}", Handler: routeHandler(http.StatusNoContent)},
+			})
+			seen = nil
+			w := httptest.NewRecorder()
+			table.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if len(seen) != 1 || seen[0] != tt.wantSeen {
+				t.Errorf("middleware saw route %v, want %s", seen, tt.wantSeen)
+			}
+			// Request finishes on the routes it started with, even though they were removed
+			if w.Code != tt.wantCode {
+				t.Errorf("%s %s got = %d, want %d", tt.method, tt.path, w.Code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -1,38 +1,90 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	log "github.com/sirupsen/logrus"
+	"sort"
+	"sync"
 )
 
 type Service struct {
-	restClients map[string]*Client
+	restClients   map[string]*ServicePool // Pools of service instances by service label
+	discovery     *Discovery
+	stopDiscovery context.CancelFunc
+	mutex         sync.RWMutex
 }
 
 func (s *Service) Init(r *REST) error {
 	log.Traceln("Service::Init")
-	s.restClients = make(map[string]*Client)
+	s.restClients = make(map[string]*ServicePool)
 
 	log.Infof("Initializing %d REST clients", len(AppConfig.Services))
 	for _, service := range AppConfig.Services {
 		log.Infof("Initializing REST client %s", service.Label)
-		s.restClients[service.Label] = &Client{}
-		if err := s.restClients[service.Label].Init(&service, r.RegisterNewRoute, r.AddToAuthIgnoreList); err != nil {
+		if _, ok := s.restClients[service.Label]; ok {
+			return fmt.Errorf("service [%s] is configured more than once", service.Label)
+		}
+		if len(service.InstanceList()) == 0 && service.DNS == nil && AppConfig.Discovery.File == "" {
+			log.Warnf("Service [%s] has no instances and no way to discover them", service.Label)
+		}
+		pool, err := NewServicePool(&service, r.UpdateServiceRoutes, r.RemoveServiceRoutes)
+		if err != nil {
 			log.Errorf("Failed to initialize REST client: %s", err.Error())
 			return err
 		}
+		pool.SetEventHandler(r.HandleGatewayEvent)
+		s.restClients[service.Label] = pool
+	}
+
+	discovery, err := NewDiscovery(&AppConfig.Discovery, AppConfig.Services, s.restClients)
+	if err != nil {
+		log.Errorf("Failed to initialize discovery: %s", err.Error())
+		return err
 	}
+	s.discovery = discovery
+	r.SetServiceStatusProvider(s.Status)
 
 	return nil
 }
 
 func (s *Service) Start(r *REST) error {
 	log.Traceln("Service::Start")
-	for _, client := range s.restClients {
-		if err := client.Start(); err != nil {
-			log.Errorf("Failed to start REST client: %s", err.Error())
-			client.ScheduleRestart()
-		}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, pool := range s.restClients {
+		pool.Start()
 	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stopDiscovery = cancel
+	go s.discovery.Run(ctx)
 
 	return nil
 }
+
+// Stop stops discovery and closes connections to all the services
+func (s *Service) Stop() {
+	log.Traceln("Service::Stop")
+	if s.stopDiscovery != nil {
+		s.stopDiscovery()
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, pool := range s.restClients {
+		pool.Stop()
+	}
+}
+
+// Status returns state of every service sorted by label
+func (s *Service) Status() []ServiceStatus {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	result := make([]ServiceStatus, 0, len(s.restClients))
+	for _, pool := range s.restClients {
+		result = append(result, pool.Status())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Label < result[j].Label
+	})
+	return result
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"github.com/savageking-io/ogbrest/proto"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+)
+
+// streamChunkSize is the maximum size of a body chunk sent over NewRestStreamRequest
+const streamChunkSize = 32 * 1024
+
+// sendStreamBody reads request body and forwards it to the service chunk by chunk.
+// Sending side of the stream is closed once the body is exhausted. Every chunk gets its own buffer,
+// as gRPC doesn't allow to modify a message after it's sent
+func sendStreamBody(stream proto.RestInterService_NewRestStreamRequestClient, body io.Reader) error {
+	log.Traceln("sendStreamBody")
+	for {
+		buf := make([]byte, streamChunkSize)
+		n, err := body.Read(buf)
+		if n > 0 {
+			chunk := &proto.RestStreamRequest{
+				Payload: &proto.RestStreamRequest_Chunk{Chunk: buf[:n]},
+			}
+			if sendErr := stream.Send(chunk); sendErr != nil {
+				return sendErr
+			}
+		}
+		if err == io.EOF {
+			return stream.CloseSend()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// receiveStreamHead waits for the response head from the service
+func receiveStreamHead(stream proto.RestInterService_NewRestStreamRequestClient) (*proto.RestApiResponse, error) {
+	log.Traceln("receiveStreamHead")
+	first, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	head := first.GetHead()
+	if head == nil {
+		return nil, fmt.Errorf("service sent body chunk before response head")
+	}
+	return head, nil
+}
+
+// copyStreamBody writes body chunks to the client as they arrive, flushing after each one.
+// Response head is already sent at this point, so errors can only cut the body short
+func copyStreamBody(stream proto.RestInterService_NewRestStreamRequestClient, w http.ResponseWriter) error {
+	log.Traceln("copyStreamBody")
+	flusher, _ := w.(http.Flusher)
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("stream interrupted after response head was sent: %w", err)
+		}
+		if _, err := w.Write(msg.GetChunk()); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/savageking-io/ogbrest/proto"
+	"google.golang.org/grpc"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeStream is the gateway side of NewRestStreamRequest. Recv returns responses in order, then err or io.EOF
+type fakeStream struct {
+	grpc.ClientStream
+	responses []*proto.RestStreamResponse
+	err       error
+
+	mu     sync.Mutex
+	sent   []*proto.RestStreamRequest
+	closed chan struct{}
+}
+
+func newFakeStream(err error, responses ...*proto.RestStreamResponse) *fakeStream {
+	return &fakeStream{responses: responses, err: err, closed: make(chan struct{})}
+}
+
+func (s *fakeStream) Send(msg *proto.RestStreamRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func (s *fakeStream) CloseSend() error {
+	close(s.closed)
+	return nil
+}
+
+func (s *fakeStream) Recv() (*proto.RestStreamResponse, error) {
+	if len(s.responses) == 0 {
+		if s.err != nil {
+			return nil, s.err
+		}
+		return nil, io.EOF
+	}
+	msg := s.responses[0]
+	s.responses = s.responses[1:]
+	return msg, nil
+}
+
+// uploaded waits for the sending side to be closed and returns sizes of chunks and the whole body
+func (s *fakeStream) uploaded(t *testing.T) ([]int, []byte) {
+	select {
+	case <-s.closed:
+	case <-time.After(time.Second):
+		t.Fatalf("sending side of the stream was not closed")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sizes []int
+	var body []byte
+	for _, msg := range s.sent {
+		sizes = append(sizes, len(msg.GetChunk()))
+		body = append(body, msg.GetChunk()...)
+	}
+	return sizes, body
+}
+
+func streamHead(code int32, headers ...*proto.RestHeader) *proto.RestStreamResponse {
+	return &proto.RestStreamResponse{Payload: &proto.RestStreamResponse_Head{Head: &proto.RestApiResponse{HttpCode: code, Headers: headers}}}
+}
+
+func streamChunk(chunk string) *proto.RestStreamResponse {
+	return &proto.RestStreamResponse{Payload: &proto.RestStreamResponse_Chunk{Chunk: []byte(chunk)}}
+}
+
+// streamBackend opens the same stream for every request
+type streamBackend struct {
+	testBackend
+	stream *fakeStream
+	head   *proto.RestApiRequest
+}
+
+func (b *streamBackend) NewRestStream(ctx context.Context, head *proto.RestApiRequest) (proto.RestInterService_NewRestStreamRequestClient, error) {
+	b.head = head
+	return b.stream, nil
+}
+
+func TestSendStreamBody(t *testing.T) {
+	body := bytes.Repeat([]byte{0xff, 0x00, 'a'}, streamChunkSize)
+	stream := newFakeStream(nil)
+	if err := sendStreamBody(stream, bytes.NewReader(body)); err != nil {
+		t.Fatalf("sendStreamBody() error = %v", err)
+	}
+	sizes, got := stream.uploaded(t)
+	if len(sizes) != 3 || sizes[0] != streamChunkSize || sizes[2] != streamChunkSize {
+		t.Errorf("chunk sizes got = %v, want 3 chunks of %d", sizes, streamChunkSize)
+	}
+	if !bytes.Equal(got, body) {
+		t.Errorf("uploaded body differs from the request body")
+	}
+}
+
+func TestReceiveStreamHead(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  *fakeStream
+		want    int32
+		wantErr error
+	}{
+		{"Head", newFakeStream(nil, streamHead(201)), 201, nil},
+		{"Closed before head", newFakeStream(nil), 0, io.EOF},
+		{"Chunk before head", newFakeStream(nil, streamChunk("body")), 0, errors.New("")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			head, err := receiveStreamHead(tt.stream)
+			if (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("receiveStreamHead() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr == io.EOF && !errors.Is(err, io.EOF) {
+				t.Errorf("receiveStreamHead() error = %v, want %v", err, io.EOF)
+			}
+			if err == nil && head.HttpCode != tt.want {
+				t.Errorf("receiveStreamHead() code got = %d, want %d", head.HttpCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestCopyStreamBody(t *testing.T) {
+	failure := errors.New("service crashed")
+	tests := []struct {
+		name    string
+		stream  *fakeStream
+		want    string
+		wantErr bool
+	}{
+		{"Multiple chunks", newFakeStream(nil, streamChunk("first,"), streamChunk("second,"), streamChunk("third")), "first,second,third", false},
+		{"Error mid body", newFakeStream(failure, streamChunk("first,"), streamChunk("second,")), "first,second,", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			err := copyStreamBody(tt.stream, w)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("copyStreamBody() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, failure) {
+				t.Errorf("copyStreamBody() error = %v, want %v", err, failure)
+			}
+			if w.Body.String() != tt.want {
+				t.Errorf("copyStreamBody() body got = %s, want %s", w.Body.String(), tt.want)
+			}
+			if !w.Flushed {
+				t.Errorf("copyStreamBody() didn't flush chunks")
+			}
+		})
+	}
+}
+
+func TestREST_handleStreamRequest(t *testing.T) {
+	upload := strings.Repeat("u", streamChunkSize+10)
+	tests := []struct {
+		name       string
+		stream     *fakeStream
+		wantCode   int
+		wantBody   string
+		wantHeader string
+	}{
+		{"Multiple chunks", newFakeStream(nil, streamHead(201, &proto.RestHeader{Key: "Content-Type", Value: "text/plain"}), streamChunk("first,"), streamChunk("second")), 201, "first,second", "text/plain"},
+		{"No code in head", newFakeStream(nil, streamHead(0), streamChunk("body")), http.StatusOK, "body", ""},
+		{"Closed before head", newFakeStream(nil), http.StatusInternalServerError, "", ""},
+		{"Service unavailable before head", newFakeStream(ErrClientNotConnected), http.StatusServiceUnavailable, "", ""},
+		{"Error mid body", newFakeStream(errors.New("service crashed"), streamHead(200), streamChunk("partial")), http.StatusOK, "partial", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &REST{}
+			backend := &streamBackend{stream: tt.stream}
+			endpoint := &proto.RestEndpoint{Path: "/upload", Method: "POST", Stream: true}
+			req := httptest.NewRequest("POST", "/files/upload?name=a", strings.NewReader(upload))
+			w := httptest.NewRecorder()
+			r.handleStreamRequest(w, req, endpoint, backend)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("handleStreamRequest() code got = %d, want %d", w.Code, tt.wantCode)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("handleStreamRequest() body got = %s, want %s", w.Body.String(), tt.wantBody)
+			}
+			if got := w.Header().Get("Content-Type"); tt.wantHeader != "" && got != tt.wantHeader {
+				t.Errorf("handleStreamRequest() Content-Type got = %s, want %s", got, tt.wantHeader)
+			}
+			if backend.head.Uri != "/upload" || backend.head.Path != "/files/upload" || backend.head.RawQuery != "name=a" {
+				t.Errorf("stream head got = %+v", backend.head)
+			}
+			if _, body := tt.stream.uploaded(t); string(body) != upload {
+				t.Errorf("uploaded %d bytes, want %d", len(body), len(upload))
+			}
+		})
+	}
+}
+
+// watchedBody records reads of the request body that are in progress or start after the handler returned
+type watchedBody struct {
+	io.ReadCloser
+	returned atomic.Bool
+	late     atomic.Bool
+	reading  atomic.Int32
+}
+
+func (b *watchedBody) Read(p []byte) (int, error) {
+	if b.returned.Load() {
+		b.late.Store(true)
+	}
+	b.reading.Add(1)
+	defer b.reading.Add(-1)
+	return b.ReadCloser.Read(p)
+}
+
+func TestREST_handleStreamRequest_StopsUpload(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream *fakeStream
+	}{
+		{"Response before upload ends", newFakeStream(nil, streamHead(200), streamChunk("done"))},
+		{"Error before head", newFakeStream(errors.New("service crashed"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &REST{}
+			backend := &streamBackend{stream: tt.stream}
+			endpoint := &proto.RestEndpoint{Path: "/upload", Method: "POST", Stream: true}
+			done := make(chan *watchedBody, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				body := &watchedBody{ReadCloser: req.Body}
+				req.Body = body
+				r.handleStreamRequest(w, req, endpoint, backend)
+				if body.reading.Load() != 0 {
+					body.late.Store(true)
+				}
+				body.returned.Store(true)
+				done <- body
+			}))
+			defer server.Close()
+
+			// Client never finishes the upload
+			reader, writer := io.Pipe()
+			defer writer.Close()
+			go func() {
+				_, _ = writer.Write([]byte("partial"))
+			}()
+			go func() {
+				response, err := http.Post(server.URL, "application/octet-stream", reader)
+				if err == nil {
+					_ = response.Body.Close()
+				}
+			}()
+
+			select {
+			case body := <-done:
+				time.Sleep(100 * time.Millisecond)
+				if body.late.Load() {
+					t.Errorf("request body is read after the handler returned")
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("handler didn't return while the upload was in progress")
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/golang-jwt/jwt/v5"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultTokenCacheTTL         = time.Minute
+	DefaultTokenCacheNegativeTTL = 5 * time.Second
+	DefaultTokenCacheMaxEntries  = 100000
+	// tokenValidationTimeout limits validations shared by concurrent requests, as they outlive the request that started them
+	tokenValidationTimeout = 10 * time.Second
+)
+
+// tokenCacheEntry is a result of token validation stored by TokenCache
+type tokenCacheEntry struct {
+	key       string
+	valid     bool
+	userId    int32
+	expiresAt time.Time
+}
+
+// tokenCall is a validation in flight. Requests with the same token wait for it instead of calling the user service
+type tokenCall struct {
+	done   chan struct{}
+	valid  bool
+	userId int32
+	err    error
+}
+
+// TokenCacheStatus describes usage of the token cache
+type TokenCacheStatus struct {
+	Enabled bool   `json:"enabled"`
+	Entries int    `json:"entries"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	Shared  uint64 `json:"shared"` // Requests that waited for validation of the same token
+	Revoked uint64 `json:"revoked"`
+}
+
+// TokenCache keeps results of remote token validation, so the user service is not called for every request.
+// Valid tokens are cached for TTL, but never past their expiry, invalid tokens for a short negative TTL.
+// Errors of the user service are never cached. nil cache validates every token remotely
+type TokenCache struct {
+	validator   TokenValidator
+	ttl         time.Duration
+	negativeTTL time.Duration
+	maxEntries  int
+	mutex       sync.Mutex
+	entries     map[string]*list.Element
+	lru         *list.List // Front is the most recently used entry
+	byUser      map[int32]map[string]bool
+	calls       map[string]*tokenCall
+	generation  uint64 // Incremented by revocations. Results of validations started before are not stored
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	shared      atomic.Uint64
+	revoked     atomic.Uint64
+}
+
+// NewTokenCache returns nil when cache is disabled
+func NewTokenCache(config TokenCacheConfig, validator TokenValidator) *TokenCache {
+	if !config.Enabled {
+		return nil
+	}
+	c := &TokenCache{
+		validator:   validator,
+		ttl:         config.TTL,
+		negativeTTL: config.NegativeTTL,
+		maxEntries:  config.MaxEntries,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+		byUser:      make(map[int32]map[string]bool),
+		calls:       make(map[string]*tokenCall),
+	}
+	if c.ttl <= 0 {
+		c.ttl = DefaultTokenCacheTTL
+	}
+	if c.negativeTTL <= 0 {
+		c.negativeTTL = DefaultTokenCacheNegativeTTL
+	}
+	if c.maxEntries <= 0 {
+		c.maxEntries = DefaultTokenCacheMaxEntries
+	}
+	log.Infof("Token cache enabled: %d entries for %s, invalid tokens for %s", c.maxEntries, c.ttl.String(), c.negativeTTL.String())
+	return c
+}
+
+// ValidateToken returns cached result of the token or validates it with the user service.
+// Concurrent requests with the same token share a single validation
+func (c *TokenCache) ValidateToken(ctx context.Context, token string) (bool, int32, error) {
+	key := tokenKey(token)
+	c.mutex.Lock()
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*tokenCacheEntry)
+		if time.Now().Before(entry.expiresAt) {
+			c.lru.MoveToFront(element)
+			c.mutex.Unlock()
+			c.hits.Add(1)
+			return entry.valid, entry.userId, nil
+		}
+		c.remove(element)
+	}
+	c.misses.Add(1)
+	call, inFlight := c.calls[key]
+	if inFlight {
+		c.shared.Add(1)
+	} else {
+		call = &tokenCall{done: make(chan struct{})}
+		c.calls[key] = call
+		go c.validate(key, token, call, c.generation)
+	}
+	c.mutex.Unlock()
+
+	select {
+	case <-call.done:
+		return call.valid, call.userId, call.err
+	case <-ctx.Done():
+		return false, -1, ctx.Err()
+	}
+}
+
+// validate calls the user service and stores the result unless tokens were revoked meanwhile
+func (c *TokenCache) validate(key string, token string, call *tokenCall, generation uint64) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenValidationTimeout)
+	defer cancel()
+	call.valid, call.userId, call.err = c.validator.ValidateToken(ctx, token)
+
+	c.mutex.Lock()
+	delete(c.calls, key)
+	if call.err == nil && generation == c.generation {
+		c.store(key, token, call.valid, call.userId)
+	}
+	c.mutex.Unlock()
+	close(call.done)
+}
+
+// store adds the result to the cache. Must be called with mutex locked
+func (c *TokenCache) store(key string, token string, valid bool, userId int32) {
+	now := time.Now()
+	expiresAt := now.Add(c.negativeTTL)
+	if valid {
+		expiresAt = now.Add(c.ttl)
+		if expiry, ok := tokenExpiry(token); ok && expiry.Before(expiresAt) {
+			expiresAt = expiry
+		}
+		if !now.Before(expiresAt) {
+			return
+		}
+	}
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	c.entries[key] = c.lru.PushFront(&tokenCacheEntry{key: key, valid: valid, userId: userId, expiresAt: expiresAt})
+	if valid {
+		if c.byUser[userId] == nil {
+			c.byUser[userId] = make(map[string]bool)
+		}
+		c.byUser[userId][key] = true
+	}
+	for len(c.entries) > c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+// RevokeUsers removes cached tokens of the users, e.g. when they log out or are banned.
+// Returns number of removed entries
+func (c *TokenCache) RevokeUsers(userIds ...int32) int {
+	if c == nil {
+		return 0
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.generation++
+	removed := 0
+	for _, userId := range userIds {
+		for key := range c.byUser[userId] {
+			if element, ok := c.entries[key]; ok {
+				c.remove(element)
+				removed++
+			}
+		}
+	}
+	c.revoked.Add(uint64(removed))
+	return removed
+}
+
+// RevokeTokens removes cached results of the tokens. Returns number of removed entries
+func (c *TokenCache) RevokeTokens(tokens ...string) int {
+	if c == nil {
+		return 0
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.generation++
+	removed := 0
+	for _, token := range tokens {
+		if element, ok := c.entries[tokenKey(token)]; ok {
+			c.remove(element)
+			removed++
+		}
+	}
+	c.revoked.Add(uint64(removed))
+	return removed
+}
+
+// Status returns a snapshot of cache usage
+func (c *TokenCache) Status() TokenCacheStatus {
+	if c == nil {
+		return TokenCacheStatus{}
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return TokenCacheStatus{
+		Enabled: true,
+		Entries: len(c.entries),
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Shared:  c.shared.Load(),
+		Revoked: c.revoked.Load(),
+	}
+}
+
+// remove deletes the entry. Must be called with mutex locked
+func (c *TokenCache) remove(element *list.Element) {
+	entry := element.Value.(*tokenCacheEntry)
+	c.lru.Remove(element)
+	delete(c.entries, entry.key)
+	if keys, ok := c.byUser[entry.userId]; ok {
+		delete(keys, entry.key)
+		if len(keys) == 0 {
+			delete(c.byUser, entry.userId)
+		}
+	}
+}
+
+// tokenKey keeps tokens themselves out of memory of the cache
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// tokenExpiry reads exp claim of JWT without verifying it. The user service has already validated the token
+func tokenExpiry(token string) (time.Time, bool) {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return time.Time{}, false
+	}
+	expiry, err := claims.GetExpirationTime()
+	if err != nil || expiry == nil {
+		return time.Time{}, false
+	}
+	return expiry.Time, true
+}
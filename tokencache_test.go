@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/savageking-io/ogbrest/proto"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingValidator treats tokens listed in users as valid and blocks until release is closed, if set
+type countingValidator struct {
+	users   map[string]int32
+	err     error
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (v *countingValidator) ValidateToken(ctx context.Context, token string) (bool, int32, error) {
+	v.calls.Add(1)
+	if v.release != nil {
+		<-v.release
+	}
+	if v.err != nil {
+		return false, -1, v.err
+	}
+	userId, ok := v.users[token]
+	return ok, userId, nil
+}
+
+func TestTokenCache_ValidateToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    TokenCacheConfig
+		token     string
+		wait      time.Duration // Between the two validations
+		wantValid bool
+		wantCalls int32
+	}{
+		{"Valid token cached", TokenCacheConfig{Enabled: true}, "valid", 0, true, 1},
+		{"Invalid token cached", TokenCacheConfig{Enabled: true}, "invalid", 0, false, 1},
+		{"Valid token expires", TokenCacheConfig{Enabled: true, TTL: 10 * time.Millisecond}, "valid", 20 * time.Millisecond, true, 2},
+		{"Invalid token expires", TokenCacheConfig{Enabled: true, NegativeTTL: 10 * time.Millisecond}, "invalid", 20 * time.Millisecond, false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := &countingValidator{users: map[string]int32{"valid": 1}}
+			c := NewTokenCache(tt.config, validator)
+			for i := 0; i < 2; i++ {
+				if i == 1 {
+					time.Sleep(tt.wait)
+				}
+				valid, _, err := c.ValidateToken(context.Background(), tt.token)
+				if err != nil || valid != tt.wantValid {
+					t.Fatalf("ValidateToken() got = %v, %v, want %v", valid, err, tt.wantValid)
+				}
+			}
+			if got := validator.calls.Load(); got != tt.wantCalls {
+				t.Errorf("user service calls got = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+	token := signToken(t, jwt.SigningMethodHS256, []byte("secret"), "", jwt.MapClaims{"exp": expiry.Unix()})
+	c := NewTokenCache(TokenCacheConfig{Enabled: true, TTL: 2 * time.Hour}, &countingValidator{users: map[string]int32{token: 1}})
+	if _, _, err := c.ValidateToken(context.Background(), token); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.entries[tokenKey(token)].Value.(*tokenCacheEntry).expiresAt; !got.Equal(expiry) {
+		t.Errorf("entry expires at %s, want token expiry %s", got, expiry)
+	}
+
+	if c := NewTokenCache(TokenCacheConfig{}, &countingValidator{}); c != nil {
+		t.Errorf("NewTokenCache() created disabled cache")
+	}
+}
+
+func TestTokenCache_Errors(t *testing.T) {
+	validator := &countingValidator{err: errors.New("unavailable")}
+	c := NewTokenCache(TokenCacheConfig{Enabled: true}, validator)
+	for i := 0; i < 2; i++ {
+		if _, _, err := c.ValidateToken(context.Background(), "valid"); err == nil {
+			t.Fatalf("ValidateToken() error is nil")
+		}
+	}
+	if got := validator.calls.Load(); got != 2 {
+		t.Errorf("user service calls got = %d, want 2", got)
+	}
+}
+
+func TestTokenCache_SingleFlight(t *testing.T) {
+	validator := &countingValidator{users: map[string]int32{"valid": 1}, release: make(chan struct{})}
+	c := NewTokenCache(TokenCacheConfig{Enabled: true}, validator)
+
+	var wg sync.WaitGroup
+	results := make(chan int32, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, userId, _ := c.ValidateToken(context.Background(), "valid")
+			results <- userId
+		}()
+	}
+	for c.Status().Shared < 9 {
+		time.Sleep(time.Millisecond)
+	}
+	close(validator.release)
+	wg.Wait()
+	close(results)
+	for userId := range results {
+		if userId != 1 {
+			t.Errorf("ValidateToken() user id got = %d, want 1", userId)
+		}
+	}
+	if got := validator.calls.Load(); got != 1 {
+		t.Errorf("user service calls got = %d, want 1", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	validator.release = make(chan struct{})
+	defer close(validator.release)
+	if _, _, err := c.ValidateToken(ctx, "other"); !errors.Is(err, context.Canceled) {
+		t.Errorf("ValidateToken() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestTokenCache_Revoke(t *testing.T) {
+	validator := &countingValidator{users: map[string]int32{"first": 1, "second": 1, "third": 2}}
+	c := NewTokenCache(TokenCacheConfig{Enabled: true}, validator)
+	r := &REST{tokens: c}
+	validate := func() {
+		for _, token := range []string{"first", "second", "third"} {
+			if _, _, err := c.ValidateToken(context.Background(), token); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	validate()
+	if removed := c.RevokeUsers(1); removed != 2 {
+		t.Errorf("RevokeUsers() got = %d, want 2", removed)
+	}
+	validate()
+	if got := validator.calls.Load(); got != 5 {
+		t.Errorf("user service calls got = %d, want 5", got)
+	}
+
+	revocation := &proto.GatewayEvent{Payload: &proto.GatewayEvent_TokenRevocation{TokenRevocation: &proto.TokenRevocation{Tokens: []string{"third"}}}}
+	r.HandleGatewayEvent("user", "/user", revocation)
+	validate()
+	if got := validator.calls.Load(); got != 6 {
+		t.Errorf("user service calls after event got = %d, want 6", got)
+	}
+	if status := c.Status(); status.Entries != 3 || status.Revoked != 3 {
+		t.Errorf("Status() got = %+v", status)
+	}
+}
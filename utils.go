@@ -1,5 +1,11 @@
 package main
 
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
 func sanitizeRoot(root string) string {
 	if root == "" {
 		return "/"
@@ -25,3 +31,18 @@ func sanitizeUri(uri string) string {
 	}
 	return uri
 }
+
+// clientIP returns IP of the client. X-Forwarded-For is only trusted when forwardedFor is set
+func clientIP(req *http.Request, forwardedFor bool) string {
+	if forwardedFor {
+		if forwarded := req.Header.Get("X-Forwarded-For"); forwarded != "" {
+			client, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(client)
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
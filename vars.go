@@ -1,6 +1,10 @@
 package main
 
-import "github.com/savageking-io/ogbrest/kafka"
+import (
+	"fmt"
+	"github.com/savageking-io/ogbrest/kafka"
+	"time"
+)
 
 var (
 	AppVersion     = "Undefined"
@@ -11,16 +15,202 @@ var (
 
 // Configuration structures for rest-config.yaml
 type RestConfig struct {
-	Hostname       string   `yaml:"hostname"`
-	Port           uint16   `yaml:"port"`
-	AllowedOrigins []string `yaml:"allowed_origins"`
+	Hostname        string             `yaml:"hostname"`
+	Port            uint16             `yaml:"port"`
+	AllowedOrigins  []string           `yaml:"allowed_origins"`
+	ShutdownTimeout time.Duration      `yaml:"shutdown_timeout"` // How long in-flight requests are drained on shutdown. Default is 15s
+	ForwardedFor    bool               `yaml:"forwarded_for"`    // Take client IP from X-Forwarded-For. Enable only behind a trusted proxy
+	MaxBodyBytes    int64              `yaml:"max_body_bytes"`   // Request body limit. Stream endpoints are only limited by their own limit. Default is 10MB, unlimited when negative
+	Cache           CacheConfig        `yaml:"cache"`
+	RateLimit       RateLimitConfig    `yaml:"rate_limit"`
+	LoadShedding    LoadSheddingConfig `yaml:"load_shedding"` // Rejects requests to services while the gateway is overloaded
+	Maintenance     MaintenanceConfig  `yaml:"maintenance"`   // Defaults of maintenance windows toggled with the admin API
+	Auth            AuthConfig         `yaml:"auth"`          // How tokens of authenticated routes are validated
+}
+
+type AuthConfig struct {
+	Mode            string           `yaml:"mode"`             // remote (default) validates every token with the user service, local verifies tokens with signing keys
+	Fallback        bool             `yaml:"fallback"`         // Validate tokens with the user service when their signing key is not known. Only for local mode
+	JWKSURL         string           `yaml:"jwks_url"`         // JWKS document with signing keys, e.g. served by the user service
+	PublicKeys      []string         `yaml:"public_keys"`      // Files with PEM public keys or certificates
+	RefreshInterval time.Duration    `yaml:"refresh_interval"` // How often keys are loaded again. Default is 15m
+	Algorithms      []string         `yaml:"algorithms"`       // Accepted signing algorithms. Default is RS*, PS*, ES* and EdDSA
+	Issuer          string           `yaml:"issuer"`           // Required iss claim. Not checked when empty
+	Audience        string           `yaml:"audience"`         // Required aud claim. Not checked when empty
+	UserIdClaim     string           `yaml:"user_id_claim"`    // Claim with numeric user id. Default is sub
+	Leeway          time.Duration    `yaml:"leeway"`           // Allowed clock skew when checking expiry
+	RolesClaim      string           `yaml:"roles_claim"`      // Claim with roles required by endpoints. Default is roles
+	ScopesClaim     string           `yaml:"scopes_claim"`     // Claim with scopes required by endpoints. Default is scope
+	APIKeys         APIKeysConfig    `yaml:"api_keys"`         // Keys of servers, bots and tools
+	Cache           TokenCacheConfig `yaml:"cache"`            // Results of validation by the user service
+}
+
+type APIKeysConfig struct {
+	Header string         `yaml:"header"` // Header with API key. Default is X-Api-Key
+	Keys   []APIKeyConfig `yaml:"keys"`
+}
+
+type APIKeyConfig struct {
+	Name      string                `yaml:"name"`       // Identifies the key in logs and to services
+	Hash      string                `yaml:"hash"`       // Hex encoded SHA-256 of the key, e.g. echo -n $KEY | sha256sum
+	Owner     string                `yaml:"owner"`      // Who the key was issued to, e.g. matchmaking servers
+	UserId    int32                 `yaml:"user_id"`    // User requests are made on behalf of. Optional
+	Roles     []string              `yaml:"roles"`      // Roles checked by endpoints that require them
+	Scopes    []string              `yaml:"scopes"`     // Scopes checked by endpoints that require them
+	ExpiresAt time.Time             `yaml:"expires_at"` // Key is rejected after this time. Never expires when empty
+	RateLimit APIKeyRateLimitConfig `yaml:"rate_limit"` // Replaces gateway rate limit for requests with the key
+}
+
+type APIKeyRateLimitConfig struct {
+	Requests int           `yaml:"requests"`
+	Period   time.Duration `yaml:"period"`
+	Burst    int           `yaml:"burst"`
+	Key      string        `yaml:"key"` // Requests are limited per key by default, ip and user_ip limit every client of the key
+}
+
+type TokenCacheConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	TTL         time.Duration `yaml:"ttl"`          // How long valid tokens are cached, never past their expiry. Default is 1m
+	NegativeTTL time.Duration `yaml:"negative_ttl"` // How long invalid tokens are cached. Default is 5s
+	MaxEntries  int           `yaml:"max_entries"`  // Default is 100000
+}
+
+type MaintenanceConfig struct {
+	Message    string        `yaml:"message"`     // Error returned by routes under maintenance. Default is "service is under maintenance"
+	RetryAfter time.Duration `yaml:"retry_after"` // Default is 5m
+	AllowUsers []int32       `yaml:"allow_users"` // Users that get through maintenance, e.g. QA
+	AllowIPs   []string      `yaml:"allow_ips"`   // IPs or CIDR ranges that get through maintenance
+	Services   []string      `yaml:"services"`    // Labels of services under maintenance on start
+}
+
+type LoadSheddingConfig struct {
+	MaxInFlight  int           `yaml:"max_in_flight"`  // Requests to all the services in flight. Unlimited when 0
+	MaxHeapBytes uint64        `yaml:"max_heap_bytes"` // Heap size of the gateway. Unlimited when 0
+	Interval     time.Duration `yaml:"interval"`       // How often heap size is sampled. Default is 1s
+}
+
+type RateLimitConfig struct {
+	Requests int                    `yaml:"requests"` // Requests allowed per period to all the routes together. Disabled when 0
+	Period   time.Duration          `yaml:"period"`   // Default is 1s
+	Burst    int                    `yaml:"burst"`    // Requests allowed at once. Defaults to requests
+	Key      string                 `yaml:"key"`      // What clients are told apart by: user, ip or user_ip. Default is user, anonymous clients are limited by ip
+	MaxKeys  int                    `yaml:"max_keys"` // Clients tracked at once, the least recently seen one is forgotten. Default is 100000
+	Routes   []RouteRateLimitConfig `yaml:"routes"`   // Limits of single routes. Override limits provided by services
+	PreAuth  PreAuthRateLimitConfig `yaml:"pre_auth"` // Limit of every IP checked before authentication, so requests with invalid credentials are limited too
+}
+
+type PreAuthRateLimitConfig struct {
+	Requests int           `yaml:"requests"` // Disabled when 0. Must be above limits of users, as users behind NAT share it
+	Period   time.Duration `yaml:"period"`
+	Burst    int           `yaml:"burst"`
+}
+
+type RouteRateLimitConfig struct {
+	Method   string        `yaml:"method"`
+	Pattern  string        `yaml:"pattern"` // Full pattern including service root, e.g. /user/{id}
+	Requests int           `yaml:"requests"`
+	Period   time.Duration `yaml:"period"`
+	Burst    int           `yaml:"burst"`
+	Key      string        `yaml:"key"`
+}
+
+type CacheConfig struct {
+	Enabled       bool     `yaml:"enabled"`
+	MaxEntries    int      `yaml:"max_entries"`     // Default is 10000
+	MaxBytes      int64    `yaml:"max_bytes"`       // Size of all cached responses. Default is 64MB
+	MaxEntryBytes int64    `yaml:"max_entry_bytes"` // Larger responses are not cached. Default is 1MB
+	Key           []string `yaml:"key"`             // Request parts entries are keyed by: path, query, user. Default is path and query. Private entries are always keyed by user
 }
 
 type ServiceConfig struct {
-	Label    string `yaml:"label"`
+	Label          string                  `yaml:"label"`
+	Hostname       string                  `yaml:"hostname"`
+	Port           uint16                  `yaml:"port"`
+	Instances      []ServiceInstanceConfig `yaml:"instances"` // Replicas of the service in addition to hostname:port
+	Token          string                  `yaml:"token"`
+	RequestTimeout time.Duration           `yaml:"request_timeout"` // Default deadline for proxied requests, e.g. 10s. Endpoints may override it
+	Balancer       string                  `yaml:"balancer"`        // round_robin (default) or least_outstanding
+	MaxFailures    int                     `yaml:"max_failures"`    // Consecutive failures before an instance is ejected
+	EjectionTime   time.Duration           `yaml:"ejection_time"`   // How long an ejected instance doesn't receive requests
+	DNS            *ServiceDNSConfig       `yaml:"dns"`             // Discover instances with DNS lookups
+	Breaker        BreakerConfig           `yaml:"breaker"`         // Circuit breaker of every instance
+	Retry          RetryConfig             `yaml:"retry"`           // Retries of requests that didn't reach the service
+	Reconnect      ReconnectConfig         `yaml:"reconnect"`       // Reconnects of instances that lost connection or failed to start
+	Health         HealthConfig            `yaml:"health"`          // Health checks of ready instances
+	Bulkhead       BulkheadConfig          `yaml:"bulkhead"`        // Requests in flight to every instance
+}
+
+type BulkheadConfig struct {
+	MaxConcurrent int           `yaml:"max_concurrent"` // Requests in flight to a single instance. Unlimited when 0
+	MaxQueue      int           `yaml:"max_queue"`      // Requests waiting for a slot. Requests over it are rejected with 503 right away. Defaults to max_concurrent, no queue when negative
+	QueueTimeout  time.Duration `yaml:"queue_timeout"`  // How long request waits for a slot. Default is 100ms
+}
+
+type HealthConfig struct {
+	Interval  time.Duration `yaml:"interval"`   // How often instances are pinged. Default is 5s
+	Timeout   time.Duration `yaml:"timeout"`    // Default is 2s
+	MaxMisses int           `yaml:"max_misses"` // Failed pings in a row before instance is unhealthy and reconnects. Default is 3
+}
+
+type ReconnectConfig struct {
+	InitialBackoff time.Duration `yaml:"initial_backoff"` // Delay before the first reconnect, doubled for every next one. Default is 1s
+	MaxBackoff     time.Duration `yaml:"max_backoff"`     // Default is 30s
+	MaxAttempts    int           `yaml:"max_attempts"`    // Failed attempts in a row before client gives up until restarted. Unlimited when 0
+}
+
+type RetryConfig struct {
+	MaxAttempts      int           `yaml:"max_attempts"`       // Attempts including the first one. Default is 3, 1 disables retries
+	InitialBackoff   time.Duration `yaml:"initial_backoff"`    // Delay before the first retry, doubled for every next one. Default is 50ms
+	MaxBackoff       time.Duration `yaml:"max_backoff"`        // Default is 1s
+	Methods          []string      `yaml:"methods"`            // Methods that are retried. Default is GET, HEAD, PUT and DELETE. Idempotent endpoints are always retried
+	BudgetRatio      float64       `yaml:"budget_ratio"`       // Retries allowed per request within 10s window. Default is 0.2
+	BudgetMinRetries int           `yaml:"budget_min_retries"` // Retries always allowed within 10s window. Default is 10
+}
+
+type BreakerConfig struct {
+	ConsecutiveFailures int           `yaml:"consecutive_failures"` // Failures in a row that open the breaker. Default is 5
+	ErrorRate           float64       `yaml:"error_rate"`           // Share of failed requests within window that opens the breaker, e.g. 0.5. Disabled when 0
+	MinRequests         int           `yaml:"min_requests"`         // Requests within window before error rate is checked. Default is 20
+	Window              time.Duration `yaml:"window"`               // Error rate window. Default is 10s
+	OpenTimeout         time.Duration `yaml:"open_timeout"`         // How long breaker stays open before probing. Default is 30s
+	HalfOpenProbes      int           `yaml:"half_open_probes"`     // Successful probes required to close the breaker. Default is 1
+}
+
+type ServiceInstanceConfig struct {
 	Hostname string `yaml:"hostname"`
 	Port     uint16 `yaml:"port"`
-	Token    string `yaml:"token"`
+}
+
+// Address returns hostname:port of the instance
+func (c ServiceInstanceConfig) Address() string {
+	return fmt.Sprintf("%s:%d", c.Hostname, c.Port)
+}
+
+type ServiceDNSConfig struct {
+	Name string `yaml:"name"` // Full record name, e.g. _grpc._tcp.ogbuser.default.svc.cluster.local for SRV
+	Type string `yaml:"type"` // srv (default) or a
+	Port uint16 `yaml:"port"` // Port of instances found by A lookup. SRV records provide their own
+}
+
+type DiscoveryConfig struct {
+	RefreshInterval time.Duration `yaml:"refresh_interval"` // How often DNS is queried. Default is 30s
+	File            string        `yaml:"file"`             // JSON or YAML file with instances by service label
+	FileInterval    time.Duration `yaml:"file_interval"`    // How often the file is checked for changes. Default is 5s
+}
+
+// InstanceList returns all the instances of the service: hostname:port followed by instances
+func (c *ServiceConfig) InstanceList() []ServiceInstanceConfig {
+	var result []ServiceInstanceConfig
+	if c.Hostname != "" {
+		result = append(result, ServiceInstanceConfig{Hostname: c.Hostname, Port: c.Port})
+	}
+	return append(result, c.Instances...)
+}
+
+type AdminConfig struct {
+	Hostname string `yaml:"hostname"`
+	Port     uint16 `yaml:"port"`  // Admin API is disabled when port is not set
+	Token    string `yaml:"token"` // Bearer token required by every admin request
 }
 
 type UserClientConfig struct {
@@ -32,6 +222,8 @@ type Config struct {
 	LogLevel   string           `yaml:"log_level"`
 	Rest       RestConfig       `yaml:"rest"`
 	Services   []ServiceConfig  `yaml:"services"`
+	Discovery  DiscoveryConfig  `yaml:"discovery"`
 	UserClient UserClientConfig `yaml:"user_client"`
 	Kafka      kafka.Config     `yaml:"kafka"`
+	Admin      AdminConfig      `yaml:"admin"`
 }
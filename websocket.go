@@ -7,8 +7,13 @@ import (
 	"github.com/savageking-io/ogbrest/packet"
 	log "github.com/sirupsen/logrus"
 	"net/http"
+	"sync/atomic"
+	"time"
 )
 
+// webSocketCloseTimeout limits how long sending a close frame may take
+const webSocketCloseTimeout = time.Second
+
 var wsUpgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -43,21 +48,40 @@ func NewWebSocketClient(w http.ResponseWriter, req *http.Request) (*WebSocketCli
 		log.Errorf("Failed to upgrade connection: %s", err.Error())
 		return nil, err
 	}
-	return &WebSocketClient{conn: conn}, nil
+	return &WebSocketClient{conn: conn, connectedAt: time.Now()}, nil
 }
 
 type WebSocketClient struct {
-	conn     *websocket.Conn
-	shutdown bool
+	conn        *websocket.Conn
+	shutdown    atomic.Bool
+	connectedAt time.Time
+}
+
+// WebSocketStatus describes a connected WebSocket client
+type WebSocketStatus struct {
+	Id          uint32    `json:"id,omitempty"`
+	RemoteAddr  string    `json:"remote_addr"`
+	ConnectedAt time.Time `json:"connected_at"`
+}
+
+func (c *WebSocketClient) Status() WebSocketStatus {
+	status := WebSocketStatus{ConnectedAt: c.connectedAt}
+	if c.conn != nil {
+		status.RemoteAddr = c.conn.RemoteAddr().String()
+	}
+	return status
 }
 
 func (c *WebSocketClient) Run() {
 	log.Traceln("WebSocketClient::Run")
 	defer c.conn.Close()
 
-	for !c.shutdown {
+	for !c.shutdown.Load() {
 		messageType, message, err := c.conn.ReadMessage()
 		if err != nil {
+			if c.shutdown.Load() {
+				return
+			}
 			log.Errorf("Failed to read message: %s", err.Error())
 			return
 		}
@@ -105,10 +129,24 @@ func (c *WebSocketClient) HandleTextMessage(message []byte) error {
 
 func (c *WebSocketClient) HandleCloseMessage(message []byte) error {
 	log.Traceln("WebSocketClient::HandleCloseMessage")
-	c.shutdown = true
+	c.shutdown.Store(true)
 	return nil
 }
 
+// Close sends a close frame with the reason and closes the connection
+func (c *WebSocketClient) Close(code int, reason string) error {
+	log.Traceln("WebSocketClient::Close")
+	if c.conn == nil {
+		return nil
+	}
+	c.shutdown.Store(true)
+	message := websocket.FormatCloseMessage(code, reason)
+	if err := c.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(webSocketCloseTimeout)); err != nil {
+		log.Debugf("Failed to send close frame to %s: %s", c.conn.RemoteAddr().String(), err.Error())
+	}
+	return c.conn.Close()
+}
+
 // HandleProtobuf will attempt to unmarshal message in a protobuf packet format (see packet)
 // Upon success it will try to forward payload to the appropriate service
 func (c *WebSocketClient) HandleProtobuf(message []byte) error {